@@ -0,0 +1,630 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// === 个人数据导出与账号注销 ===
+// 导出：将用户的资料、文章、评论、修订、回应、关注、通知写入data.json，文章另外生成带front matter的Markdown，
+// 上传的附件和头像原样放入files/目录，打包为zip下载。
+// 注销：校验密码后记录计划注销时间并注销全部会话；等待期内登录后可通过DELETE /account/deletion或账号设置页撤销
+// （仅登录不会撤销）。到期后由purgeLoop执行：
+// 按accountDeletionPolicy删除文章并清空评论（delete），或保留文章和评论只匿名化作者（anonymize）。
+// 两种策略都会删除回应、关注、通知、角色、令牌、头像等个人数据，并把用户记录改为无法登录的匿名占位（文章、评论、修订仍引用它）。
+
+// 注销时文章和评论的处理方式
+const (
+	deletionPolicyAnonymize = "anonymize"
+	deletionPolicyDelete    = "delete"
+)
+
+// 账号注销参数（由配置加载）
+var (
+	accountDeletionGracePeriod = 14 * 24 * time.Hour
+	accountDeletionPolicy      = deletionPolicyAnonymize
+)
+
+// deletedUsername 注销后的用户名（超过注册允许的20个字符，不会被新用户占用）
+func deletedUsername(userID uint) string {
+	return fmt.Sprintf("deleted_user_%08d", userID)
+}
+
+// deletedPostSlug 注销时文章的占位slug（含下划线，slugify不会生成，不与其他文章冲突）
+func deletedPostSlug(postID uint) string {
+	return fmt.Sprintf("deleted_post_%d", postID)
+}
+
+// accountExport 导出的data.json
+type accountExport struct {
+	ExportedAt    time.Time        `json:"exported_at"`
+	Account       exportAccount    `json:"account"`
+	Posts         []exportPost     `json:"posts"`
+	Comments      []exportComment  `json:"comments"`
+	Revisions     []exportRevision `json:"revisions"` // 本人所做的文章修改
+	Reactions     []Reaction       `json:"reactions"`
+	Following     []exportFollow   `json:"following"`
+	Followers     []exportFollow   `json:"followers"`
+	Notifications []Notification   `json:"notifications"`
+	Files         []exportFile     `json:"files"` // 本人上传的附件及本人文章中的附件
+}
+
+type exportAccount struct {
+	ID                  uint       `json:"id"`
+	Username            string     `json:"username"`
+	Email               *string    `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	Bio                 string     `json:"bio"`
+	Avatar              string     `json:"avatar,omitempty"` // 压缩包内的路径
+	Roles               []string   `json:"roles"`
+	JoinedAt            time.Time  `json:"joined_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+type exportPost struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	Content     string     `json:"content"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Markdown    string     `json:"markdown"` // 压缩包内的Markdown文件路径
+}
+
+type exportComment struct {
+	ID        uint       `json:"id"`
+	PostID    uint       `json:"post_id"`
+	ParentID  *uint      `json:"parent_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
+
+type exportRevision struct {
+	PostID    uint      `json:"post_id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Changes   []string  `json:"changes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportFollow struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+type exportFile struct {
+	PostID      uint   `json:"post_id,omitempty"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Path        string `json:"path"` // 压缩包内的路径
+	key         string // 存储键
+}
+
+// markdownFrontMatter Markdown文件头部的元数据
+type markdownFrontMatter struct {
+	Title       string     `yaml:"title"`
+	Slug        string     `yaml:"slug"`
+	Status      string     `yaml:"status"`
+	Tags        []string   `yaml:"tags,omitempty"`
+	CreatedAt   time.Time  `yaml:"created_at"`
+	UpdatedAt   time.Time  `yaml:"updated_at"`
+	PublishedAt *time.Time `yaml:"published_at,omitempty"`
+}
+
+// accountService 个人数据导出与账号注销
+type accountService struct {
+	db       *gorm.DB
+	tokens   *tokenStore
+	storage  Storage
+	index    *searchIndex
+	renderer *contentRenderer
+	mails    *accountMailer
+}
+
+func newAccountService(db *gorm.DB, tokens *tokenStore, storage Storage, index *searchIndex, renderer *contentRenderer, mails *accountMailer) *accountService {
+	return &accountService{db: db, tokens: tokens, storage: storage, index: index, renderer: renderer, mails: mails}
+}
+
+// collectExport 查询用户的全部数据
+func (s *accountService) collectExport(userID uint) (*accountExport, error) {
+	var user User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, translateError(err)
+	}
+	roles, err := loadUserRoles(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	data := &accountExport{
+		ExportedAt: time.Now(),
+		Account: exportAccount{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Email:               user.Email,
+			EmailVerified:       user.EmailVerifiedAt != nil,
+			Bio:                 user.Bio,
+			Roles:               roles,
+			JoinedAt:            user.CreatedAt,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		},
+		Posts:         []exportPost{},
+		Comments:      []exportComment{},
+		Revisions:     []exportRevision{},
+		Reactions:     []Reaction{},
+		Following:     []exportFollow{},
+		Followers:     []exportFollow{},
+		Notifications: []Notification{},
+		Files:         []exportFile{},
+	}
+
+	var posts []Post
+	if err := s.db.Where("user_id = ?", userID).Preload("Tags").Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range posts {
+		post := exportPost{
+			ID: p.ID, Title: p.Title, Slug: p.Slug, Status: p.Status, Content: p.Content, Tags: []string{},
+			CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, PublishAt: p.PublishAt, PublishedAt: p.PublishedAt,
+		}
+		for _, tag := range p.Tags {
+			post.Tags = append(post.Tags, tag.Name)
+		}
+		post.Markdown = fmt.Sprintf("posts/%d.md", p.ID)
+		if p.Slug != "" {
+			post.Markdown = fmt.Sprintf("posts/%d-%s.md", p.ID, p.Slug)
+		}
+		data.Posts = append(data.Posts, post)
+	}
+
+	var comments []Comment
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		data.Comments = append(data.Comments, exportComment{
+			ID: c.ID, PostID: c.PostID, ParentID: c.ParentID, Content: c.Content, CreatedAt: c.CreatedAt, EditedAt: c.EditedAt,
+		})
+	}
+
+	var revisions []PostRevision
+	if err := s.db.Where("editor_id = ?", userID).Order("post_id, number").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range revisions {
+		data.Revisions = append(data.Revisions, exportRevision{
+			PostID: r.PostID, Number: r.Number, Title: r.Title, Content: r.Content, Changes: r.Changes, CreatedAt: r.CreatedAt,
+		})
+	}
+
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&data.Reactions).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Preload("Actor", selectAuthor).Order("id").Find(&data.Notifications).Error; err != nil {
+		return nil, err
+	}
+
+	var follows []Follow
+	if err := s.db.Where("follower_id = ? OR followee_id = ?", userID, userID).
+		Preload("Follower", selectAuthor).Preload("Followee", selectAuthor).Order("id").Find(&follows).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range follows {
+		if f.FollowerID == userID && f.Followee != nil {
+			data.Following = append(data.Following, exportFollow{UserID: f.FolloweeID, Username: f.Followee.Username, Since: f.CreatedAt})
+		}
+		if f.FolloweeID == userID && f.Follower != nil {
+			data.Followers = append(data.Followers, exportFollow{UserID: f.FollowerID, Username: f.Follower.Username, Since: f.CreatedAt})
+		}
+	}
+
+	var attachments []Attachment
+	if err := s.db.Where("user_id = ? OR post_id IN (?)", userID, s.db.Model(&Post{}).Select("id").Where("user_id = ?", userID)).
+		Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		data.Files = append(data.Files, exportFile{
+			PostID: a.PostID, Filename: a.Filename, ContentType: a.ContentType, Size: a.Size,
+			Path: "files/" + a.StorageKey, key: a.StorageKey,
+		})
+	}
+	if user.AvatarKey != "" {
+		data.Account.Avatar = "files/" + user.AvatarKey
+		data.Files = append(data.Files, exportFile{Filename: "avatar", Path: data.Account.Avatar, key: user.AvatarKey})
+	}
+	return data, nil
+}
+
+// writeArchive 将导出数据写为zip；单个文件读取失败时跳过并记录日志
+func (s *accountService) writeArchive(w io.Writer, data *accountExport) error {
+	zw := zip.NewWriter(w)
+
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipEntry(zw, "data.json", data.ExportedAt, bytes.NewReader(body)); err != nil {
+		return err
+	}
+
+	for _, p := range data.Posts {
+		front, err := yaml.Marshal(markdownFrontMatter{
+			Title: p.Title, Slug: p.Slug, Status: p.Status, Tags: p.Tags,
+			CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, PublishedAt: p.PublishedAt,
+		})
+		if err != nil {
+			return err
+		}
+		doc := "---\n" + string(front) + "---\n\n" + p.Content + "\n"
+		if err := writeZipEntry(zw, p.Markdown, p.UpdatedAt, bytes.NewReader([]byte(doc))); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range data.Files {
+		file, err := s.storage.Open(f.key)
+		if err != nil {
+			logger.Printf("导出时读取文件失败: key=%s err=%v", f.key, err)
+			continue
+		}
+		err = writeZipEntry(zw, f.Path, data.ExportedAt, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, modified time.Time, r io.Reader) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// scheduleDeletion 校验密码后安排注销并注销全部会话；等待期为0时立即执行
+func (s *accountService) scheduleDeletion(c *gin.Context, userID uint, password string) (time.Time, error) {
+	var user User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return time.Time{}, translateError(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return time.Time{}, errWrongPassword
+	}
+
+	at := time.Now().Add(accountDeletionGracePeriod).Truncate(time.Second) // 避免数据库舍入后晚于当前时间
+	if err := s.db.Model(&User{}).Where("id = ?", userID).Update("deletion_scheduled_at", at).Error; err != nil {
+		return time.Time{}, err
+	}
+	if err := s.tokens.revokeUser(userID); err != nil {
+		return time.Time{}, err
+	}
+	securityEvent("account_deletion_scheduled", "user=%d ip=%s at=%s", userID, c.ClientIP(), at.Format(time.RFC3339))
+
+	if accountDeletionGracePeriod == 0 {
+		return at, s.deleteAccount(userID)
+	}
+	if user.Email != nil && user.EmailVerifiedAt != nil {
+		s.mails.sendDeletionScheduled(&user, at)
+	}
+	return at, nil
+}
+
+// cancelDeletion 撤销注销；返回是否确实有待执行的注销
+func (s *accountService) cancelDeletion(c *gin.Context, userID uint) (bool, error) {
+	result := s.db.Model(&User{}).Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).Update("deletion_scheduled_at", nil)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	securityEvent("account_deletion_cancelled", "user=%d ip=%s", userID, c.ClientIP())
+	return true, nil
+}
+
+// errDeletionNotDue 账号没有到期的注销计划（已撤销或已执行）
+var errDeletionNotDue = errors.New("账号没有到期的注销计划")
+
+// deleteAccount 执行注销。先在事务中以注销计划已到期为条件改写用户记录，
+// 计划已被撤销时不做任何改动；所有数据库改动在同一事务中，中途失败时整体回滚，下一轮purgeLoop会重试
+func (s *accountService) deleteAccount(userID uint) error {
+	policy := accountDeletionPolicy
+	var user User
+	var attachments []Attachment
+	var postIDs, commentIDs []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		// 空密码哈希无法通过bcrypt校验，占位用户不能再登录
+		result := tx.Model(&User{}).
+			Where("id = ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", userID, time.Now()).
+			Updates(map[string]interface{}{
+				"username":              deletedUsername(userID),
+				"password":              "",
+				"email":                 nil,
+				"email_verified_at":     nil,
+				"bio":                   "",
+				"avatar_key":            "",
+				"deletion_scheduled_at": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDeletionNotDue
+		}
+
+		if policy == deletionPolicyDelete {
+			var posts []Post
+			if err := tx.Where("user_id = ?", userID).Find(&posts).Error; err != nil {
+				return err
+			}
+			for i := range posts {
+				var files []Attachment
+				if err := tx.Where("post_id = ?", posts[i].ID).Find(&files).Error; err != nil {
+					return err
+				}
+				if err := deletePost(tx, &posts[i]); err != nil {
+					return err
+				}
+				attachments = append(attachments, files...)
+			}
+			// 文章为软删除，标题、内容和由标题生成的slug一并清空
+			if err := tx.Unscoped().Model(&Post{}).Where("user_id = ?", userID).Pluck("id", &postIDs).Error; err != nil {
+				return err
+			}
+			for _, id := range postIDs {
+				if err := tx.Unscoped().Model(&Post{}).Where("id = ?", id).
+					Updates(map[string]interface{}{"title": "", "content": "", "slug": deletedPostSlug(id)}).Error; err != nil {
+					return err
+				}
+			}
+			// 评论清空内容后软删除，保留为占位，不影响他人的回复
+			if err := tx.Model(&Comment{}).Where("user_id = ?", userID).Pluck("id", &commentIDs).Error; err != nil {
+				return err
+			}
+			if len(commentIDs) > 0 {
+				if err := tx.Where("target_type = ? AND target_id IN ?", reactionTargetComment, commentIDs).Delete(&Reaction{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&Comment{}).Where("id IN ?", commentIDs).Update("deleted_by_id", userID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Model(&Comment{}).Where("user_id = ?", userID).Update("content", "").Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", userID).Delete(&Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("actor_id = ?", userID).Delete(&Notification{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&Reaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("follower_id = ? OR followee_id = ?", userID, userID).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&Notification{}, &UserRole{}, &AccountToken{}, &WebSession{}} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("scope = ? AND target = ?", loginScopeUser, normalizeLoginKey(user.Username)).Delete(&LoginFailure{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 以下清理在事务提交后进行，失败只记录日志
+	if err := s.tokens.revokeUser(userID); err != nil {
+		logger.Printf("注销账号时吊销令牌失败: user=%d err=%v", userID, err)
+	}
+	deleteStoredFiles(s.storage, attachments)
+	for _, id := range postIDs {
+		s.index.RemovePost(id)
+		s.renderer.Invalidate(id)
+	}
+	for _, id := range commentIDs {
+		s.index.RemoveComment(id)
+	}
+	deleteAvatarFile(s.storage, user.AvatarKey)
+	if user.Email != nil && user.EmailVerifiedAt != nil {
+		s.mails.sendAccountDeleted(&user)
+	}
+	securityEvent("account_deleted", "user=%d username=%q policy=%s", userID, user.Username, policy)
+	return nil
+}
+
+// purgeLoop 定期执行到期的注销（在独立goroutine中运行）
+func (s *accountService) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		var due []uint
+		if err := s.db.Model(&User{}).Where("deletion_scheduled_at <= ?", now).Pluck("id", &due).Error; err != nil {
+			logger.Printf("查询待注销账号失败: %v", err)
+			continue
+		}
+		for _, userID := range due {
+			if err := s.deleteAccount(userID); err != nil && !errors.Is(err, errDeletionNotDue) { // 期间用户已撤销
+				logger.Printf("注销账号失败: user=%d err=%v", userID, err)
+			}
+		}
+	}
+}
+
+// sendDeletionScheduled 通知用户账号将被注销
+func (a *accountMailer) sendDeletionScheduled(user *User, at time.Time) {
+	a.deliver(Mail{
+		To:      *user.Email,
+		Subject: "账号注销申请",
+		Body: fmt.Sprintf("%s，你好：\n\n你的账号将于%s注销。在此之前登录并在账号设置中撤销即可保留账号：\n%s\n\n如果这不是你的操作，请立即登录撤销并修改密码。\n",
+			user.Username, at.Local().Format("2006-01-02 15:04"), mailLink("/settings")),
+	})
+}
+
+// sendAccountDeleted 通知用户注销已完成（此时邮箱已从账号中删除）
+func (a *accountMailer) sendAccountDeleted(user *User) {
+	a.deliver(Mail{
+		To:      *user.Email,
+		Subject: "账号已注销",
+		Body:    fmt.Sprintf("%s，你好：\n\n你的账号已按申请注销，个人数据已删除。感谢你的使用。\n", user.Username),
+	})
+}
+
+// === 导出与注销Handler ===
+// startExportDownload 写入下载响应头；之后出错只能记录日志
+func startExportDownload(c *gin.Context, username string) {
+	name := fmt.Sprintf("blog-export-%s-%s.zip", username, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// 下载个人数据（zip）
+func exportAccountHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		data, err := accounts.collectExport(userId.(uint))
+		if err != nil {
+			logger.Printf("导出个人数据失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+			return
+		}
+		startExportDownload(c, data.Account.Username)
+		if err := accounts.writeArchive(c.Writer, data); err != nil {
+			logger.Printf("写入导出文件失败: %v", err)
+		}
+	}
+}
+
+// 申请注销账号（需当前密码），全部会话立即失效
+func scheduleDeletionHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		var input struct {
+			Password string `json:"password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		at, err := accounts.scheduleDeletion(c, userId.(uint), input.Password)
+		if err != nil {
+			if errors.Is(err, errWrongPassword) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "密码错误"})
+				return
+			}
+			logger.Printf("申请注销账号失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "申请注销失败"})
+			return
+		}
+		if accountDeletionGracePeriod == 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "账号已注销"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": fmt.Sprintf("账号将于%s注销，在此之前重新登录后调用DELETE /api/protected/account/deletion撤销即可保留账号", at.Local().Format("2006-01-02 15:04")),
+			"data":    gin.H{"deletion_scheduled_at": at},
+		})
+	}
+}
+
+// 撤销注销
+func cancelDeletionHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		found, err := accounts.cancelDeletion(c, userId.(uint))
+		if err != nil {
+			logger.Printf("撤销注销失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销失败"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有待执行的注销申请"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已撤销注销申请"})
+	}
+}
+
+// === 页面Handler ===
+// 下载个人数据
+func webExportAccountHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := accounts.collectExport(currentUserID(c))
+		if err != nil {
+			logger.Printf("导出个人数据失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "导出失败，请重试")
+			return
+		}
+		startExportDownload(c, data.Account.Username)
+		if err := accounts.writeArchive(c.Writer, data); err != nil {
+			logger.Printf("写入导出文件失败: %v", err)
+		}
+	}
+}
+
+// 申请注销账号；当前浏览器同时退出登录
+func webScheduleDeletionHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		at, err := accounts.scheduleDeletion(c, currentUserID(c), c.PostForm("password"))
+		if err != nil {
+			if errors.Is(err, errWrongPassword) {
+				renderSettingsError(c, http.StatusForbidden, "密码错误")
+				return
+			}
+			logger.Printf("申请注销账号失败: %v", err)
+			renderSettingsError(c, http.StatusInternalServerError, "申请注销失败，请重试")
+			return
+		}
+		setCookie(c, sessionCookieName, "", -1)
+		c.Set("currentUser", nil)
+		if accountDeletionGracePeriod == 0 {
+			renderMessage(c, "账号已注销", "你的账号已注销，个人数据已删除。")
+			return
+		}
+		renderMessage(c, "已申请注销", fmt.Sprintf("账号将于%s注销。在此之前重新登录并在账号设置中撤销即可保留账号。",
+			at.Local().Format("2006-01-02 15:04")))
+	}
+}
+
+// 撤销注销
+func webCancelDeletionHandler(accounts *accountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := accounts.cancelDeletion(c, currentUserID(c)); err != nil {
+			logger.Printf("撤销注销失败: %v", err)
+			renderSettingsError(c, http.StatusInternalServerError, "撤销失败，请重试")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/settings?done=deletion")
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readExport 校验下载响应并读出压缩包中的全部文件
+func readExport(t *testing.T, w *httptest.ResponseRecorder) map[string][]byte {
+	t.Helper()
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" ||
+		!strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("状态码为%d，响应头为%v", w.Code, w.Header())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("读取压缩包失败: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = body
+	}
+	return files
+}
+
+// exportData 下载个人数据并解析data.json
+func (a *testApp) exportData(token string) (accountExport, map[string][]byte) {
+	a.t.Helper()
+	files := readExport(a.t, a.request(http.MethodGet, "/api/protected/account/export", nil, token))
+	var data accountExport
+	if err := json.Unmarshal(files["data.json"], &data); err != nil {
+		a.t.Fatalf("解析data.json失败: %v", err)
+	}
+	return data, files
+}
+
+// findUser 直接从数据库读取用户记录
+func (a *testApp) findUser(userID uint) User {
+	a.t.Helper()
+	var user User
+	if err := a.db.First(&user, userID).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return user
+}
+
+// makeDeletionDue 将注销计划提前到当前时间之前，模拟等待期结束
+func (a *testApp) makeDeletionDue(userID uint) {
+	a.t.Helper()
+	if err := a.db.Model(&User{}).Where("id = ?", userID).Update("deletion_scheduled_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// storedFile 存储中的文件是否存在
+func (a *testApp) storedFile(key string) bool {
+	r, err := a.storage.Open(key)
+	if err != nil {
+		return false
+	}
+	r.Close()
+	return true
+}
+
+func TestExportAccount(t *testing.T) {
+	app := newTestApp(t)
+	aliceID, alice := app.newUser("alice")
+	bobID, bob := app.newUser("bob")
+	carolID, _ := app.newUser("carol")
+
+	post := app.createPost(alice, gin.H{"title": "Hello Export", "content": "导出测试用的文章正文内容。", "tags": []string{"Go", "Web"}})
+	draft := app.createPost(alice, gin.H{"title": "草稿", "status": postStatusDraft})
+	bobPost := app.createPost(bob, nil)
+	app.createComment(alice, bobPost.ID, 0, "Alice的评论")
+	app.createComment(bob, post.ID, 0, "Bob的评论")
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/posts/%d/reactions/like", bobPost.ID), nil, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", carolID), nil, alice), http.StatusCreated, nil)
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", aliceID), nil, bob), http.StatusCreated, nil)
+	var attachment attachmentResponse
+	expect(t, app.upload(fmt.Sprintf("/api/protected/posts/%d/attachments", post.ID), alice, "", "notes.txt", []byte("附件内容")), http.StatusCreated, &attachment)
+	expect(t, app.upload("/api/protected/profile/avatar", alice, "", "a.png", encodeTestImage(t, "image/png", 64, 64)), http.StatusOK, nil)
+
+	data, files := app.exportData(alice)
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"账号", data.Account.ID == aliceID && data.Account.Username == "alice" && data.Account.Email != nil && data.Account.DeletionScheduledAt == nil},
+		{"文章含草稿", len(data.Posts) == 2 && data.Posts[0].ID == post.ID && data.Posts[1].ID == draft.ID},
+		{"标签", len(data.Posts) > 0 && strings.Join(data.Posts[0].Tags, ",") == "go,web"},
+		{"只含本人的评论", len(data.Comments) == 1 && data.Comments[0].Content == "Alice的评论" && data.Comments[0].PostID == bobPost.ID},
+		{"修订", len(data.Revisions) >= 2},
+		{"回应", len(data.Reactions) == 1 && data.Reactions[0].TargetID == bobPost.ID},
+		{"关注", len(data.Following) == 1 && data.Following[0].UserID == carolID && data.Following[0].Username == "carol"},
+		{"粉丝", len(data.Followers) == 1 && data.Followers[0].UserID == bobID && data.Followers[0].Username == "bob"},
+		{"通知", len(data.Notifications) > 0},
+		{"附件和头像", len(data.Files) == 2 && data.Files[0].Filename == "notes.txt" && data.Account.Avatar == data.Files[1].Path},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.ok {
+				t.Fatalf("导出数据为%+v", data)
+			}
+		})
+	}
+
+	// 文章导出为带front matter的Markdown，文件原样打包
+	markdown := string(files[fmt.Sprintf("posts/%d-%s.md", post.ID, post.Slug)])
+	if !strings.HasPrefix(markdown, "---\ntitle: Hello Export\n") || !strings.Contains(markdown, "---\n\n导出测试用的文章正文内容。\n") {
+		t.Fatalf("Markdown为%q", markdown)
+	}
+	if string(files[data.Files[0].Path]) != "附件内容" || len(files[data.Account.Avatar]) == 0 {
+		t.Fatalf("压缩包中的文件为%v", len(files))
+	}
+
+	// 他人的导出不含本人数据
+	bobData, _ := app.exportData(bob)
+	if len(bobData.Posts) != 1 || bobData.Posts[0].ID != bobPost.ID || len(bobData.Files) != 0 || len(bobData.Following) != 1 {
+		t.Fatalf("Bob的导出数据为%+v", bobData)
+	}
+	expect(t, app.request(http.MethodGet, "/api/protected/account/export", nil, ""), http.StatusUnauthorized, nil)
+
+	// 页面下载
+	web := app.newWebClient()
+	web.login("alice")
+	if files := readExport(t, web.get("/settings/export")); len(files["data.json"]) == 0 {
+		t.Fatal("页面下载的压缩包缺少data.json")
+	}
+}
+
+func TestScheduleDeletion(t *testing.T) {
+	app := newTestApp(t)
+	aliceID := app.register("alice")
+	token := app.mailToken("alice@example.com", "请验证你的邮箱")
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": token}, ""), http.StatusOK, nil)
+	alice := app.login("alice")
+	const path = "/api/protected/account/deletion"
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+	}{
+		{"缺少密码", gin.H{}, http.StatusBadRequest},
+		{"密码错误", gin.H{"password": "wrong-password"}, http.StatusForbidden},
+		{"申请注销", gin.H{"password": testPassword}, http.StatusAccepted},
+		{"会话已失效", gin.H{"password": testPassword}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, path, tt.body, alice.AccessToken), tt.status, nil)
+		})
+	}
+	if w, _ := app.refresh(alice.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("申请注销后刷新令牌返回%d", w.Code)
+	}
+	user := app.findUser(aliceID)
+	if user.DeletionScheduledAt == nil || time.Until(*user.DeletionScheduledAt) < accountDeletionGracePeriod-time.Minute {
+		t.Fatalf("计划注销时间为%v", user.DeletionScheduledAt)
+	}
+	if m := app.nextMail(); m.To != "alice@example.com" || m.Subject != "账号注销申请" {
+		t.Fatalf("邮件发给%s，标题为%s", m.To, m.Subject)
+	}
+
+	// 等待期内可以登录，仅登录不会撤销
+	relogin := app.login("alice").AccessToken
+	var profile struct {
+		Data struct {
+			DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+		} `json:"data"`
+	}
+	expect(t, app.request(http.MethodGet, "/api/protected/profile", nil, relogin), http.StatusOK, &profile)
+	if profile.Data.DeletionScheduledAt == nil {
+		t.Fatal("资料中没有计划注销时间")
+	}
+
+	// 未到期或已撤销时不执行
+	if err := app.accounts.deleteAccount(aliceID); !errors.Is(err, errDeletionNotDue) {
+		t.Fatalf("未到期时注销返回%v", err)
+	}
+	expect(t, app.request(http.MethodDelete, path, nil, relogin), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, path, nil, relogin), http.StatusNotFound, nil)
+	if user := app.findUser(aliceID); user.DeletionScheduledAt != nil {
+		t.Fatalf("撤销后计划注销时间为%v", user.DeletionScheduledAt)
+	}
+	if err := app.accounts.deleteAccount(aliceID); !errors.Is(err, errDeletionNotDue) {
+		t.Fatalf("撤销后注销返回%v", err)
+	}
+	app.login("alice")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"匿名化", deletionPolicyAnonymize},
+		{"删除", deletionPolicyDelete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t, func(cfg *Config) { cfg.Accounts.DeletionPolicy = tt.policy })
+			aliceID, alice := app.newUser("alice", roleModerator)
+			bobID, bob := app.newUser("bob")
+
+			post := app.createPost(alice, gin.H{"title": "Alice的文章"})
+			bobPost := app.createPost(bob, nil)
+			comment := app.createComment(alice, bobPost.ID, 0, "Alice的评论")
+			reply := app.createComment(bob, bobPost.ID, comment.ID, "Bob的回复")
+			expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/posts/%d/reactions/like", bobPost.ID), nil, alice), http.StatusOK, nil)
+			expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", bobID), nil, alice), http.StatusCreated, nil)
+			var attachment attachmentResponse
+			expect(t, app.upload(fmt.Sprintf("/api/protected/posts/%d/attachments", post.ID), alice, "", "a.txt", []byte("附件")), http.StatusCreated, &attachment)
+			expect(t, app.upload("/api/protected/profile/avatar", alice, "", "a.png", encodeTestImage(t, "image/png", 64, 64)), http.StatusOK, nil)
+			attachmentKey := strings.TrimPrefix(attachment.Data.URL, uploadURLPrefix)
+			avatarKey := app.findUser(aliceID).AvatarKey
+
+			expect(t, app.request(http.MethodPost, "/api/protected/account/deletion", gin.H{"password": testPassword}, alice), http.StatusAccepted, nil)
+			app.makeDeletionDue(aliceID)
+			if err := app.accounts.deleteAccount(aliceID); err != nil {
+				t.Fatalf("注销失败: %v", err)
+			}
+			if err := app.accounts.deleteAccount(aliceID); !errors.Is(err, errDeletionNotDue) {
+				t.Fatalf("重复注销返回%v", err)
+			}
+
+			// 用户记录改为无法登录的匿名占位，个人数据全部删除
+			user := app.findUser(aliceID)
+			if user.Username != deletedUsername(aliceID) || user.Password != "" || user.Email != nil || user.AvatarKey != "" || user.DeletionScheduledAt != nil {
+				t.Fatalf("注销后的用户为%+v", user)
+			}
+			for _, model := range []interface{}{&Reaction{}, &Notification{}, &UserRole{}, &WebSession{}} {
+				var count int64
+				app.db.Model(model).Where("user_id = ?", aliceID).Count(&count)
+				if count != 0 {
+					t.Fatalf("%T剩余%d条", model, count)
+				}
+			}
+			var follows int64
+			app.db.Model(&Follow{}).Where("follower_id = ? OR followee_id = ?", aliceID, aliceID).Count(&follows)
+			if follows != 0 || app.storedFile(avatarKey) {
+				t.Fatalf("关注剩余%d条，头像文件是否存在: %v", follows, app.storedFile(avatarKey))
+			}
+
+			// 用户名和邮箱可以重新注册
+			app.register("alice")
+
+			postStatus, keepContent := http.StatusOK, true
+			if tt.policy == deletionPolicyDelete {
+				postStatus, keepContent = http.StatusNotFound, false
+			}
+			var detail postResponse
+			expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), postStatus, &detail)
+			if keepContent && detail.Data.User.Username != deletedUsername(aliceID) {
+				t.Fatalf("文章作者为%s", detail.Data.User.Username)
+			}
+			if app.storedFile(attachmentKey) != keepContent {
+				t.Fatalf("附件文件是否存在: %v", !keepContent)
+			}
+
+			var stored Post
+			if err := app.db.Unscoped().First(&stored, post.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			var storedComment Comment
+			if err := app.db.Unscoped().First(&storedComment, comment.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if keepContent {
+				if stored.Title != "Alice的文章" || storedComment.Content != "Alice的评论" || storedComment.DeletedAt.Valid {
+					t.Fatalf("匿名化后文章为%+v，评论为%+v", stored, storedComment)
+				}
+				return
+			}
+			// 删除策略：文章清空并改用占位slug，评论清空后保留为占位
+			if stored.Title != "" || stored.Content != "" || stored.Slug != deletedPostSlug(post.ID) || !stored.DeletedAt.Valid {
+				t.Fatalf("删除后文章为%+v", stored)
+			}
+			if storedComment.Content != "" || !storedComment.DeletedAt.Valid {
+				t.Fatalf("删除后评论为%+v", storedComment)
+			}
+			if _, err := app.repos.Comments.FindByID(reply.ID); err != nil {
+				t.Fatalf("他人的回复被删除: %v", err)
+			}
+		})
+	}
+}
+
+func TestScheduleDeletionWithoutGracePeriod(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Accounts.DeletionGracePeriod = 0 })
+	aliceID, alice := app.newUser("alice")
+
+	// 等待期为0时立即注销
+	expect(t, app.request(http.MethodPost, "/api/protected/account/deletion", gin.H{"password": testPassword}, alice), http.StatusOK, nil)
+	if user := app.findUser(aliceID); user.Username != deletedUsername(aliceID) {
+		t.Fatalf("注销后的用户名为%s", user.Username)
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": testPassword}, ""), http.StatusUnauthorized, nil)
+}
+
+func TestWebAccountDeletion(t *testing.T) {
+	app := newTestApp(t)
+	aliceID := app.register("alice")
+	web := app.newWebClient()
+	web.login("alice")
+
+	expectPage(t, web.post("/settings/delete-account", url.Values{"password": {"wrong-password"}}), http.StatusForbidden)
+	if user := app.findUser(aliceID); user.DeletionScheduledAt != nil {
+		t.Fatal("密码错误时安排了注销")
+	}
+
+	// 申请后当前浏览器退出登录
+	expectPage(t, web.post("/settings/delete-account", url.Values{"password": {testPassword}}), http.StatusOK)
+	if web.cookies[sessionCookieName] != "" {
+		t.Fatal("申请注销后仍保持登录")
+	}
+	if user := app.findUser(aliceID); user.DeletionScheduledAt == nil {
+		t.Fatal("没有安排注销")
+	}
+
+	// 重新登录后撤销
+	web.login("alice")
+	rec := web.post("/settings/delete-account/cancel", nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/settings?done=deletion" {
+		t.Fatalf("状态码为%d，跳转到%s", rec.Code, rec.Header().Get("Location"))
+	}
+	if user := app.findUser(aliceID); user.DeletionScheduledAt != nil {
+		t.Fatalf("撤销后计划注销时间为%v", user.DeletionScheduledAt)
+	}
+}
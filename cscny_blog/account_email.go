@@ -0,0 +1,468 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// === 邮箱验证与找回密码 ===
+// 验证邮箱和重置密码的链接都携带一次性令牌：服务端只保存SHA-256哈希，过期或使用后失效，
+// 重新申请会使同一用途的旧令牌作废。令牌同时记录签发时的邮箱，期间更换过邮箱则令牌无效。
+
+// 账号令牌用途
+const (
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposeResetPassword = "reset_password"
+)
+
+// 账号令牌有效期（由配置加载）
+var (
+	emailVerifyTTL   = 24 * time.Hour
+	passwordResetTTL = time.Hour
+)
+
+const maxEmailLength = 254
+
+var errAccountTokenInvalid = errors.New("链接无效或已过期")
+
+// AccountToken 邮箱验证/重置密码令牌（服务端只保存哈希）
+type AccountToken struct {
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `gorm:"not null;index"`
+	Purpose   string     `gorm:"type:varchar(20);not null"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null"`
+	Email     string     `gorm:"type:varchar(255);not null"` // 签发时的邮箱
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	UsedAt    *time.Time // 使用时间（非空表示已使用）
+	CreatedAt time.Time
+}
+
+// normalizeEmail 校验并转换为小写；只接受不带显示名的地址
+func normalizeEmail(s string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return "", errors.New("邮箱格式不正确")
+	}
+	return email, nil
+}
+
+// createAccountToken 签发令牌，同一用户同一用途未使用的旧令牌一并作废
+func (s *tokenStore) createAccountToken(userID uint, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).Delete(&AccountToken{}).Error; err != nil {
+		return "", err
+	}
+	record := AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeAccountToken 校验并使用令牌（并发请求中只有一个能成功）
+func (s *tokenStore) consumeAccountToken(token, purpose string) (*AccountToken, error) {
+	var record AccountToken
+	if err := s.db.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&record).Error; err != nil {
+		if errors.Is(translateError(err), ErrNotFound) {
+			return nil, errAccountTokenInvalid
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if record.UsedAt != nil || now.After(record.ExpiresAt) {
+		return nil, errAccountTokenInvalid
+	}
+	result := s.db.Model(&AccountToken{}).Where("id = ? AND used_at IS NULL", record.ID).Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errAccountTokenInvalid
+	}
+	return &record, nil
+}
+
+// accountMailer 发送账号相关邮件；邮件在后台发送，不让SMTP的耗时暴露邮箱是否已注册
+type accountMailer struct {
+	mailer Mailer
+	tokens *tokenStore
+}
+
+func newAccountMailer(mailer Mailer, tokens *tokenStore) *accountMailer {
+	return &accountMailer{mailer: mailer, tokens: tokens}
+}
+
+// mailLink 邮件中的链接。只使用配置的站点地址，不按请求的Host头推断，
+// 防止伪造Host让重置密码等链接指向攻击者的站点（使用SMTP时必须配置server.base_url，见Config.Validate）
+func mailLink(path string) string {
+	return siteBaseURL + path
+}
+
+// sendVerification 发送邮箱验证链接
+func (a *accountMailer) sendVerification(user *User, email string) error {
+	token, err := a.tokens.createAccountToken(user.ID, tokenPurposeVerifyEmail, email, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	link := mailLink("/verify-email?token=" + url.QueryEscape(token))
+	a.deliver(Mail{
+		To:      email,
+		Subject: "请验证你的邮箱",
+		Body: fmt.Sprintf("%s，你好：\n\n请在%s内打开以下链接完成邮箱验证：\n%s\n\n如果这不是你的操作，请忽略本邮件。\n",
+			user.Username, humanizeWait(emailVerifyTTL), link),
+	})
+	return nil
+}
+
+// sendPasswordReset 发送重置密码链接
+func (a *accountMailer) sendPasswordReset(user *User) error {
+	token, err := a.tokens.createAccountToken(user.ID, tokenPurposeResetPassword, *user.Email, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := mailLink("/reset-password?token=" + url.QueryEscape(token))
+	a.deliver(Mail{
+		To:      *user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，你好：\n\n我们收到了重置密码的请求，请在%s内打开以下链接设置新密码（链接只能使用一次）：\n%s\n\n如果这不是你的操作，请忽略本邮件，你的密码不会改变。\n",
+			user.Username, humanizeWait(passwordResetTTL), link),
+	})
+	return nil
+}
+
+func (a *accountMailer) deliver(m Mail) {
+	go func() {
+		if err := a.mailer.Send(m); err != nil {
+			logger.Printf("发送邮件失败: to=%s subject=%s err=%v", m.To, m.Subject, err)
+		}
+	}()
+}
+
+// requestPasswordReset 邮箱对应的用户存在时发送重置链接；不存在时同样返回成功
+func requestPasswordReset(c *gin.Context, users UserRepository, mails *accountMailer, email string) error {
+	user, err := users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			securityEvent("password_reset_unknown_email", "email=%q ip=%s", email, c.ClientIP())
+			return nil
+		}
+		return err
+	}
+	securityEvent("password_reset_requested", "user=%d ip=%s", user.ID, c.ClientIP())
+	return mails.sendPasswordReset(user)
+}
+
+// verifyEmail 使用验证令牌，返回验证的邮箱
+func verifyEmail(users UserRepository, tokens *tokenStore, token string) (string, error) {
+	record, err := tokens.consumeAccountToken(token, tokenPurposeVerifyEmail)
+	if err != nil {
+		return "", err
+	}
+	ok, err := users.MarkEmailVerified(record.UserID, record.Email)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errAccountTokenInvalid // 签发后更换过邮箱
+	}
+	return record.Email, nil
+}
+
+// resetPassword 使用重置令牌设置新密码，并注销该用户的所有会话、解除登录锁定
+func resetPassword(c *gin.Context, users UserRepository, tokens *tokenStore, guard *loginGuard, token, password string) error {
+	record, err := tokens.consumeAccountToken(token, tokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+	user, err := users.FindByID(record.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return errAccountTokenInvalid
+		}
+		return err
+	}
+	if user.Email == nil || *user.Email != record.Email {
+		return errAccountTokenInvalid
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+	if err := users.UpdatePassword(user.ID, string(hash)); err != nil {
+		return err
+	}
+	// 能收到重置邮件即说明邮箱属于本人
+	if _, err := users.MarkEmailVerified(user.ID, record.Email); err != nil {
+		logger.Printf("标记邮箱已验证失败: %v", err)
+	}
+	if err := tokens.revokeUser(user.ID); err != nil {
+		logger.Printf("吊销用户令牌失败: %v", err)
+	}
+	if _, err := guard.unlock(loginScopeUser, normalizeLoginKey(user.Username)); err != nil {
+		logger.Printf("解除登录锁定失败: %v", err)
+	}
+	securityEvent("password_reset", "user=%d ip=%s", user.ID, c.ClientIP())
+	return nil
+}
+
+// === 接口Handler ===
+// 申请重置密码（无论邮箱是否注册都返回相同结果）
+func forgotPasswordHandler(users UserRepository, mails *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Email string `json:"email" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		email, err := normalizeEmail(input.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := requestPasswordReset(c, users, mails, email); err != nil {
+			logger.Printf("申请重置密码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "请求失败，请重试"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码的链接已发送"})
+	}
+}
+
+// 重置密码
+func resetPasswordHandler(users UserRepository, tokens *tokenStore, guard *loginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Token    string `json:"token" binding:"required"`
+			Password string `json:"password" binding:"required,min=6,max=32"` // 规则同注册
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := resetPassword(c, users, tokens, guard, input.Token, input.Password); err != nil {
+			if errors.Is(err, errAccountTokenInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("重置密码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败，请重试"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+	}
+}
+
+// 验证邮箱
+func verifyEmailHandler(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Token string `json:"token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		email, err := verifyEmail(users, tokens, input.Token)
+		if err != nil {
+			if errors.Is(err, errAccountTokenInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("验证邮箱失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败，请重试"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功", "email": email})
+	}
+}
+
+// 重新发送验证邮件（当前用户）
+func resendVerificationHandler(users UserRepository, mails *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发送失败"})
+			return
+		}
+		if user.Email == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "尚未设置邮箱"})
+			return
+		}
+		if user.EmailVerifiedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "邮箱已验证"})
+			return
+		}
+
+		if err := mails.sendVerification(user, *user.Email); err != nil {
+			logger.Printf("发送验证邮件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发送失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "验证邮件已发送"})
+	}
+}
+
+// 设置或更换邮箱（需验证当前密码），新邮箱需重新验证
+func changeEmailHandler(users UserRepository, mails *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		var input struct {
+			Email    string `json:"email" binding:"required"`
+			Password string `json:"password" binding:"required"` // 当前密码
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		email, err := normalizeEmail(input.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改邮箱失败"})
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "密码错误"})
+			return
+		}
+		if user.Email != nil && *user.Email == email {
+			c.JSON(http.StatusConflict, gin.H{"error": "新邮箱与当前邮箱相同"})
+			return
+		}
+		if _, err := users.FindByEmail(email); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "邮箱已被使用"})
+			return
+		}
+
+		if err := users.SetEmail(user.ID, email); err != nil {
+			logger.Printf("修改邮箱失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改邮箱失败"})
+			return
+		}
+		if err := mails.sendVerification(user, email); err != nil {
+			logger.Printf("发送验证邮件失败: %v", err)
+		}
+		securityEvent("email_changed", "user=%d ip=%s", user.ID, c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"message": "邮箱已更新，请查收验证邮件"})
+	}
+}
+
+// === 页面Handler ===
+// 忘记密码页
+func webForgotPasswordFormHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderPage(c, http.StatusOK, "forgot_password.html", "忘记密码", nil)
+	}
+}
+
+// 提交忘记密码表单
+func webForgotPasswordHandler(users UserRepository, mails *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, err := normalizeEmail(c.PostForm("email"))
+		if err != nil {
+			renderPage(c, http.StatusBadRequest, "forgot_password.html", "忘记密码", gin.H{"Error": err.Error(), "Email": c.PostForm("email")})
+			return
+		}
+		if err := requestPasswordReset(c, users, mails, email); err != nil {
+			logger.Printf("申请重置密码失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "请求失败，请重试")
+			return
+		}
+		renderMessage(c, "忘记密码", "如果该邮箱已注册，重置密码的链接已发送，请查收邮件。")
+	}
+}
+
+// 重置密码页（邮件中的链接）
+func webResetPasswordFormHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Referrer-Policy", "no-referrer") // 地址中带有令牌
+		renderPage(c, http.StatusOK, "reset_password.html", "重置密码", gin.H{"Token": c.Query("token")})
+	}
+}
+
+// 提交新密码
+func webResetPasswordHandler(users UserRepository, tokens *tokenStore, guard *loginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.PostForm("token")
+		password := c.PostForm("password")
+		fail := func(status int, message string) {
+			renderPage(c, status, "reset_password.html", "重置密码", gin.H{"Error": message, "Token": token})
+		}
+		switch {
+		case len(password) < 6 || len(password) > 32:
+			fail(http.StatusBadRequest, "密码需为6-32个字符")
+			return
+		case password != c.PostForm("password_confirm"):
+			fail(http.StatusBadRequest, "两次输入的密码不一致")
+			return
+		}
+
+		if err := resetPassword(c, users, tokens, guard, token, password); err != nil {
+			if errors.Is(err, errAccountTokenInvalid) {
+				renderError(c, http.StatusBadRequest, "重置链接无效或已过期，请重新申请")
+				return
+			}
+			logger.Printf("重置密码失败: %v", err)
+			fail(http.StatusInternalServerError, "重置密码失败，请重试")
+			return
+		}
+		setCookie(c, sessionCookieName, "", -1) // 当前浏览器的会话也已注销
+		renderMessage(c, "重置密码", "密码已重置，请使用新密码登录。")
+	}
+}
+
+// 验证邮箱确认页（邮件中的链接）；令牌只在提交表单时消费，避免被邮件扫描器预先访问而失效
+func webVerifyEmailFormHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Referrer-Policy", "no-referrer") // 地址中带有令牌
+		renderPage(c, http.StatusOK, "verify_email.html", "邮箱验证", gin.H{"Token": c.Query("token")})
+	}
+}
+
+// 提交邮箱验证
+func webVerifyEmailHandler(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, err := verifyEmail(users, tokens, c.PostForm("token"))
+		if err != nil {
+			if errors.Is(err, errAccountTokenInvalid) {
+				renderError(c, http.StatusBadRequest, "验证链接无效或已过期")
+				return
+			}
+			logger.Printf("验证邮箱失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "验证失败，请重试")
+			return
+		}
+		renderMessage(c, "邮箱验证", "邮箱 "+email+" 验证成功。")
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mailTokenPattern 邮件中链接携带的令牌
+var mailTokenPattern = regexp.MustCompile(`token=([0-9a-f]+)`)
+
+// mailToken 等待下一封邮件，校验收件人和标题后返回其中的令牌
+func (a *testApp) mailToken(to, subject string) string {
+	a.t.Helper()
+	m := a.nextMail()
+	if m.To != to || m.Subject != subject {
+		a.t.Fatalf("邮件发给%s，标题为%s", m.To, m.Subject)
+	}
+	match := mailTokenPattern.FindStringSubmatch(m.Body)
+	if match == nil {
+		a.t.Fatalf("邮件中没有链接: %s", m.Body)
+	}
+	return match[1]
+}
+
+// expectNoMail 确认没有发出邮件
+func (a *testApp) expectNoMail() {
+	a.t.Helper()
+	select {
+	case m := <-a.mailer.sent:
+		a.t.Fatalf("意外发出邮件: %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// emailVerified 查询当前用户的邮箱和验证状态
+func (a *testApp) emailVerified(token string) (string, bool) {
+	a.t.Helper()
+	var resp struct {
+		Data struct {
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+		} `json:"data"`
+	}
+	expect(a.t, a.request(http.MethodGet, "/api/protected/profile", nil, token), http.StatusOK, &resp)
+	return resp.Data.Email, resp.Data.EmailVerified
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // 为空表示格式错误
+	}{
+		{"alice@example.com", "alice@example.com"},
+		{"  Alice@Example.COM ", "alice@example.com"},
+		{"Alice <alice@example.com>", ""},
+		{"alice", ""},
+		{"alice@", ""},
+		{"", ""},
+		{strings.Repeat("a", 250) + "@example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := normalizeEmail(tt.input)
+			if (err != nil) != (tt.want == "") || got != tt.want {
+				t.Fatalf("normalizeEmail(%q) = %q, %v", tt.input, got, err)
+			}
+		})
+	}
+}
+
+func TestConsumeAccountToken(t *testing.T) {
+	app := newTestApp(t)
+	userID := app.register("alice")
+	app.nextMail()
+
+	issue := func(purpose string, ttl time.Duration) string {
+		t.Helper()
+		token, err := app.tokens.createAccountToken(userID, purpose, "alice@example.com", ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	replaced := issue(tokenPurposeResetPassword, time.Hour)
+	reset := issue(tokenPurposeResetPassword, time.Hour) // 同一用途的旧令牌作废
+	verify := issue(tokenPurposeVerifyEmail, time.Hour)  // 不同用途互不影响
+	expired := issue(tokenPurposeVerifyEmail, -time.Second)
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		valid   bool
+	}{
+		{"用途不符", reset, tokenPurposeVerifyEmail, false},
+		{"有效", reset, tokenPurposeResetPassword, true},
+		{"只能使用一次", reset, tokenPurposeResetPassword, false},
+		{"重新申请后旧令牌作废", replaced, tokenPurposeResetPassword, false},
+		{"已过期", expired, tokenPurposeVerifyEmail, false},
+		{"不存在", "deadbeef", tokenPurposeVerifyEmail, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := app.tokens.consumeAccountToken(tt.token, tt.purpose)
+			if tt.valid {
+				if err != nil || record.UserID != userID || record.Email != "alice@example.com" {
+					t.Fatalf("使用令牌失败: %+v %v", record, err)
+				}
+				return
+			}
+			if err != errAccountTokenInvalid {
+				t.Fatalf("错误为%v，期望令牌无效", err)
+			}
+		})
+	}
+
+	// 重新签发验证令牌后，未使用的旧令牌作废
+	fresh := issue(tokenPurposeVerifyEmail, time.Hour)
+	if _, err := app.tokens.consumeAccountToken(verify, tokenPurposeVerifyEmail); err != errAccountTokenInvalid {
+		t.Fatalf("作废的令牌仍可使用: %v", err)
+	}
+
+	// 并发使用同一令牌只有一次成功
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := app.tokens.consumeAccountToken(fresh, tokenPurposeVerifyEmail); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("令牌被使用了%d次", succeeded)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	token := app.mailToken("alice@example.com", "请验证你的邮箱")
+	app.register("bob")
+	app.nextMail()
+
+	if email, verified := app.emailVerified(alice); email != "alice@example.com" || verified {
+		t.Fatalf("注册后邮箱为%s，已验证=%v", email, verified)
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": "deadbeef"}, ""), http.StatusBadRequest, nil)
+	var resp struct {
+		Email string `json:"email"`
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": token}, ""), http.StatusOK, &resp)
+	if resp.Email != "alice@example.com" {
+		t.Fatalf("验证的邮箱为%s", resp.Email)
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": token}, ""), http.StatusBadRequest, nil)
+	if _, verified := app.emailVerified(alice); !verified {
+		t.Fatal("验证后邮箱仍未验证")
+	}
+	expect(t, app.request(http.MethodPost, "/api/protected/auth/resend-verification", nil, alice), http.StatusConflict, nil)
+
+	// 更换邮箱需要当前密码，新邮箱需重新验证
+	changes := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"密码错误", gin.H{"email": "new@example.com", "password": "wrong"}, http.StatusForbidden},
+		{"格式错误", gin.H{"email": "new", "password": testPassword}, http.StatusBadRequest},
+		{"与当前邮箱相同", gin.H{"email": "ALICE@example.com", "password": testPassword}, http.StatusConflict},
+		{"已被其他用户使用", gin.H{"email": "bob@example.com", "password": testPassword}, http.StatusConflict},
+		{"成功", gin.H{"email": "New@Example.com", "password": testPassword}, http.StatusOK},
+	}
+	for _, tt := range changes {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPut, "/api/protected/auth/email", tt.body, alice), tt.status, nil)
+		})
+	}
+	first := app.mailToken("new@example.com", "请验证你的邮箱")
+	if email, verified := app.emailVerified(alice); email != "new@example.com" || verified {
+		t.Fatalf("更换后邮箱为%s，已验证=%v", email, verified)
+	}
+
+	// 重新发送后旧链接作废；签发后再次更换邮箱，发往旧邮箱的链接失效
+	expect(t, app.request(http.MethodPost, "/api/protected/auth/resend-verification", nil, alice), http.StatusOK, nil)
+	resent := app.mailToken("new@example.com", "请验证你的邮箱")
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": first}, ""), http.StatusBadRequest, nil)
+	expect(t, app.request(http.MethodPut, "/api/protected/auth/email", gin.H{"email": "other@example.com", "password": testPassword}, alice), http.StatusOK, nil)
+	latest := app.mailToken("other@example.com", "请验证你的邮箱")
+	expect(t, app.request(http.MethodPost, "/api/public/auth/verify-email", gin.H{"token": resent}, ""), http.StatusBadRequest, nil)
+
+	// 网页验证：打开链接不消费令牌，提交表单后才验证
+	web := app.newWebClient()
+	rec := web.get("/verify-email?token=" + latest)
+	expectPage(t, rec, http.StatusOK)
+	if rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Fatal("验证页缺少Referrer-Policy")
+	}
+	if body := expectPage(t, web.post("/verify-email", url.Values{"token": {latest}}), http.StatusOK); !strings.Contains(body, "other@example.com") {
+		t.Fatalf("验证结果页为%s", body)
+	}
+	expectPage(t, web.post("/verify-email", url.Values{"token": {latest}}), http.StatusBadRequest)
+	if email, verified := app.emailVerified(alice); email != "other@example.com" || !verified {
+		t.Fatalf("网页验证后邮箱为%s，已验证=%v", email, verified)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	app.nextMail()
+	session := app.login("alice")
+	web := app.newWebClient()
+	web.login("alice")
+
+	// 未注册的邮箱返回相同结果但不发邮件
+	forgot := func(email string) {
+		t.Helper()
+		expect(t, app.request(http.MethodPost, "/api/public/auth/forgot-password", gin.H{"email": email}, ""), http.StatusOK, nil)
+	}
+	forgot("nobody@example.com")
+	app.expectNoMail()
+	expect(t, app.request(http.MethodPost, "/api/public/auth/forgot-password", gin.H{"email": "bad"}, ""), http.StatusBadRequest, nil)
+
+	forgot("Alice@Example.com")
+	replaced := app.mailToken("alice@example.com", "重置密码")
+	forgot("alice@example.com")
+	token := app.mailToken("alice@example.com", "重置密码")
+
+	resets := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"密码过短", gin.H{"token": token, "password": "123"}, http.StatusBadRequest},
+		{"旧链接已作废", gin.H{"token": replaced, "password": "newpassword"}, http.StatusBadRequest},
+		{"成功", gin.H{"token": token, "password": "newpassword"}, http.StatusOK},
+		{"链接只能使用一次", gin.H{"token": token, "password": "another"}, http.StatusBadRequest},
+	}
+	for _, tt := range resets {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, "/api/public/auth/reset-password", tt.body, ""), tt.status, nil)
+		})
+	}
+
+	// 新密码生效，旧密码失效，已有会话全部注销，邮箱视为已验证
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": testPassword}, ""), http.StatusUnauthorized, nil)
+	var resp loginResponse
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": "newpassword"}, ""), http.StatusOK, &resp)
+	if w, _ := app.refresh(session.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("重置密码后旧的刷新令牌返回%d", w.Code)
+	}
+	if rec := web.get("/settings"); rec.Code != http.StatusSeeOther {
+		t.Fatalf("重置密码后网页会话仍有效: %d", rec.Code)
+	}
+	if _, verified := app.emailVerified(resp.AccessToken); !verified {
+		t.Fatal("重置密码后邮箱未标记为已验证")
+	}
+}
+
+func TestMailLinksIgnoreHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{"使用配置的站点地址", "https://blog.example.com/", "https://blog.example.com/reset-password?token="},
+		{"未配置时为相对链接", "", "\n/reset-password?token="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t, func(cfg *Config) { cfg.Server.BaseURL = tt.baseURL })
+			app.register("alice")
+			app.nextMail()
+
+			// 伪造Host请求重置密码，邮件中的链接不受影响
+			req := httptest.NewRequest(http.MethodPost, "/api/public/auth/forgot-password", strings.NewReader(`{"email":"alice@example.com"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Host = "evil.example"
+			w := httptest.NewRecorder()
+			app.router.ServeHTTP(w, req)
+			expect(t, w, http.StatusOK, nil)
+
+			if m := app.nextMail(); !strings.Contains(m.Body, tt.want) || strings.Contains(m.Body, "evil.example") {
+				t.Fatalf("邮件内容为%s", m.Body)
+			}
+		})
+	}
+}
+
+func TestWebPasswordReset(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	app.nextMail()
+	web := app.newWebClient()
+
+	expectPage(t, web.post("/forgot-password", url.Values{"email": {"bad"}}), http.StatusBadRequest)
+	expectPage(t, web.post("/forgot-password", url.Values{"email": {"alice@example.com"}}), http.StatusOK)
+	token := app.mailToken("alice@example.com", "重置密码")
+
+	rec := web.get("/reset-password?token=" + token)
+	if body := expectPage(t, rec, http.StatusOK); !strings.Contains(body, token) || rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Fatal("重置密码页缺少令牌或Referrer-Policy")
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		confirm string
+		status  int
+	}{
+		{"两次密码不一致", token, "different", http.StatusBadRequest},
+		{"链接无效", "deadbeef", "newpassword", http.StatusBadRequest},
+		{"成功", token, "newpassword", http.StatusOK},
+		{"链接只能使用一次", token, "newpassword", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"token": {tt.token}, "password": {"newpassword"}, "password_confirm": {tt.confirm}}
+			expectPage(t, web.post("/reset-password", form), tt.status)
+		})
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": "newpassword"}, ""), http.StatusOK, nil)
+}
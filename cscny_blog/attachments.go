@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === 文章附件 ===
+// 附件通过multipart上传并关联到文章，文件保存在Storage中，以随机文件名通过/uploads/访问。
+// 文件类型按内容检测而不信任客户端声明；图片会去除元数据并生成缩略图（见image_process.go）。
+
+const (
+	uploadURLPrefix       = "/uploads/"
+	uploadFormField       = "file"
+	maxAttachmentsPerPost = 50
+	maxFilenameLength     = 255
+	maxUploadSizeMB       = 100 // 配置允许的单个文件大小上限
+)
+
+// uploadMaxSize 单个文件的大小上限（字节，由配置加载）
+var uploadMaxSize int64 = 10 << 20
+
+// attachmentTypes 允许上传的文件类型及保存时使用的扩展名
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// Attachment 文章附件
+type Attachment struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	PostID       uint      `gorm:"not null;index" json:"post_id"`
+	UserID       uint      `gorm:"not null" json:"user_id"`                    // 上传者
+	Filename     string    `gorm:"type:varchar(255);not null" json:"filename"` // 原始文件名（仅用于展示）
+	ContentType  string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Width        int       `json:"width,omitempty"`  // 图片宽度
+	Height       int       `json:"height,omitempty"` // 图片高度
+	StorageKey   string    `gorm:"type:varchar(255);not null" json:"-"`
+	ThumbnailKey string    `gorm:"type:varchar(255)" json:"-"` // 为空表示无缩略图
+	URL          string    `gorm:"-" json:"url"`
+	ThumbnailURL string    `gorm:"-" json:"thumbnail_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AfterFind 根据存储键填充访问地址
+func (a *Attachment) AfterFind(*gorm.DB) error {
+	a.fillURLs()
+	return nil
+}
+
+func (a *Attachment) fillURLs() {
+	a.URL = uploadURLPrefix + a.StorageKey
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = uploadURLPrefix + a.ThumbnailKey
+	} else if strings.HasPrefix(a.ContentType, "image/") {
+		a.ThumbnailURL = a.URL // 小图直接使用原图
+	}
+}
+
+// storageKeys 附件占用的全部存储键
+func (a *Attachment) storageKeys() []string {
+	if a.ThumbnailKey == "" {
+		return []string{a.StorageKey}
+	}
+	return []string{a.StorageKey, a.ThumbnailKey}
+}
+
+// AttachmentRepository 附件仓储
+type AttachmentRepository interface {
+	Create(a *Attachment) error
+	FindByID(id uint) (*Attachment, error)
+	ListByPost(postID uint) ([]Attachment, error) // 按上传顺序
+	Delete(id uint) error
+}
+
+// deleteStoredFiles 删除附件对应的文件；删除失败只记录日志（数据库记录已删除，残留文件不影响访问）
+func deleteStoredFiles(storage Storage, attachments []Attachment) {
+	for _, a := range attachments {
+		for _, key := range a.storageKeys() {
+			if err := storage.Delete(key); err != nil {
+				logger.Printf("删除附件文件失败: key=%s err=%v", key, err)
+			}
+		}
+	}
+}
+
+// detectContentType 按文件内容检测类型（忽略charset等参数）
+func detectContentType(data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// cleanFilename 只保留原始文件名的最后一段并限制长度
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLength/4 { // varchar按字符计，中文文件名也不会超长
+		name = string(runes[:maxFilenameLength/4])
+	}
+	return name
+}
+
+// uploadError 读取上传文件失败，Status为应返回的HTTP状态码
+type uploadError struct {
+	Status  int
+	Message string
+}
+
+// readUploadedFile 读取multipart表单file字段的内容和原始文件名，大小不超过maxSize字节
+func readUploadedFile(c *gin.Context, maxSize int64) ([]byte, string, *uploadError) {
+	tooLarge := &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("文件不能超过%dMB", maxSize>>20)}
+	// 为multipart边界等开销预留1MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	header, err := c.FormFile(uploadFormField)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return nil, "", tooLarge
+		}
+		return nil, "", &uploadError{http.StatusBadRequest, "请通过file字段上传文件"}
+	}
+	if header.Size > maxSize {
+		return nil, "", tooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", &uploadError{http.StatusBadRequest, "读取上传文件失败"}
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, "", &uploadError{http.StatusBadRequest, "读取上传文件失败"}
+	}
+	if len(data) == 0 {
+		return nil, "", &uploadError{http.StatusBadRequest, "文件为空"}
+	}
+	return data, header.Filename, nil
+}
+
+// === 附件Handler ===
+// 上传附件（作者或拥有post:edit_any权限的用户），multipart表单字段为file
+func uploadAttachmentHandler(posts PostRepository, attachments AttachmentRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+				return
+			}
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+		if post.UserID != userId.(uint) && !hasPermission(c, permPostEditAny) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限为此文章上传附件"})
+			return
+		}
+
+		existing, err := attachments.ListByPost(post.ID)
+		if err != nil {
+			logger.Printf("查询附件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+			return
+		}
+		if len(existing) >= maxAttachmentsPerPost {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每篇文章最多%d个附件", maxAttachmentsPerPost)})
+			return
+		}
+
+		data, filename, uerr := readUploadedFile(c, uploadMaxSize)
+		if uerr != nil {
+			c.JSON(uerr.Status, gin.H{"error": uerr.Message})
+			return
+		}
+
+		contentType := detectContentType(data)
+		ext, ok := attachmentTypes[contentType]
+		if !ok {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的文件类型: " + contentType})
+			return
+		}
+
+		attachment := Attachment{
+			PostID:      post.ID,
+			UserID:      userId.(uint),
+			Filename:    cleanFilename(filename),
+			ContentType: contentType,
+		}
+		var thumbnail []byte
+		if strings.HasPrefix(contentType, "image/") {
+			img, err := processImage(data, contentType)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			data, thumbnail = img.Data, img.Thumbnail
+			attachment.Width, attachment.Height = img.Width, img.Height
+		}
+		attachment.Size = int64(len(data))
+
+		name, err := randomToken(16)
+		if err != nil {
+			logger.Printf("生成文件名失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+			return
+		}
+		prefix := "posts/" + strconv.FormatUint(uint64(post.ID), 10) + "/" + name
+		attachment.StorageKey = prefix + ext
+		if thumbnail != nil {
+			attachment.ThumbnailKey = prefix + "_thumb" + ext
+			if contentType == "image/gif" {
+				attachment.ThumbnailKey = prefix + "_thumb.png"
+			}
+		}
+
+		saved := []Attachment{{StorageKey: attachment.StorageKey}} // 失败时清理已写入的文件
+		if err := storage.Save(attachment.StorageKey, bytes.NewReader(data)); err != nil {
+			logger.Printf("保存附件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+			return
+		}
+		if thumbnail != nil {
+			saved[0].ThumbnailKey = attachment.ThumbnailKey
+			if err := storage.Save(attachment.ThumbnailKey, bytes.NewReader(thumbnail)); err != nil {
+				logger.Printf("保存缩略图失败: %v", err)
+				deleteStoredFiles(storage, saved)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+				return
+			}
+		}
+		if err := attachments.Create(&attachment); err != nil {
+			logger.Printf("保存附件记录失败: %v", err)
+			deleteStoredFiles(storage, saved)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+			return
+		}
+		attachment.fillURLs()
+
+		c.JSON(http.StatusCreated, gin.H{"data": attachment})
+	}
+}
+
+// 文章的附件列表（无需认证，可见性规则同文章详情）
+func listAttachmentsHandler(posts PostRepository, attachments AttachmentRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		post, err := posts.FindByID(postID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询附件失败"})
+			return
+		}
+		if post == nil || !canViewPost(c, post) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return
+		}
+
+		list, err := attachments.ListByPost(post.ID)
+		if err != nil {
+			logger.Printf("查询附件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询附件失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list})
+	}
+}
+
+// 删除附件（文章作者或拥有post:edit_any权限的用户）
+func deleteAttachmentHandler(posts PostRepository, attachments AttachmentRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		attachment, err := attachments.FindByID(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+				return
+			}
+			logger.Printf("查询附件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+			return
+		}
+		post, err := posts.FindByID(attachment.PostID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+			return
+		}
+		if post == nil || (post.UserID != userId.(uint) && !hasPermission(c, permPostEditAny)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限删除此附件"})
+			return
+		}
+
+		if err := attachments.Delete(attachment.ID); err != nil {
+			logger.Printf("删除附件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+			return
+		}
+		deleteStoredFiles(storage, []Attachment{*attachment})
+
+		c.JSON(http.StatusOK, gin.H{"message": "附件删除成功"})
+	}
+}
+
+// 读取上传的文件（文件名随机且内容不变，允许长期缓存）
+func serveUploadHandler(storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		contentType := mime.TypeByExtension(filepath.Ext(key))
+		if contentType == "" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		file, err := storage.Open(key)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				logger.Printf("读取附件失败: key=%s err=%v", key, err)
+			}
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		c.Header("Content-Type", contentType)
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		if !strings.HasPrefix(contentType, "image/") {
+			c.Header("Content-Disposition", "attachment") // 非图片一律下载，不在站点域名下直接打开
+		}
+		if rs, ok := file.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, "", time.Time{}, rs)
+			return
+		}
+		c.Status(http.StatusOK)
+		io.Copy(c.Writer, file)
+	}
+}
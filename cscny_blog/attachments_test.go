@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attachmentResponse 上传接口的响应
+type attachmentResponse struct {
+	Data Attachment `json:"data"`
+}
+
+// upload 以multipart表单上传文件（field为空时使用file字段）
+func (a *testApp) upload(path, token, field, filename string, content []byte) *httptest.ResponseRecorder {
+	a.t.Helper()
+	if field == "" {
+		field = uploadFormField
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile(field, filename)
+	part.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCleanFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\报告.pdf`, "报告.pdf"},
+		{"dir/", "dir"},
+		{"/", "file"},
+		{"", "file"},
+		{strings.Repeat("长", maxFilenameLength), strings.Repeat("长", maxFilenameLength/4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanFilename(tt.name); got != tt.want {
+				t.Fatalf("文件名为%q，期望%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadAttachment(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	_, moderator := app.newUser("moderator", roleModerator)
+	post := app.createPost(author, nil)
+	path := fmt.Sprintf("/api/protected/posts/%d/attachments", post.ID)
+	large := encodeTestImage(t, "image/png", 640, 480)
+
+	tests := []struct {
+		name        string
+		token       string
+		path        string
+		field       string
+		filename    string
+		content     []byte
+		status      int
+		contentType string
+		thumbnail   bool
+	}{
+		{"大图", author, path, "", "../photo.png", large, http.StatusCreated, "image/png", true},
+		{"小图", author, path, "", "small.gif", encodeTestImage(t, "image/gif", 10, 10), http.StatusCreated, "image/gif", false},
+		{"文本文件", author, path, "", "readme.txt", []byte("说明文字"), http.StatusCreated, "text/plain", false},
+		{"版主上传他人文章附件", moderator, path, "", "notes.txt", []byte("notes"), http.StatusCreated, "text/plain", false},
+		{"按内容检测类型", author, path, "", "fake.png", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType, "", false},
+		{"可执行文件", author, path, "", "a.exe", []byte("MZ\x90\x00\x03\x00\x00\x00"), http.StatusUnsupportedMediaType, "", false},
+		{"损坏的图片", author, path, "", "broken.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), http.StatusBadRequest, "", false},
+		{"空文件", author, path, "", "empty.txt", nil, http.StatusBadRequest, "", false},
+		{"字段名错误", author, path, "upload", "a.txt", []byte("a"), http.StatusBadRequest, "", false},
+		{"不是作者", stranger, path, "", "a.txt", []byte("a"), http.StatusForbidden, "", false},
+		{"文章不存在", author, "/api/protected/posts/9999/attachments", "", "a.txt", []byte("a"), http.StatusNotFound, "", false},
+		{"未登录", "", path, "", "a.txt", []byte("a"), http.StatusUnauthorized, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp attachmentResponse
+			expect(t, app.upload(tt.path, tt.token, tt.field, tt.filename, tt.content), tt.status, &resp)
+			if tt.status != http.StatusCreated {
+				return
+			}
+			a := resp.Data
+			if a.ContentType != tt.contentType || a.PostID != post.ID || strings.Contains(a.Filename, "/") {
+				t.Fatalf("附件为%+v", a)
+			}
+			if !strings.HasPrefix(a.URL, fmt.Sprintf("%sposts/%d/", uploadURLPrefix, post.ID)) {
+				t.Fatalf("附件地址为%s", a.URL)
+			}
+			switch {
+			case tt.thumbnail && (a.ThumbnailURL == "" || a.ThumbnailURL == a.URL):
+				t.Fatalf("大图缩略图地址为%q", a.ThumbnailURL)
+			case !tt.thumbnail && strings.HasPrefix(tt.contentType, "image/") && a.ThumbnailURL != a.URL:
+				t.Fatalf("小图缩略图地址为%q", a.ThumbnailURL)
+			case !strings.HasPrefix(tt.contentType, "image/") && a.ThumbnailURL != "":
+				t.Fatalf("非图片有缩略图%q", a.ThumbnailURL)
+			}
+		})
+	}
+
+	// 列表按上传顺序，文章详情中也带附件
+	var list listResponse[Attachment]
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/attachments", post.ID), nil, ""), http.StatusOK, &list)
+	if len(list.Data) != 4 || list.Data[0].Filename != "photo.png" || list.Data[0].Width != 640 || list.Data[0].Height != 480 {
+		t.Fatalf("附件列表为%+v", list.Data)
+	}
+	var detail postResponse
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusOK, &detail)
+	if len(detail.Data.Attachments) != 4 || detail.Data.Attachments[0].URL != list.Data[0].URL {
+		t.Fatalf("文章详情中的附件为%+v", detail.Data.Attachments)
+	}
+
+	// 读取文件：图片可直接显示，其他文件强制下载
+	files := []struct {
+		name        string
+		url         string
+		contentType string
+		disposition string
+	}{
+		{"图片", list.Data[0].URL, "image/png", ""},
+		{"缩略图", list.Data[0].ThumbnailURL, "image/png", ""},
+		{"文本", list.Data[2].URL, "text/plain; charset=utf-8", "attachment"},
+	}
+	for _, f := range files {
+		t.Run(f.name, func(t *testing.T) {
+			w := app.request(http.MethodGet, f.url, nil, "")
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != f.contentType ||
+				w.Header().Get("Content-Disposition") != f.disposition || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Fatalf("状态码为%d，响应头为%v", w.Code, w.Header())
+			}
+		})
+	}
+	for _, url := range []string{uploadURLPrefix + "posts/1/missing.png", uploadURLPrefix + "../main.go", uploadURLPrefix + "posts/1/noext"} {
+		if w := app.request(http.MethodGet, url, nil, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("%s返回%d", url, w.Code)
+		}
+	}
+}
+
+func TestUploadAttachmentLimits(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Uploads.MaxSizeMB = 1 })
+	_, token := app.newUser("alice")
+	post := app.createPost(token, nil)
+	path := fmt.Sprintf("/api/protected/posts/%d/attachments", post.ID)
+
+	expect(t, app.upload(path, token, "", "big.txt", bytes.Repeat([]byte("a"), 1<<20+1)), http.StatusRequestEntityTooLarge, nil)
+	expect(t, app.upload(path, token, "", "huge.txt", bytes.Repeat([]byte("a"), 3<<20)), http.StatusRequestEntityTooLarge, nil)
+	expect(t, app.upload(path, token, "", "ok.txt", bytes.Repeat([]byte("a"), 1<<20)), http.StatusCreated, nil)
+
+	// 附件数量上限
+	for i := 1; i < maxAttachmentsPerPost; i++ {
+		if err := app.repos.Attachments.Create(&Attachment{PostID: post.ID, UserID: 1, Filename: "a.txt", ContentType: "text/plain", StorageKey: fmt.Sprintf("k%d.txt", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(t, app.upload(path, token, "", "more.txt", []byte("a")), http.StatusBadRequest, nil)
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	post := app.createPost(author, nil)
+	path := fmt.Sprintf("/api/protected/posts/%d/attachments", post.ID)
+
+	uploadImage := func() Attachment {
+		t.Helper()
+		var resp attachmentResponse
+		expect(t, app.upload(path, author, "", "a.png", encodeTestImage(t, "image/png", 640, 480)), http.StatusCreated, &resp)
+		return resp.Data
+	}
+	exists := func(url string) bool {
+		return app.request(http.MethodGet, url, nil, "").Code == http.StatusOK
+	}
+
+	// 删除附件时一并删除原图和缩略图
+	first := uploadImage()
+	deletePath := fmt.Sprintf("/api/protected/attachments/%d", first.ID)
+	expect(t, app.request(http.MethodDelete, deletePath, nil, stranger), http.StatusForbidden, nil)
+	expect(t, app.request(http.MethodDelete, deletePath, nil, author), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, deletePath, nil, author), http.StatusNotFound, nil)
+	if exists(first.URL) || exists(first.ThumbnailURL) {
+		t.Fatal("删除附件后文件仍可访问")
+	}
+
+	// 删除文章时清理全部附件
+	second, third := uploadImage(), uploadImage()
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/posts/%d", post.ID), nil, author), http.StatusOK, nil)
+	for _, a := range []Attachment{second, third} {
+		if exists(a.URL) || exists(a.ThumbnailURL) {
+			t.Fatalf("删除文章后附件%d仍可访问", a.ID)
+		}
+		if _, err := app.repos.Attachments.FindByID(a.ID); err == nil {
+			t.Fatalf("删除文章后附件记录%d仍存在", a.ID)
+		}
+	}
+
+	// 草稿的附件列表对他人不可见
+	draft := app.createPost(author, gin.H{"status": postStatusDraft})
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/attachments", draft.ID), nil, stranger), http.StatusNotFound, nil)
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/attachments", draft.ID), nil, author), http.StatusOK, nil)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// === 令牌配置 ===
+var (
+	accessTokenTTL  = 15 * time.Minute   // 访问令牌有效期（短期）
+	refreshTokenTTL = 7 * 24 * time.Hour // 刷新令牌有效期
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	errRefreshTokenReused  = errors.New("刷新令牌被重复使用")
+)
+
+// === 令牌数据模型 ===
+// RefreshToken 刷新令牌（服务端只保存哈希）
+type RefreshToken struct {
+	gorm.Model
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null"` // 令牌SHA-256哈希
+	UserID    uint       `gorm:"index;not null"`                     // 所属用户ID
+	FamilyID  string     `gorm:"type:char(32);index;not null"`       // 令牌家族（同一次登录轮换出的所有令牌）
+	AccessJTI string     `gorm:"type:char(32)"`                      // 同时签发的访问令牌jti
+	ExpiresAt time.Time  `gorm:"not null"`                           // 过期时间
+	UsedAt    *time.Time // 已轮换时间（非空表示已使用）
+	RevokedAt *time.Time // 吊销时间（非空表示已吊销）
+}
+
+// RevokedToken 访问令牌吊销列表（按jti记录，过期后可清理）
+type RevokedToken struct {
+	JTI       string    `gorm:"type:char(32);primaryKey"` // 访问令牌jti
+	ExpiresAt time.Time `gorm:"index;not null"`           // 原令牌过期时间
+	CreatedAt time.Time
+}
+
+// tokenPair 登录/刷新时返回给客户端的令牌对
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌剩余秒数
+}
+
+// === 令牌存储 ===
+// tokenStore 负责签发、轮换和吊销令牌
+type tokenStore struct {
+	db *gorm.DB
+}
+
+func newTokenStore(db *gorm.DB) *tokenStore {
+	return &tokenStore{db: db}
+}
+
+// randomToken 生成n字节的随机串（十六进制）
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signAccessToken 签发短期访问令牌（携带角色和权限），返回令牌字符串和jti
+func signAccessToken(userID uint, roles []string) (string, string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   userID,
+		"jti":   jti,
+		"typ":   "access",
+		"roles": roles,
+		"perms": permissionsFor(roles),
+		"iat":   now.Unix(),
+		"exp":   now.Add(accessTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return "", "", err
+	}
+	return tokenString, jti, nil
+}
+
+// issue 为用户签发新的令牌对；familyID为空时开启新的令牌家族
+func (s *tokenStore) issue(userID uint, familyID string) (*tokenPair, error) {
+	return issueTokens(s.db, userID, familyID)
+}
+
+// issueTokens 在db（可以是事务）中签发令牌对
+func issueTokens(db *gorm.DB, userID uint, familyID string) (*tokenPair, error) {
+	if familyID == "" {
+		id, err := randomToken(16)
+		if err != nil {
+			return nil, err
+		}
+		familyID = id
+	}
+
+	// 每次签发都重新读取角色，角色变更在下一次刷新时生效
+	roles, err := loadUserRoles(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, jti, err := signAccessToken(userID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		UserID:    userID,
+		FamilyID:  familyID,
+		AccessJTI: jti,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL / time.Second),
+	}, nil
+}
+
+// rotate 使用刷新令牌换取新的令牌对；检测到重复使用时吊销整个令牌家族
+func (s *tokenStore) rotate(refreshToken string) (*tokenPair, error) {
+	var record RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	if record.UsedAt != nil || record.RevokedAt != nil {
+		if err := s.revokeFamily(record.FamilyID); err != nil {
+			return nil, err
+		}
+		logger.Printf("检测到刷新令牌重复使用，已吊销令牌家族: user=%d family=%s", record.UserID, record.FamilyID)
+		return nil, errRefreshTokenReused
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, errRefreshTokenInvalid
+	}
+
+	// 条件更新保证并发请求中只有一个能完成轮换；标记与签发在同一事务中，签发失败时旧令牌仍可使用
+	var pair *tokenPair
+	reused := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", record.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		var err error
+		pair, err = issueTokens(tx, record.UserID, record.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		if err := s.revokeFamily(record.FamilyID); err != nil {
+			return nil, err
+		}
+		logger.Printf("检测到刷新令牌并发重复使用，已吊销令牌家族: user=%d family=%s", record.UserID, record.FamilyID)
+		return nil, errRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// revokeFamily 吊销令牌家族中的所有刷新令牌及其未过期的访问令牌
+func (s *tokenStore) revokeFamily(familyID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var records []RefreshToken
+		if err := tx.Where("family_id = ?", familyID).Find(&records).Error; err != nil {
+			return err
+		}
+
+		accessExpiry := time.Now().Add(accessTokenTTL)
+		for _, record := range records {
+			if record.AccessJTI == "" {
+				continue
+			}
+			if err := revokeJTI(tx, record.AccessJTI, accessExpiry); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// revokeRefreshToken 吊销某个刷新令牌所在的令牌家族（用于登出）
+func (s *tokenStore) revokeRefreshToken(userID uint, refreshToken string) error {
+	var record RefreshToken
+	if err := s.db.Where("token_hash = ? AND user_id = ?", hashToken(refreshToken), userID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errRefreshTokenInvalid
+		}
+		return err
+	}
+	return s.revokeFamily(record.FamilyID)
+}
+
+// revokeUser 吊销用户的所有令牌家族和网页会话（角色变更、修改密码等场景）
+func (s *tokenStore) revokeUser(userID uint) error {
+	var families []string
+	if err := s.db.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Distinct().Pluck("family_id", &families).Error; err != nil {
+		return err
+	}
+	for _, familyID := range families {
+		if err := s.revokeFamily(familyID); err != nil {
+			return err
+		}
+	}
+	return s.db.Where("user_id = ?", userID).Delete(&WebSession{}).Error // 网页会话一并注销
+}
+
+// revokeAccessToken 将访问令牌加入吊销列表
+func (s *tokenStore) revokeAccessToken(jti string, expiresAt time.Time) error {
+	return revokeJTI(s.db, jti, expiresAt)
+}
+
+func revokeJTI(db *gorm.DB, jti string, expiresAt time.Time) error {
+	// 并发吊销同一令牌时主键冲突视为已吊销
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// isRevoked 检查访问令牌是否已被吊销
+func (s *tokenStore) isRevoked(jti string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// purgeExpired 清理已过期的吊销记录和刷新令牌
+func (s *tokenStore) purgeExpired() error {
+	now := time.Now()
+	if err := s.db.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("expires_at < ?", now).Delete(&WebSession{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("expires_at < ?", now).Delete(&AccountToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.Unscoped().Where("expires_at < ?", now).Delete(&RefreshToken{}).Error
+}
+
+// purgeLoop 定期清理过期令牌（在独立goroutine中运行）
+func (s *tokenStore) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.purgeExpired(); err != nil {
+			logger.Printf("清理过期令牌失败: %v", err)
+		}
+	}
+}
+
+// === 令牌Handler ===
+// 刷新令牌（无需认证，凭刷新令牌换取新的令牌对）
+func refreshHandler(tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pair, err := tokens.rotate(input.RefreshToken)
+		if err != nil {
+			if errors.Is(err, errRefreshTokenInvalid) || errors.Is(err, errRefreshTokenReused) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("刷新令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败，请重试"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": pair})
+	}
+}
+
+// 登出（需认证）：吊销当前访问令牌，并可选吊销刷新令牌所在家族
+func logoutHandler(tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		jti := c.GetString("jti")
+		exp, _ := c.Get("tokenExp")
+
+		var input struct {
+			RefreshToken string `json:"refresh_token"` // 可选
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := tokens.revokeAccessToken(jti, exp.(time.Time)); err != nil {
+			logger.Printf("吊销访问令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败，请重试"})
+			return
+		}
+
+		if input.RefreshToken != "" {
+			if err := tokens.revokeRefreshToken(userId.(uint), input.RefreshToken); err != nil && !errors.Is(err, errRefreshTokenInvalid) {
+				logger.Printf("吊销刷新令牌失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败，请重试"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// accessJTI 取出访问令牌中的jti
+func accessJTI(t *testing.T, token string) string {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return jwtSecret, nil }); err != nil {
+		t.Fatalf("解析访问令牌失败: %v", err)
+	}
+	jti, _ := claims["jti"].(string)
+	return jti
+}
+
+func TestTokenStoreRotate(t *testing.T) {
+	store := newTokenStore(newTestDB(t))
+	first, err := store.issue(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.rotate(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" || second.TokenType != "Bearer" {
+		t.Fatalf("轮换后的令牌对为%+v", second)
+	}
+	other, err := store.issue(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复使用已轮换的刷新令牌：整个家族被吊销，包括刚签发的令牌
+	steps := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"重复使用旧令牌", first.RefreshToken, errRefreshTokenReused},
+		{"家族中的新令牌也被吊销", second.RefreshToken, errRefreshTokenReused},
+		{"未知令牌", "unknown-token", errRefreshTokenInvalid},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if _, err := store.rotate(step.token); !errors.Is(err, step.err) {
+				t.Fatalf("轮换返回%v，期望%v", err, step.err)
+			}
+		})
+	}
+
+	// 家族内的访问令牌进入吊销列表，其他登录会话不受影响
+	tests := []struct {
+		name    string
+		token   string
+		revoked bool
+	}{
+		{"第一次签发", first.AccessToken, true},
+		{"轮换签发", second.AccessToken, true},
+		{"其他会话", other.AccessToken, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if revoked, err := store.isRevoked(accessJTI(t, tt.token)); err != nil || revoked != tt.revoked {
+				t.Fatalf("revoked=%v err=%v，期望%v", revoked, err, tt.revoked)
+			}
+		})
+	}
+	if _, err := store.rotate(other.RefreshToken); err != nil {
+		t.Fatalf("其他会话轮换失败: %v", err)
+	}
+}
+
+func TestTokenStoreExpiredRefreshToken(t *testing.T) {
+	db := newTestDB(t)
+	store := newTokenStore(db)
+	pair, err := store.issue(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&RefreshToken{}).Where("token_hash = ?", hashToken(pair.RefreshToken)).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := store.rotate(pair.RefreshToken); !errors.Is(err, errRefreshTokenInvalid) {
+		t.Fatalf("过期令牌轮换返回%v", err)
+	}
+}
+
+func TestTokenStoreRotateIssueFailure(t *testing.T) {
+	db := newTestDB(t)
+	store := newTokenStore(db)
+	pair, err := store.issue(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 签发失败时旧刷新令牌不能被标记为已使用，否则用户会被登出
+	if err := db.Migrator().DropTable(&UserRole{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.rotate(pair.RefreshToken); err == nil {
+		t.Fatal("签发失败时轮换成功")
+	}
+	if err := db.AutoMigrate(&UserRole{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.rotate(pair.RefreshToken); err != nil {
+		t.Fatalf("签发失败后旧令牌无法再轮换: %v", err)
+	}
+}
+
+func TestTokenStoreRevokeRefreshToken(t *testing.T) {
+	store := newTokenStore(newTestDB(t))
+	pair, err := store.issue(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.revokeRefreshToken(2, pair.RefreshToken); !errors.Is(err, errRefreshTokenInvalid) {
+		t.Fatalf("吊销他人的刷新令牌返回%v", err)
+	}
+	if err := store.revokeRefreshToken(1, pair.RefreshToken); err != nil {
+		t.Fatalf("吊销失败: %v", err)
+	}
+	if _, err := store.rotate(pair.RefreshToken); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("吊销后轮换返回%v", err)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	store := newTokenStore(newTestDB(t))
+	r := gin.New()
+	r.GET("/", authMiddleware(store), func(c *gin.Context) {
+		userID, _ := c.Get("userId")
+		c.JSON(http.StatusOK, gin.H{"user_id": userID})
+	})
+
+	valid, err := store.issue(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := store.issue(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.revokeAccessToken(accessJTI(t, revoked.AccessToken), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	sign := func(secret string, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"缺少Authorization头", "", http.StatusUnauthorized},
+		{"格式错误", "Token " + valid.AccessToken, http.StatusUnauthorized},
+		{"伪造签名", "Bearer " + sign("other-secret", jwt.MapClaims{"sub": 7, "jti": "x", "typ": "access", "exp": exp}), http.StatusUnauthorized},
+		{"已过期", "Bearer " + sign(testJWTSecret, jwt.MapClaims{"sub": 7, "jti": "x", "typ": "access", "exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		{"不是访问令牌", "Bearer " + sign(testJWTSecret, jwt.MapClaims{"sub": 7, "jti": "x", "exp": exp}), http.StatusUnauthorized},
+		{"已吊销", "Bearer " + revoked.AccessToken, http.StatusUnauthorized},
+		{"有效", "Bearer " + valid.AccessToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("状态码为%d，期望%d，响应: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != `{"user_id":7}` {
+				t.Fatalf("响应为%s", w.Body.String())
+			}
+		})
+	}
+}
+
+// refresh 用刷新令牌换取新的令牌对
+func (a *testApp) refresh(refreshToken string) (*httptest.ResponseRecorder, tokenPair) {
+	a.t.Helper()
+	var resp struct {
+		Data tokenPair `json:"data"`
+	}
+	w := a.request(http.MethodPost, "/api/public/auth/refresh", gin.H{"refresh_token": refreshToken}, "")
+	if w.Code == http.StatusOK {
+		expect(a.t, w, http.StatusOK, &resp)
+	}
+	return w, resp.Data
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	first := app.login("alice")
+
+	w, second := app.refresh(first.RefreshToken)
+	expect(t, w, http.StatusOK, nil)
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatal("刷新后应返回新的令牌对")
+	}
+	if !app.authenticated(second.AccessToken) {
+		t.Fatal("新的访问令牌无法通过认证")
+	}
+
+	// 重复使用已轮换的刷新令牌：整个家族被吊销，包括刚签发的令牌
+	w, _ = app.refresh(first.RefreshToken)
+	expect(t, w, http.StatusUnauthorized, nil)
+	w, _ = app.refresh(second.RefreshToken)
+	expect(t, w, http.StatusUnauthorized, nil)
+	if app.authenticated(second.AccessToken) {
+		t.Fatal("家族被吊销后访问令牌仍然有效")
+	}
+
+	// 其他登录会话不受影响
+	other := app.login("alice")
+	if !app.authenticated(other.AccessToken) {
+		t.Fatal("其他会话的访问令牌失效")
+	}
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	expired := app.login("alice")
+	app.db.Model(&RefreshToken{}).Where("token_hash = ?", hashToken(expired.RefreshToken)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"缺少令牌", "", http.StatusBadRequest},
+		{"未知令牌", "unknown-token", http.StatusUnauthorized},
+		{"已过期", expired.RefreshToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := app.refresh(tt.token)
+			expect(t, w, tt.status, nil)
+		})
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	pair := app.login("alice")
+
+	w := app.request(http.MethodPost, "/api/protected/auth/logout", gin.H{"refresh_token": pair.RefreshToken}, pair.AccessToken)
+	expect(t, w, http.StatusOK, nil)
+
+	if app.authenticated(pair.AccessToken) {
+		t.Fatal("登出后访问令牌仍然有效")
+	}
+	w, _ = app.refresh(pair.RefreshToken)
+	expect(t, w, http.StatusUnauthorized, nil)
+}
+
+func TestRevokeJTIIsIdempotent(t *testing.T) {
+	app := newTestApp(t)
+	expires := time.Now().Add(time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- revokeJTI(app.db, "0123456789abcdef0123456789abcdef", expires)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("重复吊销同一令牌失败: %v", err)
+		}
+	}
+	if revoked, err := app.tokens.isRevoked("0123456789abcdef0123456789abcdef"); err != nil || !revoked {
+		t.Fatalf("revoked=%v err=%v", revoked, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 评论编辑与审核 ===
+// 作者只能在发布后的一段时间内编辑评论；删除均为软删除并记录操作人，
+// 版主可查看已删除的评论并按需恢复。
+
+var commentEditWindow = 15 * time.Minute // 作者可编辑评论的时限（由配置加载）
+
+// canEditComment 评论是否仍在作者可编辑的时限内
+func canEditComment(comment *Comment, now time.Time) bool {
+	return now.Sub(comment.CreatedAt) <= commentEditWindow
+}
+
+// 已删除评论列表（需comment:delete_any权限）
+// 查询参数：limit、cursor，按删除时间倒序
+func listDeletedCommentsHandler(comments CommentRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := parsePageQuery(c, true, sortDeletedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		list, page, err := comments.ListDeleted(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询已删除评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评论失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list, "pagination": page})
+	}
+}
+
+// 恢复已删除的评论（需comment:delete_any权限；随文章删除的评论不能单独恢复）
+func restoreCommentHandler(comments CommentRepository, index *searchIndex, events *eventBus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		commentID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		comment, err := comments.Restore(commentID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在、未被删除或所属文章已删除"})
+				return
+			}
+			logger.Printf("恢复评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复评论失败"})
+			return
+		}
+		index.IndexComment(comment)
+		events.Publish(eventCommentUpdated, CommentEvent{Comment: comment, ActorID: c.GetUint("userId")})
+
+		c.JSON(http.StatusOK, gin.H{"data": comment})
+	}
+}
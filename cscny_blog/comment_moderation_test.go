@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanEditComment(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		created time.Time
+		want    bool
+	}{
+		{"刚发布", now, true},
+		{"恰好到时限", now.Add(-commentEditWindow), true},
+		{"超过时限", now.Add(-commentEditWindow - time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := &Comment{}
+			comment.CreatedAt = tt.created
+			if got := canEditComment(comment, now); got != tt.want {
+				t.Fatalf("canEditComment = %v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCommentPermissions(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	_, moderator := app.newUser("moderator", roleModerator)
+	post := app.createPost(author, nil)
+
+	fresh := app.createComment(author, post.ID, 0, "刚发布的评论")
+	expired := app.createComment(author, post.ID, 0, "很久以前的评论")
+	app.db.Model(&Comment{}).Where("id = ?", expired.ID).Update("created_at", time.Now().Add(-commentEditWindow-time.Minute))
+
+	tests := []struct {
+		name      string
+		commentID uint
+		token     string
+		status    int
+	}{
+		{"其他用户", fresh.ID, stranger, http.StatusForbidden},
+		{"作者在时限内", fresh.ID, author, http.StatusOK},
+		{"作者超过时限", expired.ID, author, http.StatusForbidden},
+		{"版主不受时限限制", expired.ID, moderator, http.StatusOK},
+		{"评论不存在", 9999, author, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Data Comment `json:"data"`
+			}
+			w := app.request(http.MethodPut, fmt.Sprintf("/api/protected/comments/%d", tt.commentID), gin.H{"content": "修改后的评论"}, tt.token)
+			expect(t, w, tt.status, &resp)
+			if tt.status == http.StatusOK && (resp.Data.Content != "修改后的评论" || resp.Data.EditedAt == nil) {
+				t.Fatalf("编辑结果为%+v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestDeleteCommentPermissions(t *testing.T) {
+	app := newTestApp(t)
+	_, postAuthor := app.newUser("author")
+	_, commenter := app.newUser("commenter")
+	_, stranger := app.newUser("stranger")
+	_, moderator := app.newUser("moderator", roleModerator)
+	post := app.createPost(postAuthor, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"其他用户", stranger, http.StatusForbidden},
+		{"评论作者", commenter, http.StatusOK},
+		{"文章作者", postAuthor, http.StatusOK},
+		{"版主", moderator, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := app.createComment(commenter, post.ID, 0, "待删除的评论")
+			expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/comments/%d", comment.ID), nil, tt.token), tt.status, nil)
+		})
+	}
+}
+
+func TestDeletedCommentModeration(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	moderatorID, moderator := app.newUser("moderator", roleModerator)
+	post := app.createPost(author, nil)
+	root := app.createComment(author, post.ID, 0, "违规内容")
+	app.createComment(author, post.ID, root.ID, "回复")
+
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/comments/%d", root.ID), nil, moderator), http.StatusOK, nil)
+
+	// 公开列表中显示为占位符，不暴露内容和操作人
+	w := app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments", post.ID), nil, "")
+	var tree listResponse[Comment]
+	expect(t, w, http.StatusOK, &tree)
+	if body := w.Body.String(); strings.Contains(body, "违规内容") || strings.Contains(body, "deleted_by") {
+		t.Fatalf("占位符泄露了信息: %s", body)
+	}
+	if len(tree.Data) != 1 || !tree.Data[0].Deleted || len(tree.Data[0].Replies) != 1 {
+		t.Fatalf("评论树为%+v", tree.Data)
+	}
+
+	// 审核列表需要comment:delete_any权限，可以看到原内容和操作人
+	expect(t, app.request(http.MethodGet, "/api/protected/moderation/comments/deleted", nil, author), http.StatusForbidden, nil)
+	var deleted listResponse[Comment]
+	expect(t, app.request(http.MethodGet, "/api/protected/moderation/comments/deleted", nil, moderator), http.StatusOK, &deleted)
+	if len(deleted.Data) != 1 || deleted.Data[0].Content != "违规内容" ||
+		deleted.Data[0].DeletedByID == nil || *deleted.Data[0].DeletedByID != moderatorID {
+		t.Fatalf("已删除评论为%+v", deleted.Data)
+	}
+
+	restorePath := fmt.Sprintf("/api/protected/moderation/comments/%d/restore", root.ID)
+	expect(t, app.request(http.MethodPost, restorePath, nil, moderator), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPost, restorePath, nil, moderator), http.StatusNotFound, nil)
+
+	var restored listResponse[Comment]
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments", post.ID), nil, ""), http.StatusOK, &restored)
+	if restored.Data[0].Deleted || restored.Data[0].Content != "违规内容" {
+		t.Fatalf("恢复后的评论为%+v", restored.Data[0])
+	}
+}
+
+func TestDeletedPostCommentsNotRestorable(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, moderator := app.newUser("moderator", roleModerator)
+	live := app.createPost(author, nil)
+	removed := app.createPost(author, nil)
+	kept := app.createComment(author, live.ID, 0, "正常删除的评论")
+	cascaded := app.createComment(author, removed.ID, 0, "随文章删除的评论")
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/comments/%d", kept.ID), nil, moderator), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/posts/%d", removed.ID), nil, author), http.StatusOK, nil)
+
+	// 审核列表只有所属文章仍存在的评论
+	var deleted listResponse[Comment]
+	expect(t, app.request(http.MethodGet, "/api/protected/moderation/comments/deleted", nil, moderator), http.StatusOK, &deleted)
+	if len(deleted.Data) != 1 || deleted.Data[0].ID != kept.ID {
+		t.Fatalf("已删除评论为%+v", deleted.Data)
+	}
+
+	// 随文章删除的评论不能单独恢复，也不会重新进入搜索结果
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/moderation/comments/%d/restore", cascaded.ID), nil, moderator), http.StatusNotFound, nil)
+	var search struct {
+		Total int `json:"total"`
+	}
+	expect(t, app.request(http.MethodGet, "/api/public/search?type=comment&q="+url.QueryEscape("随文章删除"), nil, ""), http.StatusOK, &search)
+	if search.Total != 0 {
+		t.Fatalf("搜索到%d条已删除文章下的评论", search.Total)
+	}
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/moderation/comments/%d/restore", kept.ID), nil, moderator), http.StatusOK, nil)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 评论实时推送（Server-Sent Events） ===
+// 客户端订阅 /api/public/posts/:id/comments/stream，评论创建、编辑、删除时收到对应事件。
+// 事件ID全局递增，每篇文章保留最近的事件供断线重连时按Last-Event-ID补发；
+// 每个连接的缓冲区有上限，写满说明客户端过慢，直接断开，由客户端重连后补发，不阻塞发布者。
+
+const (
+	streamClientBuffer  = 32               // 每个连接最多缓冲的事件数
+	streamHistorySize   = 100              // 每篇文章保留的最近事件数（用于重连补发）
+	streamHistoryTTL    = 10 * time.Minute // 没有新事件且无人订阅的文章，超过此时间后丢弃历史
+	streamRetryInterval = 3 * time.Second  // 建议客户端的重连间隔
+)
+
+// streamHeartbeatInterval 心跳间隔，防止代理因连接空闲而断开
+var streamHeartbeatInterval = 15 * time.Second
+
+// SSE事件类型
+const (
+	streamCommentCreated = "comment.created"
+	streamCommentEdited  = "comment.edited"
+	streamCommentDeleted = "comment.deleted"
+	streamReset          = "reset" // 无法补发错过的事件，客户端应重新加载评论
+)
+
+// streamEvent 推送给客户端的事件
+type streamEvent struct {
+	ID   uint64
+	Type string
+	Data []byte // JSON
+}
+
+// streamClient 一个SSE连接
+type streamClient struct {
+	events chan streamEvent // 缓冲区写满时由hub关闭
+}
+
+// postStream 一篇文章的订阅者和最近事件
+type postStream struct {
+	clients map[*streamClient]struct{}
+	history []streamEvent
+	dropped uint64    // 已从历史中淘汰的最大事件ID
+	touched time.Time // 最后一次发布事件的时间
+}
+
+// commentHub 按文章分发评论事件（并发安全）
+type commentHub struct {
+	comments CommentRepository
+
+	mu      sync.Mutex
+	lastID  uint64
+	pruned  uint64 // 已整体清理的文章中最大的事件ID
+	streams map[uint]*postStream
+}
+
+func newCommentHub(comments CommentRepository) *commentHub {
+	return &commentHub{comments: comments, streams: make(map[uint]*postStream)}
+}
+
+// Subscribe 订阅评论的创建、编辑、删除事件
+func (h *commentHub) Subscribe(bus *eventBus) {
+	bus.Subscribe(eventCommentCreated, func(payload interface{}) {
+		h.publishComment(streamCommentCreated, payload.(CommentEvent).Comment)
+	})
+	bus.Subscribe(eventCommentUpdated, func(payload interface{}) {
+		h.publishComment(streamCommentEdited, payload.(CommentEvent).Comment)
+	})
+	bus.Subscribe(eventCommentDeleted, func(payload interface{}) {
+		comment := payload.(CommentEvent).Comment
+		h.publish(comment.PostID, streamCommentDeleted, gin.H{"id": comment.ID, "post_id": comment.PostID})
+	})
+}
+
+// publishComment 推送评论（含评论者信息）
+func (h *commentHub) publishComment(eventType string, comment *Comment) {
+	if comment.User.ID == 0 {
+		if loaded, err := h.comments.FindWithAuthor(comment.ID); err == nil {
+			comment = loaded
+		}
+	}
+	h.publish(comment.PostID, eventType, comment)
+}
+
+// publish 编号并分发事件；不等待任何客户端
+func (h *commentHub) publish(postID uint, eventType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Printf("序列化推送事件失败: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	event := streamEvent{ID: h.lastID, Type: eventType, Data: data}
+
+	stream := h.stream(postID)
+	stream.touched = time.Now()
+	stream.history = append(stream.history, event)
+	if len(stream.history) > streamHistorySize {
+		stream.dropped = stream.history[0].ID
+		stream.history = stream.history[1:]
+	}
+	for client := range stream.clients {
+		select {
+		case client.events <- event:
+		default: // 客户端过慢：断开连接，重连后从历史补发
+			delete(stream.clients, client)
+			close(client.events)
+		}
+	}
+}
+
+// stream 获取或创建文章的订阅状态（调用方持有锁）
+func (h *commentHub) stream(postID uint) *postStream {
+	stream, ok := h.streams[postID]
+	if !ok {
+		stream = &postStream{clients: make(map[*streamClient]struct{}), touched: time.Now()}
+		h.streams[postID] = stream
+	}
+	return stream
+}
+
+// join 注册连接，并返回lastEventID之后错过的事件；
+// 错过的事件已不在历史中（或服务已重启）时只返回一个reset事件
+func (h *commentHub) join(postID uint, lastEventID uint64) (client *streamClient, missed []streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := h.stream(postID)
+	client = &streamClient{events: make(chan streamEvent, streamClientBuffer)}
+	stream.clients[client] = struct{}{}
+
+	switch {
+	case lastEventID == 0:
+		return client, nil
+	case lastEventID > h.lastID, // 事件ID来自重启前
+		lastEventID < stream.dropped,                       // 错过的事件已被淘汰
+		len(stream.history) == 0 && lastEventID < h.pruned: // 文章的历史可能已被整体清理
+		// 携带当前事件ID，客户端下次重连时从这里开始
+		return client, []streamEvent{{ID: h.lastID, Type: streamReset, Data: []byte("{}")}}
+	}
+	for i, event := range stream.history {
+		if event.ID > lastEventID {
+			missed = append(missed, stream.history[i:]...)
+			break
+		}
+	}
+	return client, missed
+}
+
+// leave 注销连接（连接可能已因过慢被移除）
+func (h *commentHub) leave(postID uint, client *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if stream, ok := h.streams[postID]; ok {
+		if _, ok := stream.clients[client]; ok {
+			delete(stream.clients, client)
+			close(client.events)
+		}
+	}
+}
+
+// prune 丢弃长时间没有新事件且无人订阅的文章
+func (h *commentHub) prune(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for postID, stream := range h.streams {
+		if len(stream.clients) == 0 && now.Sub(stream.touched) > streamHistoryTTL {
+			if n := len(stream.history); n > 0 && stream.history[n-1].ID > h.pruned {
+				h.pruned = stream.history[n-1].ID
+			}
+			delete(h.streams, postID)
+		}
+	}
+}
+
+// pruneLoop 定期清理，随服务进程一直运行
+func (h *commentHub) pruneLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		h.prune(now)
+	}
+}
+
+// writeStreamEvent 按SSE格式写出事件
+func writeStreamEvent(w gin.ResponseWriter, event streamEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+// 文章评论的实时事件流（无需认证，可见性规则同文章详情）
+// 断线重连时浏览器会自动携带Last-Event-ID请求头，也可用?last_event_id=指定
+func streamCommentsHandler(posts PostRepository, hub *commentHub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+				return
+			}
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+		if !canViewPost(c, post) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return
+		}
+
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		var lastEventID uint64
+		if lastID != "" {
+			if lastEventID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的Last-Event-ID"})
+				return
+			}
+		}
+
+		client, missed := hub.join(post.ID, lastEventID)
+		defer hub.leave(post.ID, client)
+
+		w := c.Writer
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // 禁止nginx缓冲
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetryInterval.Milliseconds())
+		for _, event := range missed {
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+		w.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case event, ok := <-client.events:
+				if !ok {
+					return // 客户端过慢被断开
+				}
+				if err := writeStreamEvent(w, event); err != nil {
+					return
+				}
+				w.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				w.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publishN 向文章发布n个事件
+func publishN(h *commentHub, postID uint, n int) {
+	for i := 0; i < n; i++ {
+		h.publish(postID, streamCommentCreated, gin.H{"n": i})
+	}
+}
+
+func TestCommentHubJoin(t *testing.T) {
+	// 期望补发count个事件，第一个的ID为first；reset表示只收到一个reset事件
+	tests := []struct {
+		name        string
+		setup       func(h *commentHub)
+		lastEventID uint64
+		first       uint64
+		count       int
+		reset       bool
+	}{
+		{"首次连接", func(h *commentHub) { publishN(h, 1, 3) }, 0, 0, 0, false},
+		{"补发错过的事件", func(h *commentHub) { publishN(h, 1, 3) }, 1, 2, 2, false},
+		{"只补发本文章的事件", func(h *commentHub) { publishN(h, 1, 2); publishN(h, 2, 1); publishN(h, 1, 1) }, 2, 4, 1, false},
+		{"没有错过的事件", func(h *commentHub) { publishN(h, 1, 3) }, 3, 0, 0, false},
+		{"事件ID来自重启前", func(h *commentHub) { publishN(h, 1, 3) }, 99, 0, 0, true},
+		{"错过的事件已淘汰", func(h *commentHub) { publishN(h, 1, streamHistorySize+2) }, 1, 0, 0, true},
+		{"刚好未淘汰", func(h *commentHub) { publishN(h, 1, streamHistorySize+2) }, 2, 3, streamHistorySize, false},
+		{"历史已被清理", func(h *commentHub) {
+			publishN(h, 1, 2)
+			h.prune(time.Now().Add(streamHistoryTTL + time.Second))
+		}, 1, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newCommentHub(nil)
+			tt.setup(h)
+			client, missed := h.join(1, tt.lastEventID)
+			defer h.leave(1, client)
+			if tt.reset {
+				// reset事件携带当前事件ID，供下次重连使用
+				if len(missed) != 1 || missed[0].Type != streamReset || missed[0].ID != h.lastID {
+					t.Fatalf("补发事件为%+v，期望reset", missed)
+				}
+				return
+			}
+			if len(missed) != tt.count {
+				t.Fatalf("补发了%d个事件，期望%d个", len(missed), tt.count)
+			}
+			for i, event := range missed {
+				if i == 0 && event.ID != tt.first || i > 0 && event.ID <= missed[i-1].ID {
+					t.Fatalf("补发事件ID为%d（第%d个）", event.ID, i)
+				}
+			}
+		})
+	}
+}
+
+func TestCommentHubSlowClient(t *testing.T) {
+	h := newCommentHub(nil)
+	slow, _ := h.join(1, 0)
+	fast, _ := h.join(1, 0)
+	other, _ := h.join(2, 0)
+
+	// 快的客户端及时读取，慢的客户端缓冲区写满后被断开
+	received := 0
+	for i := 0; i < streamClientBuffer+1; i++ {
+		publishN(h, 1, 1)
+		<-fast.events
+		received++
+	}
+	for range slow.events {
+	}
+	if _, ok := h.streams[1].clients[slow]; ok {
+		t.Fatal("过慢的连接未被移除")
+	}
+	if received != streamClientBuffer+1 {
+		t.Fatalf("快的客户端收到%d个事件", received)
+	}
+	if len(other.events) != 0 {
+		t.Fatal("其他文章的连接收到了事件")
+	}
+
+	// 已被移除的连接再离开不会重复关闭
+	h.leave(1, slow)
+	h.leave(1, fast)
+	h.leave(2, other)
+	if _, ok := <-fast.events; ok {
+		t.Fatal("离开后连接未关闭")
+	}
+}
+
+func TestCommentHubPrune(t *testing.T) {
+	h := newCommentHub(nil)
+	publishN(h, 1, 1)
+	publishN(h, 2, 1)
+	client, _ := h.join(2, 0)
+	defer h.leave(2, client)
+
+	h.prune(time.Now())
+	if len(h.streams) != 2 {
+		t.Fatalf("未过期时清理了文章，剩余%d篇", len(h.streams))
+	}
+	// 有人订阅的文章不清理
+	h.prune(time.Now().Add(streamHistoryTTL + time.Second))
+	if _, ok := h.streams[1]; ok || len(h.streams) != 1 || h.pruned != 1 {
+		t.Fatalf("清理后剩余%d篇，pruned=%d", len(h.streams), h.pruned)
+	}
+}
+
+// readStreamEvents 从SSE连接中读取事件类型，直到收到n个事件
+func readStreamEvents(t *testing.T, scanner *bufio.Scanner, n int) []string {
+	t.Helper()
+	var events []string
+	for len(events) < n && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("只收到%v: %v", events, scanner.Err())
+	}
+	return events
+}
+
+func TestStreamCommentsHandler(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	post := app.createPost(alice, nil)
+	draft := app.createPost(alice, gin.H{"status": postStatusDraft})
+	path := fmt.Sprintf("/api/public/posts/%d/comments/stream", post.ID)
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		status int
+	}{
+		{"文章不存在", "/api/public/posts/9999/comments/stream", nil, http.StatusNotFound},
+		{"草稿不可订阅", fmt.Sprintf("/api/public/posts/%d/comments/stream", draft.ID), nil, http.StatusNotFound},
+		{"无效的Last-Event-ID", path, map[string]string{"Last-Event-ID": "abc"}, http.StatusBadRequest},
+		{"无效的last_event_id参数", path + "?last_event_id=-1", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.getFeed(tt.path, tt.header), tt.status, nil)
+		})
+	}
+
+	server := httptest.NewServer(app.router)
+	defer server.Close()
+	connect := func(lastEventID string) *bufio.Scanner {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("状态码为%d，Content-Type为%s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), "retry: ") {
+			t.Fatalf("首行为%q", scanner.Text())
+		}
+		return scanner
+	}
+
+	// 评论的创建、编辑、删除实时推送
+	live := connect("")
+	comment := app.createComment(alice, post.ID, 0, "第一条评论")
+	editPath := fmt.Sprintf("/api/protected/comments/%d", comment.ID)
+	expect(t, app.request(http.MethodPut, editPath, gin.H{"content": "编辑后的评论"}, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, editPath, nil, alice), http.StatusOK, nil)
+	want := []string{streamCommentCreated, streamCommentEdited, streamCommentDeleted}
+	if got := readStreamEvents(t, live, 3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("收到事件%v，期望%v", got, want)
+	}
+
+	// 断线重连时补发错过的事件，过期的ID则要求重新加载
+	if got := readStreamEvents(t, connect("1"), 2); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("重连后收到%v，期望%v", got, want[1:])
+	}
+	if got := readStreamEvents(t, connect("99"), 1); got[0] != streamReset {
+		t.Fatalf("重连后收到%v", got)
+	}
+}
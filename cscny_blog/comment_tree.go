@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// === 评论楼中楼 ===
+// 每条评论记录父评论、所在楼层的根评论、深度和物化路径（各级ID补零后以"/"连接），
+// 按path排序即为深度优先的展示顺序，一次查询即可取出整棵评论树。
+
+const (
+	deletedCommentPlaceholder = "[deleted]"
+	commentPathSegmentFormat  = "%010d"
+	maxCommentDepthLimit      = 20 // path列长度限制下允许配置的最大深度
+)
+
+var maxCommentDepth = 5 // 回复最大深度（根评论深度为0，由配置加载）
+
+// commentPath 由父评论路径和自身ID生成物化路径
+func commentPath(parentPath string, id uint) string {
+	segment := fmt.Sprintf(commentPathSegmentFormat, id)
+	if parentPath == "" {
+		return segment
+	}
+	return parentPath + "/" + segment
+}
+
+// maskDeletedComment 已删除但仍有回复的评论显示为占位符，隐藏内容、作者和操作人
+func maskDeletedComment(c *Comment) {
+	c.Content = deletedCommentPlaceholder
+	c.UserID = 0
+	c.User = User{}
+	c.DeletedByID = nil // 操作人仅对版主可见（见ListDeleted）
+	c.Reactions, c.MyReactions = nil, nil
+	c.Deleted = true
+}
+
+// buildCommentTree 将按path排序的扁平评论（含已软删除的）组装为树：
+// 已删除且没有存活回复的评论被剪除，其余已删除评论显示为占位符
+func buildCommentTree(flat []Comment) []Comment {
+	byID := make(map[uint]int, len(flat))
+	for i := range flat {
+		byID[flat[i].ID] = i
+	}
+
+	children := make(map[uint][]int)
+	var roots []int
+	for i := range flat {
+		if parentID := flat[i].ParentID; parentID != nil {
+			if _, ok := byID[*parentID]; ok {
+				children[*parentID] = append(children[*parentID], i)
+				continue
+			}
+		}
+		roots = append(roots, i) // 根评论，或父评论不在本次结果中
+	}
+
+	var build func(i int) (Comment, bool)
+	build = func(i int) (Comment, bool) {
+		node := flat[i]
+		node.Replies = []Comment{}
+		for _, child := range children[node.ID] {
+			if reply, ok := build(child); ok {
+				node.Replies = append(node.Replies, reply)
+			}
+		}
+		if node.DeletedAt.Valid {
+			if len(node.Replies) == 0 {
+				return Comment{}, false
+			}
+			maskDeletedComment(&node)
+		}
+		return node, true
+	}
+
+	tree := make([]Comment, 0, len(roots))
+	for _, i := range roots {
+		if node, ok := build(i); ok {
+			tree = append(tree, node)
+		}
+	}
+	return tree
+}
+
+// flattenCommentTree 按展示顺序展开评论树（每条评论带depth和path，不再嵌套replies）
+func flattenCommentTree(tree []Comment) []Comment {
+	var flat []Comment
+	var walk func(nodes []Comment)
+	walk = func(nodes []Comment) {
+		for _, node := range nodes {
+			replies := node.Replies
+			node.Replies = nil
+			flat = append(flat, node)
+			walk(replies)
+		}
+	}
+	walk(tree)
+	if flat == nil {
+		flat = []Comment{}
+	}
+	return flat
+}
+
+// backfillCommentPaths 为升级前创建的评论补全path和root_id（均视为根评论）
+func backfillCommentPaths(db *gorm.DB) error {
+	var batch []Comment
+	return db.Unscoped().Where("path = '' OR path IS NULL").FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+		for _, c := range batch {
+			if err := tx.Model(&Comment{}).Unscoped().Where("id = ?", c.ID).
+				Updates(map[string]interface{}{"path": commentPath("", c.ID), "root_id": c.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// treeComment 构造评论树测试用的评论；parentID为0表示根评论
+func treeComment(id, parentID uint, deleted bool) Comment {
+	c := Comment{Model: gorm.Model{ID: id}, Content: fmt.Sprintf("评论%d", id), UserID: 1}
+	if parentID != 0 {
+		c.ParentID = &parentID
+	}
+	if deleted {
+		moderatorID := uint(9)
+		c.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		c.DeletedByID = &moderatorID
+	}
+	return c
+}
+
+// treeShape 将评论树描述为"ID(子节点...)"，占位符以*标记
+func treeShape(nodes []Comment) string {
+	s := ""
+	for i, node := range nodes {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprint(node.ID)
+		if node.Deleted {
+			s += "*"
+		}
+		if len(node.Replies) > 0 {
+			s += "(" + treeShape(node.Replies) + ")"
+		}
+	}
+	return s
+}
+
+func TestCommentPath(t *testing.T) {
+	tests := []struct {
+		parent string
+		id     uint
+		want   string
+	}{
+		{"", 7, "0000000007"},
+		{"0000000007", 12, "0000000007/0000000012"},
+	}
+	for _, tt := range tests {
+		if got := commentPath(tt.parent, tt.id); got != tt.want {
+			t.Fatalf("commentPath(%q, %d) = %q，期望%q", tt.parent, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	tests := []struct {
+		name string
+		flat []Comment
+		want string
+	}{
+		{"正常嵌套", []Comment{treeComment(1, 0, false), treeComment(2, 1, false), treeComment(3, 2, false), treeComment(4, 0, false)}, "1(2(3)) 4"},
+		{"删除的叶子被剪除", []Comment{treeComment(1, 0, false), treeComment(2, 1, true)}, "1"},
+		{"有回复的已删除评论显示为占位符", []Comment{treeComment(1, 0, true), treeComment(2, 1, false)}, "1*(2)"},
+		{"子树全部删除时整体剪除", []Comment{treeComment(1, 0, true), treeComment(2, 1, true), treeComment(3, 2, true), treeComment(4, 0, false)}, "4"},
+		{"中间层删除", []Comment{treeComment(1, 0, false), treeComment(2, 1, true), treeComment(3, 2, false)}, "1(2*(3))"},
+		{"父评论不在结果中时作为根", []Comment{treeComment(5, 3, false)}, "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := treeShape(buildCommentTree(tt.flat)); got != tt.want {
+				t.Fatalf("评论树为%q，期望%q", got, tt.want)
+			}
+		})
+	}
+
+	// 占位符隐藏内容、作者和操作人
+	tree := buildCommentTree([]Comment{treeComment(1, 0, true), treeComment(2, 1, false)})
+	placeholder := tree[0]
+	if placeholder.Content != deletedCommentPlaceholder || placeholder.UserID != 0 || placeholder.DeletedByID != nil {
+		t.Fatalf("占位符泄露了信息: %+v", placeholder)
+	}
+}
+
+func TestFlattenCommentTree(t *testing.T) {
+	tree := buildCommentTree([]Comment{treeComment(1, 0, false), treeComment(2, 1, false), treeComment(3, 0, false)})
+	var ids []uint
+	for _, c := range flattenCommentTree(tree) {
+		if c.Replies != nil {
+			t.Fatalf("展开后评论%d仍带replies", c.ID)
+		}
+		ids = append(ids, c.ID)
+	}
+	if want := []uint{1, 2, 3}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("展开顺序为%v，期望%v", ids, want)
+	}
+	if flat := flattenCommentTree(nil); flat == nil || len(flat) != 0 {
+		t.Fatal("空树应展开为空数组")
+	}
+}
+
+func TestCommentReplies(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Comments.MaxDepth = 2 })
+	_, token := app.newUser("alice")
+	post := app.createPost(token, nil)
+	other := app.createPost(token, nil)
+
+	root := app.createComment(token, post.ID, 0, "根评论")
+	reply := app.createComment(token, post.ID, root.ID, "一级回复")
+	nested := app.createComment(token, post.ID, reply.ID, "二级回复")
+	if nested.Depth != 2 || nested.RootID != root.ID || nested.Path != commentPath(commentPath(root.Path, reply.ID), nested.ID) {
+		t.Fatalf("回复的层级信息有误: depth=%d root=%d path=%s", nested.Depth, nested.RootID, nested.Path)
+	}
+
+	tests := []struct {
+		name     string
+		postID   uint
+		parentID uint
+		status   int
+	}{
+		{"超过最大深度", post.ID, nested.ID, http.StatusBadRequest},
+		{"父评论属于其他文章", other.ID, root.ID, http.StatusBadRequest},
+		{"父评论不存在", post.ID, 9999, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf("/api/protected/posts/%d/comments", tt.postID)
+			expect(t, app.request(http.MethodPost, path, gin.H{"content": "回复", "parent_id": tt.parentID}, token), tt.status, nil)
+		})
+	}
+
+	var tree listResponse[Comment]
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments", post.ID), nil, ""), http.StatusOK, &tree)
+	if got := treeShape(tree.Data); got != fmt.Sprintf("%d(%d(%d))", root.ID, reply.ID, nested.ID) {
+		t.Fatalf("评论树为%s", got)
+	}
+
+	var flat listResponse[Comment]
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments?format=flat", post.ID), nil, ""), http.StatusOK, &flat)
+	if len(flat.Data) != 3 || flat.Data[2].Depth != 2 {
+		t.Fatalf("扁平列表为%+v", flat.Data)
+	}
+
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments?format=xml", post.ID), nil, ""), http.StatusBadRequest, nil)
+}
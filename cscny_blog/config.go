@@ -0,0 +1,616 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// === 配置 ===
+// 优先级（从低到高）：默认值 < 配置文件(YAML/TOML) < 环境变量 < 命令行参数
+
+const minJWTSecretLen = 32 // HS256密钥至少32字节
+
+// weakSecrets 常见弱密钥，命中即拒绝启动
+var weakSecrets = []string{"secret", "changeme", "password", "123456", "jwt_secret", "your-secret-key"}
+
+// Config 博客与CRUD示例服务的完整配置
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Comments  CommentConfig   `yaml:"comments" toml:"comments"`
+	Revision  RevisionConfig  `yaml:"revisions" toml:"revisions"`
+	Uploads   UploadConfig    `yaml:"uploads" toml:"uploads"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	Accounts  AccountConfig   `yaml:"accounts" toml:"accounts"`
+	CRUD      CRUDConfig      `yaml:"crud" toml:"crud"`
+}
+
+// ServerConfig 博客HTTP服务配置
+type ServerConfig struct {
+	Addr    string `yaml:"addr" toml:"addr"`         // 监听地址，如":8080"
+	BaseURL string `yaml:"base_url" toml:"base_url"` // 站点对外地址，如"https://blog.example.com"（订阅源、邮件等生成绝对链接时使用；使用SMTP时必填）
+
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"` // 可信反向代理的IP或网段，只有来自这些地址的X-Forwarded-For才用于识别客户端IP
+}
+
+// DatabaseConfig 数据库连接配置
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" toml:"driver"` // mysql或sqlite
+	DSN    string `yaml:"dsn" toml:"dsn"`       // 连接串（含密码，打印时脱敏）
+}
+
+// AuthConfig 认证相关配置
+type AuthConfig struct {
+	JWTSecret       string   `yaml:"jwt_secret" toml:"jwt_secret"`               // JWT签名密钥
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`   // 访问令牌有效期
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"` // 刷新令牌有效期
+	BcryptCost      int      `yaml:"bcrypt_cost" toml:"bcrypt_cost"`             // bcrypt计算成本
+	AdminUsername   string   `yaml:"admin_username" toml:"admin_username"`       // 启动时授予管理员角色的用户名
+
+	// 登录防暴力破解，见login_guard.go
+	LoginMaxFailures   int      `yaml:"login_max_failures" toml:"login_max_failures"`       // 同一用户名连续失败多少次后锁定
+	LoginIPMaxFailures int      `yaml:"login_ip_max_failures" toml:"login_ip_max_failures"` // 同一IP连续失败多少次后锁定
+	LockoutDuration    Duration `yaml:"lockout_duration" toml:"lockout_duration"`           // 锁定时长
+	LoginFailureWindow Duration `yaml:"login_failure_window" toml:"login_failure_window"`   // 超过此时间没有新的失败则重新计数
+
+	EmailVerifyTTL   Duration `yaml:"email_verify_ttl" toml:"email_verify_ttl"`     // 邮箱验证链接有效期
+	PasswordResetTTL Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"` // 重置密码链接有效期
+}
+
+// CommentConfig 评论相关配置
+type CommentConfig struct {
+	MaxDepth   int      `yaml:"max_depth" toml:"max_depth"`     // 回复最大深度（根评论为0）
+	EditWindow Duration `yaml:"edit_window" toml:"edit_window"` // 作者可编辑评论的时限
+}
+
+// RevisionConfig 文章修订保留策略（均为0表示永久保留全部修订）
+type RevisionConfig struct {
+	MaxPerPost int      `yaml:"max_per_post" toml:"max_per_post"` // 每篇文章最多保留的修订数
+	MaxAge     Duration `yaml:"max_age" toml:"max_age"`           // 修订最长保留时间
+}
+
+// UploadConfig 附件上传配置
+type UploadConfig struct {
+	Dir       string `yaml:"dir" toml:"dir"`                 // 本地存储目录
+	MaxSizeMB int    `yaml:"max_size_mb" toml:"max_size_mb"` // 单个文件大小上限（MB）
+}
+
+// RateLimitConfig 限流配置，见ratelimit.go
+type RateLimitConfig struct {
+	Enabled  bool          `yaml:"enabled" toml:"enabled"`
+	Auth     RateLimitRule `yaml:"auth" toml:"auth"`         // 登录、刷新令牌（按IP）
+	Register RateLimitRule `yaml:"register" toml:"register"` // 注册（按IP）
+	Comment  RateLimitRule `yaml:"comment" toml:"comment"`   // 发表评论（按用户）
+	Write    RateLimitRule `yaml:"write" toml:"write"`       // 其他写操作（按用户）
+}
+
+// RateLimitRule 每个周期内最多允许的请求数（可在周期内集中使用）
+type RateLimitRule struct {
+	Limit  int      `yaml:"limit" toml:"limit"`
+	Period Duration `yaml:"period" toml:"period"`
+}
+
+// MailConfig 邮件发送配置，见mailer.go
+type MailConfig struct {
+	Driver    string     `yaml:"driver" toml:"driver"`         // smtp或outbox
+	From      string     `yaml:"from" toml:"from"`             // 发件人地址
+	OutboxDir string     `yaml:"outbox_dir" toml:"outbox_dir"` // outbox方式写入的目录
+	SMTP      SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"` // 为空表示不认证
+	Password string `yaml:"password" toml:"password"` // 打印时脱敏
+}
+
+// AccountConfig 账号注销配置，见account_data.go
+type AccountConfig struct {
+	DeletionGracePeriod Duration `yaml:"deletion_grace_period" toml:"deletion_grace_period"` // 申请注销后多久执行（期间可撤销，0表示立即执行）
+	DeletionPolicy      string   `yaml:"deletion_policy" toml:"deletion_policy"`             // anonymize（保留文章和评论，作者改为匿名）或delete（删除）
+}
+
+// CRUDConfig CRUD示例服务配置
+type CRUDConfig struct {
+	Addr     string         `yaml:"addr" toml:"addr"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+}
+
+// Duration 支持"15m"、"168h"这类写法的时长，用于配置文件
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// defaultConfig 返回默认配置（JWT密钥没有默认值，必须显式配置）
+func defaultConfig() Config {
+	return Config{
+		Server:   ServerConfig{Addr: ":8080"},
+		Database: DatabaseConfig{Driver: driverMySQL}, // DSN为空时按驱动取默认值
+		Auth: AuthConfig{
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(7 * 24 * time.Hour),
+			BcryptCost:      bcrypt.DefaultCost,
+
+			LoginMaxFailures:   5,
+			LoginIPMaxFailures: 20,
+			LockoutDuration:    Duration(15 * time.Minute),
+			LoginFailureWindow: Duration(time.Hour),
+
+			EmailVerifyTTL:   Duration(24 * time.Hour),
+			PasswordResetTTL: Duration(time.Hour),
+		},
+		Comments: CommentConfig{MaxDepth: 5, EditWindow: Duration(15 * time.Minute)},
+		Revision: RevisionConfig{MaxPerPost: 50},
+		Uploads:  UploadConfig{Dir: "uploads", MaxSizeMB: 10},
+		Mail: MailConfig{
+			Driver:    mailDriverOutbox,
+			From:      "no-reply@localhost",
+			OutboxDir: "outbox",
+			SMTP:      SMTPConfig{Port: 587},
+		},
+		Accounts: AccountConfig{
+			DeletionGracePeriod: Duration(14 * 24 * time.Hour),
+			DeletionPolicy:      deletionPolicyAnonymize,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:  true,
+			Auth:     RateLimitRule{Limit: 10, Period: Duration(time.Minute)},
+			Register: RateLimitRule{Limit: 5, Period: Duration(time.Hour)},
+			Comment:  RateLimitRule{Limit: 10, Period: Duration(time.Minute)},
+			Write:    RateLimitRule{Limit: 60, Period: Duration(time.Minute)},
+		},
+		CRUD: CRUDConfig{
+			Addr:     ":8081", // 使用不同的端口避免与博客系统冲突
+			Database: DatabaseConfig{Driver: driverMySQL},
+		},
+	}
+}
+
+// loadConfig 按优先级合并配置；第二个返回值表示是否只打印配置（--print-config）
+func loadConfig(args []string) (Config, bool, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("blog", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("BLOG_CONFIG"), "配置文件路径（.yaml/.yml/.toml）")
+	printConfig := fs.Bool("print-config", false, "打印生效配置（敏感信息脱敏）后退出")
+	addr := fs.String("addr", "", "博客服务监听地址")
+	baseURLFlag := fs.String("base-url", "", "站点对外地址（为空时按请求推断）")
+	dbDriver := fs.String("db-driver", "", "数据库驱动（mysql/sqlite）")
+	dbDSN := fs.String("db-dsn", "", "数据库连接串")
+	jwtSecretFlag := fs.String("jwt-secret", "", "JWT签名密钥（建议使用环境变量）")
+	accessTTL := fs.Duration("access-token-ttl", 0, "访问令牌有效期")
+	refreshTTL := fs.Duration("refresh-token-ttl", 0, "刷新令牌有效期")
+	bcryptCostFlag := fs.Int("bcrypt-cost", 0, "bcrypt计算成本")
+	adminUsername := fs.String("admin-username", "", "启动时授予管理员角色的用户名")
+	loginMaxFailures := fs.Int("login-max-failures", 0, "同一用户名连续登录失败多少次后锁定")
+	lockoutDuration := fs.Duration("lockout-duration", 0, "登录失败锁定时长")
+	commentMaxDepth := fs.Int("comment-max-depth", 0, "评论回复最大深度")
+	commentEditWindow := fs.Duration("comment-edit-window", 0, "作者可编辑评论的时限")
+	revisionMaxPerPost := fs.Int("revision-max-per-post", 0, "每篇文章最多保留的修订数（0表示不限）")
+	revisionMaxAge := fs.Duration("revision-max-age", 0, "文章修订最长保留时间（0表示不限）")
+	uploadDir := fs.String("upload-dir", "", "附件存储目录")
+	uploadMaxSizeMB := fs.Int("upload-max-size-mb", 0, "单个附件大小上限（MB）")
+	mailDriver := fs.String("mail-driver", "", "邮件发送方式（smtp/outbox）")
+	deletionPolicy := fs.String("deletion-policy", "", "注销账号时文章和评论的处理方式（anonymize/delete）")
+	rateLimitFlag := fs.Bool("rate-limit", true, "启用接口限流")
+	crudAddr := fs.String("crud-addr", "", "CRUD示例服务监听地址")
+	crudDriver := fs.String("crud-db-driver", "", "CRUD示例服务数据库驱动（mysql/sqlite）")
+	crudDSN := fs.String("crud-db-dsn", "", "CRUD示例服务数据库连接串")
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+
+	// 1. 配置文件
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, &cfg); err != nil {
+			return cfg, false, err
+		}
+	}
+
+	// 2. 环境变量
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, false, err
+	}
+
+	// 3. 命令行参数（只覆盖显式传入的参数）
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "base-url":
+			cfg.Server.BaseURL = *baseURLFlag
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "db-dsn":
+			cfg.Database.DSN = *dbDSN
+		case "jwt-secret":
+			cfg.Auth.JWTSecret = *jwtSecretFlag
+		case "access-token-ttl":
+			cfg.Auth.AccessTokenTTL = Duration(*accessTTL)
+		case "refresh-token-ttl":
+			cfg.Auth.RefreshTokenTTL = Duration(*refreshTTL)
+		case "bcrypt-cost":
+			cfg.Auth.BcryptCost = *bcryptCostFlag
+		case "admin-username":
+			cfg.Auth.AdminUsername = *adminUsername
+		case "login-max-failures":
+			cfg.Auth.LoginMaxFailures = *loginMaxFailures
+		case "lockout-duration":
+			cfg.Auth.LockoutDuration = Duration(*lockoutDuration)
+		case "comment-max-depth":
+			cfg.Comments.MaxDepth = *commentMaxDepth
+		case "comment-edit-window":
+			cfg.Comments.EditWindow = Duration(*commentEditWindow)
+		case "revision-max-per-post":
+			cfg.Revision.MaxPerPost = *revisionMaxPerPost
+		case "revision-max-age":
+			cfg.Revision.MaxAge = Duration(*revisionMaxAge)
+		case "upload-dir":
+			cfg.Uploads.Dir = *uploadDir
+		case "upload-max-size-mb":
+			cfg.Uploads.MaxSizeMB = *uploadMaxSizeMB
+		case "mail-driver":
+			cfg.Mail.Driver = *mailDriver
+		case "deletion-policy":
+			cfg.Accounts.DeletionPolicy = *deletionPolicy
+		case "rate-limit":
+			cfg.RateLimit.Enabled = *rateLimitFlag
+		case "crud-addr":
+			cfg.CRUD.Addr = *crudAddr
+		case "crud-db-driver":
+			cfg.CRUD.Database.Driver = *crudDriver
+		case "crud-db-dsn":
+			cfg.CRUD.Database.DSN = *crudDSN
+		}
+	})
+
+	// 4. 未指定连接串时按驱动补全默认值
+	if cfg.Database.DSN == "" {
+		cfg.Database.DSN = defaultDSN(cfg.Database.Driver)
+	}
+	if cfg.CRUD.Database.DSN == "" {
+		cfg.CRUD.Database.DSN = defaultDSN(cfg.CRUD.Database.Driver)
+		if cfg.CRUD.Database.Driver == driverMySQL {
+			cfg.CRUD.Database.DSN = defaultCRUDMySQLDSN
+		}
+	}
+
+	return cfg, *printConfig, nil
+}
+
+// loadConfigFile 按扩展名解析YAML或TOML配置文件
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// applyEnv 用环境变量覆盖配置（兼容原有的JWT_SECRET、PORT等变量名）
+func applyEnv(cfg *Config) error {
+	strVars := map[string]*string{
+		"BLOG_ADDR":               &cfg.Server.Addr,
+		"BLOG_BASE_URL":           &cfg.Server.BaseURL,
+		"DB_DRIVER":               &cfg.Database.Driver,
+		"DB_DSN":                  &cfg.Database.DSN,
+		"JWT_SECRET":              &cfg.Auth.JWTSecret,
+		"ADMIN_USERNAME":          &cfg.Auth.AdminUsername,
+		"UPLOAD_DIR":              &cfg.Uploads.Dir,
+		"MAIL_DRIVER":             &cfg.Mail.Driver,
+		"MAIL_FROM":               &cfg.Mail.From,
+		"MAIL_OUTBOX_DIR":         &cfg.Mail.OutboxDir,
+		"SMTP_HOST":               &cfg.Mail.SMTP.Host,
+		"SMTP_USERNAME":           &cfg.Mail.SMTP.Username,
+		"SMTP_PASSWORD":           &cfg.Mail.SMTP.Password,
+		"ACCOUNT_DELETION_POLICY": &cfg.Accounts.DeletionPolicy,
+		"CRUD_DB_DRIVER":          &cfg.CRUD.Database.Driver,
+		"CRUD_DB_DSN":             &cfg.CRUD.Database.DSN,
+	}
+	for name, target := range strVars {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*target = v
+		}
+	}
+
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = strings.Split(v, ",")
+	}
+	if v := os.Getenv("RATE_LIMIT_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量RATE_LIMIT_ENABLED格式错误: %w", err)
+		}
+		cfg.RateLimit.Enabled = enabled
+	}
+
+	// CRUD示例原先只读取端口号
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.CRUD.Addr = ":" + port
+	}
+
+	durationVars := map[string]*Duration{
+		"ACCESS_TOKEN_TTL":              &cfg.Auth.AccessTokenTTL,
+		"REFRESH_TOKEN_TTL":             &cfg.Auth.RefreshTokenTTL,
+		"COMMENT_EDIT_WINDOW":           &cfg.Comments.EditWindow,
+		"REVISION_MAX_AGE":              &cfg.Revision.MaxAge,
+		"LOCKOUT_DURATION":              &cfg.Auth.LockoutDuration,
+		"LOGIN_FAILURE_WINDOW":          &cfg.Auth.LoginFailureWindow,
+		"EMAIL_VERIFY_TTL":              &cfg.Auth.EmailVerifyTTL,
+		"PASSWORD_RESET_TTL":            &cfg.Auth.PasswordResetTTL,
+		"ACCOUNT_DELETION_GRACE_PERIOD": &cfg.Accounts.DeletionGracePeriod,
+	}
+	for name, target := range durationVars {
+		if v := os.Getenv(name); v != "" {
+			if err := target.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("环境变量%s格式错误: %w", name, err)
+			}
+		}
+	}
+
+	intVars := map[string]*int{
+		"BCRYPT_COST":           &cfg.Auth.BcryptCost,
+		"COMMENT_MAX_DEPTH":     &cfg.Comments.MaxDepth,
+		"REVISION_MAX_PER_POST": &cfg.Revision.MaxPerPost,
+		"UPLOAD_MAX_SIZE_MB":    &cfg.Uploads.MaxSizeMB,
+		"LOGIN_MAX_FAILURES":    &cfg.Auth.LoginMaxFailures,
+		"LOGIN_IP_MAX_FAILURES": &cfg.Auth.LoginIPMaxFailures,
+		"SMTP_PORT":             &cfg.Mail.SMTP.Port,
+	}
+	for name, target := range intVars {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("环境变量%s格式错误: %w", name, err)
+			}
+			*target = n
+		}
+	}
+	return nil
+}
+
+// Validate 校验配置，任何一项不合法都拒绝启动
+func (cfg Config) Validate() error {
+	var errs []error
+
+	secret := cfg.Auth.JWTSecret
+	switch {
+	case secret == "":
+		errs = append(errs, errors.New("未配置JWT密钥（JWT_SECRET），所有token都可被伪造"))
+	case len(secret) < minJWTSecretLen:
+		errs = append(errs, fmt.Errorf("JWT密钥过短，至少需要%d字节", minJWTSecretLen))
+	case isWeakSecret(secret):
+		errs = append(errs, errors.New("JWT密钥过于简单，请使用随机生成的密钥"))
+	}
+
+	if cfg.Auth.AccessTokenTTL <= 0 || cfg.Auth.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("令牌有效期必须大于0"))
+	} else if cfg.Auth.AccessTokenTTL >= cfg.Auth.RefreshTokenTTL {
+		errs = append(errs, errors.New("访问令牌有效期必须短于刷新令牌有效期"))
+	}
+
+	if cfg.Auth.BcryptCost < bcrypt.MinCost || cfg.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt成本必须在%d到%d之间", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	if cfg.Auth.LoginMaxFailures < 1 || cfg.Auth.LoginIPMaxFailures < 1 {
+		errs = append(errs, errors.New("登录失败锁定阈值必须大于0"))
+	}
+	if cfg.Auth.LockoutDuration <= 0 || cfg.Auth.LoginFailureWindow <= 0 {
+		errs = append(errs, errors.New("登录锁定时长和失败计数周期必须大于0"))
+	}
+
+	if cfg.Auth.EmailVerifyTTL <= 0 || cfg.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("邮箱验证和重置密码链接的有效期必须大于0"))
+	}
+
+	if cfg.Comments.MaxDepth < 0 || cfg.Comments.MaxDepth > maxCommentDepthLimit {
+		errs = append(errs, fmt.Errorf("评论最大深度必须在0到%d之间", maxCommentDepthLimit))
+	}
+	if cfg.Comments.EditWindow < 0 {
+		errs = append(errs, errors.New("评论编辑时限不能为负数"))
+	}
+	if cfg.Revision.MaxPerPost < 0 || cfg.Revision.MaxAge < 0 {
+		errs = append(errs, errors.New("修订保留条数和保留时间不能为负数"))
+	}
+
+	if cfg.Uploads.Dir == "" {
+		errs = append(errs, errors.New("未配置附件存储目录"))
+	}
+	if cfg.Uploads.MaxSizeMB < 1 || cfg.Uploads.MaxSizeMB > maxUploadSizeMB {
+		errs = append(errs, fmt.Errorf("附件大小上限必须在1到%dMB之间", maxUploadSizeMB))
+	}
+
+	if cfg.Accounts.DeletionGracePeriod < 0 {
+		errs = append(errs, errors.New("账号注销等待期不能为负数"))
+	}
+	if cfg.Accounts.DeletionPolicy != deletionPolicyAnonymize && cfg.Accounts.DeletionPolicy != deletionPolicyDelete {
+		errs = append(errs, fmt.Errorf("不支持的账号注销策略: %s", cfg.Accounts.DeletionPolicy))
+	}
+
+	if cfg.RateLimit.Enabled {
+		rules := []struct {
+			name string
+			rule RateLimitRule
+		}{{"auth", cfg.RateLimit.Auth}, {"register", cfg.RateLimit.Register}, {"comment", cfg.RateLimit.Comment}, {"write", cfg.RateLimit.Write}}
+		for _, r := range rules {
+			if r.rule.Limit < 1 || r.rule.Period <= 0 {
+				errs = append(errs, fmt.Errorf("限流策略%s的次数和周期必须大于0", r.name))
+			}
+		}
+	}
+
+	if addr, err := mail.ParseAddress(cfg.Mail.From); err != nil || strings.ContainsAny(cfg.Mail.From, "\r\n") {
+		errs = append(errs, fmt.Errorf("发件人地址格式不正确: %s", cfg.Mail.From))
+	} else if addr.Address == "" {
+		errs = append(errs, errors.New("未配置发件人地址"))
+	}
+	switch cfg.Mail.Driver {
+	case mailDriverSMTP:
+		// 邮件中的链接只用配置的站点地址生成，不按请求的Host推断，见mailLink
+		if cfg.Server.BaseURL == "" {
+			errs = append(errs, errors.New("使用SMTP发送邮件时必须配置站点地址server.base_url"))
+		}
+		if cfg.Mail.SMTP.Host == "" {
+			errs = append(errs, errors.New("使用SMTP发送邮件时必须配置服务器地址"))
+		}
+		if cfg.Mail.SMTP.Port < 1 || cfg.Mail.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("SMTP端口不合法: %d", cfg.Mail.SMTP.Port))
+		}
+	case mailDriverOutbox:
+		if cfg.Mail.OutboxDir == "" {
+			errs = append(errs, errors.New("未配置邮件outbox目录"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("不支持的邮件发送方式: %s", cfg.Mail.Driver))
+	}
+
+	if cfg.Server.Addr == "" {
+		errs = append(errs, errors.New("未配置监听地址"))
+	}
+	if cfg.Server.BaseURL != "" {
+		if u, err := url.Parse(cfg.Server.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("站点地址必须为http(s)绝对地址: %s", cfg.Server.BaseURL))
+		}
+	}
+	if cfg.Database.Driver != driverMySQL && cfg.Database.Driver != driverSQLite {
+		errs = append(errs, fmt.Errorf("不支持的数据库驱动: %s", cfg.Database.Driver))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateCRUD 校验CRUD示例服务所需的配置
+func (cfg Config) ValidateCRUD() error {
+	var errs []error
+	if cfg.CRUD.Addr == "" {
+		errs = append(errs, errors.New("未配置CRUD服务监听地址"))
+	}
+	if cfg.CRUD.Database.Driver != driverMySQL && cfg.CRUD.Database.Driver != driverSQLite {
+		errs = append(errs, fmt.Errorf("不支持的数据库驱动: %s", cfg.CRUD.Database.Driver))
+	}
+	return errors.Join(errs...)
+}
+
+// isWeakSecret 判断密钥是否包含常见弱口令，或字符种类过少（如"aaaa..."、"abab..."）
+func isWeakSecret(secret string) bool {
+	lower := strings.ToLower(secret)
+	for _, weak := range weakSecrets {
+		if strings.Contains(lower, weak) {
+			return true
+		}
+	}
+	distinct := make(map[rune]struct{})
+	for _, r := range secret {
+		distinct[r] = struct{}{}
+	}
+	return len(distinct) < 8
+}
+
+// Redacted 返回脱敏后的配置副本，用于打印
+func (cfg Config) Redacted() Config {
+	out := cfg
+	if out.Auth.JWTSecret != "" {
+		out.Auth.JWTSecret = "******"
+	}
+	if out.Mail.SMTP.Password != "" {
+		out.Mail.SMTP.Password = "******"
+	}
+	out.Database.DSN = redactDSN(out.Database.DSN)
+	out.CRUD.Database.DSN = redactDSN(out.CRUD.Database.DSN)
+	return out
+}
+
+// redactDSN 隐藏MySQL连接串中的密码（user:password@tcp(...)）
+func redactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + "******" + dsn[at:]
+}
+
+// printConfig 以YAML格式打印脱敏后的生效配置
+func printConfig(cfg Config) error {
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// applyConfig 将配置写入各模块使用的全局变量
+func applyConfig(cfg Config) {
+	jwtSecret = []byte(cfg.Auth.JWTSecret)
+	accessTokenTTL = time.Duration(cfg.Auth.AccessTokenTTL)
+	refreshTokenTTL = time.Duration(cfg.Auth.RefreshTokenTTL)
+	bcryptCost = cfg.Auth.BcryptCost
+	loginMaxFailures = cfg.Auth.LoginMaxFailures
+	loginIPMaxFailures = cfg.Auth.LoginIPMaxFailures
+	loginLockoutDuration = time.Duration(cfg.Auth.LockoutDuration)
+	loginFailureWindow = time.Duration(cfg.Auth.LoginFailureWindow)
+	emailVerifyTTL = time.Duration(cfg.Auth.EmailVerifyTTL)
+	passwordResetTTL = time.Duration(cfg.Auth.PasswordResetTTL)
+	maxCommentDepth = cfg.Comments.MaxDepth
+	commentEditWindow = time.Duration(cfg.Comments.EditWindow)
+	revisionMaxPerPost = cfg.Revision.MaxPerPost
+	revisionMaxAge = time.Duration(cfg.Revision.MaxAge)
+	uploadMaxSize = int64(cfg.Uploads.MaxSizeMB) << 20
+	accountDeletionGracePeriod = time.Duration(cfg.Accounts.DeletionGracePeriod)
+	accountDeletionPolicy = cfg.Accounts.DeletionPolicy
+	rateLimitEnabled = cfg.RateLimit.Enabled
+	authRateLimit = cfg.RateLimit.Auth.policy(authRateLimit.Name)
+	registerRateLimit = cfg.RateLimit.Register.policy(registerRateLimit.Name)
+	commentRateLimit = cfg.RateLimit.Comment.policy(commentRateLimit.Name)
+	writeRateLimit = cfg.RateLimit.Write.policy(writeRateLimit.Name)
+	siteBaseURL = strings.TrimRight(cfg.Server.BaseURL, "/")
+}
+
+// policy 转换为指定名称的限流策略
+func (r RateLimitRule) policy(name string) rateLimitPolicy {
+	return rateLimitPolicy{Name: name, Limit: r.Limit, Period: time.Duration(r.Period)}
+}
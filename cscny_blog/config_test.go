@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile 在临时目录写入配置文件
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", `
+server:
+  addr: ":7000"
+auth:
+  access_token_ttl: 10m
+  bcrypt_cost: 12
+`)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		addr     string
+		accessTT time.Duration
+		cost     int
+	}{
+		{"只有配置文件", nil, nil, ":7000", 10 * time.Minute, 12},
+		{"环境变量覆盖文件", map[string]string{"BLOG_ADDR": ":7100", "ACCESS_TOKEN_TTL": "1m"}, nil, ":7100", time.Minute, 12},
+		{"命令行覆盖环境变量", map[string]string{"BLOG_ADDR": ":7100"}, []string{"-addr", ":7200", "-bcrypt-cost", "11"}, ":7200", 10 * time.Minute, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, printOnly, err := loadConfig(append([]string{"-config", file}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if printOnly {
+				t.Fatal("未传入-print-config")
+			}
+			if cfg.Server.Addr != tt.addr || time.Duration(cfg.Auth.AccessTokenTTL) != tt.accessTT || cfg.Auth.BcryptCost != tt.cost {
+				t.Fatalf("addr=%s access=%s cost=%d", cfg.Server.Addr, time.Duration(cfg.Auth.AccessTokenTTL), cfg.Auth.BcryptCost)
+			}
+			// 未配置的项保持默认值
+			if cfg.Auth.RefreshTokenTTL != defaultConfig().Auth.RefreshTokenTTL {
+				t.Fatalf("未配置的项被修改: %s", time.Duration(cfg.Auth.RefreshTokenTTL))
+			}
+		})
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	file := writeConfigFile(t, "config.toml", `
+[database]
+driver = "sqlite"
+
+[uploads]
+max_size_mb = 20
+`)
+	cfg, _, err := loadConfig([]string{"-config", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Driver != driverSQLite || cfg.Database.DSN != defaultSQLiteDSN || cfg.Uploads.MaxSizeMB != 20 {
+		t.Fatalf("driver=%s dsn=%s max=%d", cfg.Database.Driver, cfg.Database.DSN, cfg.Uploads.MaxSizeMB)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"不支持的文件格式", nil, []string{"-config", writeConfigFile(t, "config.ini", "addr=:80")}},
+		{"配置文件不存在", nil, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}},
+		{"时长格式错误", map[string]string{"ACCESS_TOKEN_TTL": "15"}, nil},
+		{"整数格式错误", map[string]string{"BCRYPT_COST": "ten"}, nil},
+		{"布尔格式错误", map[string]string{"RATE_LIMIT_ENABLED": "maybe"}, nil},
+		{"未知参数", nil, []string{"-no-such-flag"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, _, err := loadConfig(tt.args); err == nil {
+				t.Fatal("期望返回错误")
+			}
+		})
+	}
+}
+
+func TestExampleConfigParses(t *testing.T) {
+	cfg := defaultConfig()
+	if err := loadConfigFile("config.example.yaml", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Auth.JWTSecret = testJWTSecret
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("示例配置未通过校验: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // 错误信息片段，为空表示应通过
+	}{
+		{"合法", func(*Config) {}, ""},
+		{"缺少JWT密钥", func(c *Config) { c.Auth.JWTSecret = "" }, "未配置JWT密钥"},
+		{"JWT密钥过短", func(c *Config) { c.Auth.JWTSecret = "abc" }, "JWT密钥过短"},
+		{"JWT密钥过弱", func(c *Config) { c.Auth.JWTSecret = strings.Repeat("ab", 20) }, "过于简单"},
+		{"访问令牌长于刷新令牌", func(c *Config) { c.Auth.AccessTokenTTL = c.Auth.RefreshTokenTTL }, "访问令牌有效期"},
+		{"bcrypt成本过低", func(c *Config) { c.Auth.BcryptCost = 1 }, "bcrypt成本"},
+		{"不支持的数据库驱动", func(c *Config) { c.Database.Driver = "postgres" }, "数据库驱动"},
+		{"评论深度越界", func(c *Config) { c.Comments.MaxDepth = maxCommentDepthLimit + 1 }, "评论最大深度"},
+		{"附件大小越界", func(c *Config) { c.Uploads.MaxSizeMB = 0 }, "附件大小上限"},
+		{"限流参数无效", func(c *Config) { c.RateLimit.Auth.Limit = 0 }, "限流策略auth"},
+		{"关闭限流时不校验限流参数", func(c *Config) { c.RateLimit.Enabled = false; c.RateLimit.Auth.Limit = 0 }, ""},
+		{"注销策略无效", func(c *Config) { c.Accounts.DeletionPolicy = "keep" }, "注销策略"},
+		{"SMTP缺少服务器", func(c *Config) { c.Mail.Driver = mailDriverSMTP }, "服务器地址"},
+		{"SMTP缺少站点地址", func(c *Config) { c.Mail.Driver = mailDriverSMTP; c.Mail.SMTP.Host = "smtp.example.com" }, "server.base_url"},
+		{"SMTP配置完整", func(c *Config) {
+			c.Mail.Driver = mailDriverSMTP
+			c.Mail.SMTP.Host = "smtp.example.com"
+			c.Server.BaseURL = "https://blog.example.com"
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Auth.JWTSecret = testJWTSecret
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("不应报错: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("错误为%v，期望包含%q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Auth.JWTSecret = testJWTSecret
+	cfg.Mail.SMTP.Password = "smtp-password"
+	cfg.Database.DSN = "root:123456@tcp(127.0.0.1:3306)/dbtest"
+
+	out := cfg.Redacted()
+	if out.Auth.JWTSecret == testJWTSecret || out.Mail.SMTP.Password == "smtp-password" {
+		t.Fatal("密钥未脱敏")
+	}
+	if out.Database.DSN != "root:******@tcp(127.0.0.1:3306)/dbtest" {
+		t.Fatalf("DSN为%q", out.Database.DSN)
+	}
+	if cfg.Auth.JWTSecret != testJWTSecret {
+		t.Fatal("脱敏不应修改原配置")
+	}
+}
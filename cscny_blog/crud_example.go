@@ -2,18 +2,18 @@ package main
 
 import (
 	"log"
-	"os"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 )
 
-// CRUDExample 演示如何使用CRUD操作
-func CRUDExample() {
+// CRUDExample 演示如何使用CRUD操作（监听地址和数据库取自cfg.CRUD）
+func CRUDExample(cfg Config) {
+	if err := cfg.ValidateCRUD(); err != nil {
+		log.Fatalf("配置校验未通过: %v", err)
+	}
+
 	// 数据库连接
-	dsn := "root:123456@tcp(127.0.0.1:3306)/crud_demo?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := openDatabase(cfg.CRUD.Database.Driver, cfg.CRUD.Database.DSN)
 	if err != nil {
 		log.Fatalf("数据库连接失败: %v", err)
 	}
@@ -51,20 +51,17 @@ func CRUDExample() {
 		})
 	})
 
-	// 启动服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8081" // 使用不同的端口避免与博客系统冲突
-	}
+	// 启动服务器（地址默认:8081，可由PORT环境变量、crud.addr配置或-crud-addr参数指定）
+	addr := cfg.CRUD.Addr
 
-	log.Printf("CRUD API服务器启动成功，监听端口: %s", port)
-	log.Printf("API文档地址: http://localhost:%s/health", port)
+	log.Printf("CRUD API服务器启动成功，监听地址: %s", addr)
+	log.Printf("API文档地址: http://localhost%s/health", addr)
 	log.Printf("示例API调用:")
-	log.Printf("  GET  http://localhost:%s/api/v1/categories", port)
-	log.Printf("  GET  http://localhost:%s/api/v1/products", port)
-	log.Printf("  POST http://localhost:%s/api/v1/products", port)
+	log.Printf("  GET  http://localhost%s/api/v1/categories", addr)
+	log.Printf("  GET  http://localhost%s/api/v1/products", addr)
+	log.Printf("  POST http://localhost%s/api/v1/products", addr)
 
-	if err := r.Run(":" + port); err != nil {
+	if err := r.Run(addr); err != nil {
 		log.Fatalf("服务器启动失败: %v", err)
 	}
 }
@@ -74,12 +71,16 @@ func CRUDExample() {
 /*
 如何运行CRUD示例：
 
-1. 确保MySQL数据库运行正常
+1. 确保MySQL数据库运行正常（或设置CRUD_DB_DRIVER=sqlite使用内存数据库）
 2. 创建数据库：CREATE DATABASE crud_demo;
 3. 在main.go中调用CRUDExample()函数，或者创建单独的main函数：
 
 func main() {
-    CRUDExample()
+    cfg, _, err := loadConfig(os.Args[1:])
+    if err != nil {
+        log.Fatal(err)
+    }
+    CRUDExample(cfg)
 }
 
 4. 运行程序：go run *.go
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// === 数据库连接 ===
+const (
+	driverMySQL  = "mysql"
+	driverSQLite = "sqlite" // 纯Go实现，无需CGO
+
+	defaultMySQLDSN     = "root:123456@tcp(127.0.0.1:3306)/dbtest?charset=utf8mb4&parseTime=True&loc=Local"
+	defaultCRUDMySQLDSN = "root:123456@tcp(127.0.0.1:3306)/crud_demo?charset=utf8mb4&parseTime=True&loc=Local"
+	defaultSQLiteDSN    = "file::memory:" // 默认使用内存数据库，进程退出后数据清空
+)
+
+// defaultDSN 返回驱动对应的默认连接串
+func defaultDSN(driver string) string {
+	if driver == driverSQLite {
+		return defaultSQLiteDSN
+	}
+	return defaultMySQLDSN
+}
+
+// openDatabase 按驱动名打开数据库连接；dsn为空时使用对应驱动的默认值
+func openDatabase(driver, dsn string) (*gorm.DB, error) {
+	if dsn == "" {
+		dsn = defaultDSN(driver)
+	}
+	switch driver {
+	case driverMySQL, "":
+		return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	case driverSQLite:
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		// SQLite只允许单写者；内存库每个连接各自独立，必须共用同一个连接
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		if err := db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+			return nil, err
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
+
+// migrate 自动迁移博客系统的所有表结构
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&User{}, &Post{}, &Comment{}, &Tag{}, &UserRole{}, &RefreshToken{}, &RevokedToken{}, &PostRevision{}, &WebSession{}, &PostSlugRedirect{}, &Reaction{}, &Follow{}, &Notification{}, &Attachment{}, &LoginFailure{}, &AccountToken{}); err != nil {
+		return err
+	}
+	if err := backfillCommentPaths(db); err != nil {
+		return err
+	}
+	// 升级前的文章均视为已发布，补全发布时间
+	if err := db.Model(&Post{}).Where("status = ? AND published_at IS NULL", postStatusPublished).
+		Update("published_at", gorm.Expr("created_at")).Error; err != nil {
+		return err
+	}
+	if err := backfillPostRevisions(db); err != nil {
+		return err
+	}
+	return backfillPostSlugs(db)
+}
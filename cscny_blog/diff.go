@@ -0,0 +1,166 @@
+package main
+
+import "strings"
+
+// === 行级文本差异 ===
+// 使用Myers算法计算最短编辑脚本，结果按行给出相等/新增/删除
+
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+const (
+	maxDiffLines = 5000 // 单侧最大行数，超出时拒绝计算
+	maxDiffEdits = 2000 // 最大编辑距离，超出时退化为整段删除+整段新增以限制内存
+)
+
+// DiffLine 差异中的一行；OldLine/NewLine为行号（从1开始，不存在时为0）
+type DiffLine struct {
+	Op      string `json:"op"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// splitLines 按行切分文本（统一换行符，忽略末尾换行）
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines 计算从a到b的行级差异
+func diffLines(a, b []string) []DiffLine {
+	// 先去掉公共前后缀，只对中间变化的部分运行Myers算法
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffLine
+	for _, text := range a[:prefix] {
+		ops = append(ops, DiffLine{Op: diffEqual, Text: text})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myersDiff(midA, midB, maxDiffEdits); ok {
+		ops = append(ops, middle...)
+	} else {
+		for _, text := range midA {
+			ops = append(ops, DiffLine{Op: diffDelete, Text: text})
+		}
+		for _, text := range midB {
+			ops = append(ops, DiffLine{Op: diffInsert, Text: text})
+		}
+	}
+	for _, text := range a[len(a)-suffix:] {
+		ops = append(ops, DiffLine{Op: diffEqual, Text: text})
+	}
+
+	// 补充行号
+	oldLine, newLine := 0, 0
+	for i := range ops {
+		switch ops[i].Op {
+		case diffEqual:
+			oldLine++
+			newLine++
+			ops[i].OldLine, ops[i].NewLine = oldLine, newLine
+		case diffDelete:
+			oldLine++
+			ops[i].OldLine = oldLine
+		case diffInsert:
+			newLine++
+			ops[i].NewLine = newLine
+		}
+	}
+	return ops
+}
+
+// myersDiff 用Myers算法计算a到b的最短编辑脚本（不含行号）；
+// 编辑距离超过maxEdits时返回false。每轮只保存对角线[-d,d]上的结果，
+// 内存为O(maxEdits²)，与文本长度无关
+func myersDiff(a, b []string, maxEdits int) ([]DiffLine, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// rounds[d][(k+d)/2]为第d轮结束后对角线k上能到达的最远x，用于回溯
+	var rounds [][]int
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // 向下移动：插入
+			} else {
+				x = v[offset+k-1] + 1 // 向右移动：删除
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		round := make([]int, d+1)
+		for i := range round {
+			round[i] = v[offset-d+2*i]
+		}
+		rounds = append(rounds, round)
+	}
+	if !found {
+		return nil, false
+	}
+
+	// 从终点回溯，得到逆序的编辑脚本
+	at := func(d, k int) int { return rounds[d][(k+d)/2] }
+	var ops []DiffLine
+	x, y := n, m
+	for d := len(rounds) - 1; d > 0; d-- {
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(d-1, k-1) < at(d-1, k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(d-1, prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffLine{Op: diffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, DiffLine{Op: diffInsert, Text: b[y-1]})
+		} else {
+			ops = append(ops, DiffLine{Op: diffDelete, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for ; x > 0; x-- {
+		ops = append(ops, DiffLine{Op: diffEqual, Text: a[x-1]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyDiff 由差异还原出两侧文本，并校验行号连续
+func applyDiff(t *testing.T, ops []DiffLine) (oldLines, newLines []string) {
+	t.Helper()
+	for _, op := range ops {
+		switch op.Op {
+		case diffEqual:
+			oldLines = append(oldLines, op.Text)
+			newLines = append(newLines, op.Text)
+		case diffDelete:
+			oldLines = append(oldLines, op.Text)
+		case diffInsert:
+			newLines = append(newLines, op.Text)
+		}
+		if (op.Op != diffInsert && op.OldLine != len(oldLines)) || (op.Op != diffDelete && op.NewLine != len(newLines)) {
+			t.Fatalf("行号有误: %+v", op)
+		}
+	}
+	return oldLines, newLines
+}
+
+// countEdits 统计新增和删除的行数
+func countEdits(ops []DiffLine) int {
+	edits := 0
+	for _, op := range ops {
+		if op.Op != diffEqual {
+			edits++
+		}
+	}
+	return edits
+}
+
+// lcsLength 最长公共子序列长度（动态规划，用于校验编辑脚本最短）
+func lcsLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"\n", nil},
+		{"a", []string{"a"}},
+		{"a\r\nb\n", []string{"a", "b"}},
+		{"a\n\nb", []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		if got := splitLines(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("splitLines(%q) = %q，期望%q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string // 每行的操作，=表示相等，+表示新增，-表示删除
+	}{
+		{"相同", "a\nb", "a\nb", "=="},
+		{"都为空", "", "", ""},
+		{"从空到有", "", "a\nb", "++"},
+		{"全部删除", "a\nb", "", "--"},
+		{"中间插入", "a\nc", "a\nb\nc", "=+="},
+		{"中间删除", "a\nb\nc", "a\nc", "=-="},
+		{"修改一行", "a\nb\nc", "a\nx\nc", "=-+="},
+		{"交换顺序", "a\nb", "b\na", "-=+"},
+	}
+	symbols := map[string]string{diffEqual: "=", diffInsert: "+", diffDelete: "-"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := splitLines(tt.old), splitLines(tt.new)
+			ops := diffLines(a, b)
+			got := ""
+			for _, op := range ops {
+				got += symbols[op.Op]
+			}
+			if got != tt.want {
+				t.Fatalf("差异为%q，期望%q", got, tt.want)
+			}
+			oldLines, newLines := applyDiff(t, ops)
+			if !reflect.DeepEqual(oldLines, a) || !reflect.DeepEqual(newLines, b) {
+				t.Fatalf("还原结果为%q/%q", oldLines, newLines)
+			}
+		})
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		var lines []string
+		for n := rng.Intn(30); len(lines) < n; {
+			lines = append(lines, string(rune('a'+rng.Intn(4))))
+		}
+		return lines
+	}
+	for i := 0; i < 300; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+		oldLines, newLines := applyDiff(t, ops)
+		if !reflect.DeepEqual(oldLines, a) || !reflect.DeepEqual(newLines, b) {
+			t.Fatalf("无法还原: a=%q b=%q", a, b)
+		}
+		if got, want := countEdits(ops), len(a)+len(b)-2*lcsLength(a, b); got != want {
+			t.Fatalf("编辑数为%d，最短为%d: a=%q b=%q", got, want, a, b)
+		}
+	}
+}
+
+func TestDiffLinesFallback(t *testing.T) {
+	// 公共前后缀之间的内容完全不同，编辑距离超过maxDiffEdits
+	var a, b []string
+	a = append(a, "开头")
+	b = append(b, "开头")
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("旧%d", i))
+		b = append(b, fmt.Sprintf("新%d", i))
+	}
+	a = append(a, "结尾")
+	b = append(b, "结尾")
+
+	ops := diffLines(a, b)
+	oldLines, newLines := applyDiff(t, ops)
+	if !reflect.DeepEqual(oldLines, a) || !reflect.DeepEqual(newLines, b) {
+		t.Fatal("退化后无法还原")
+	}
+	if ops[0].Op != diffEqual || ops[len(ops)-1].Op != diffEqual {
+		t.Fatal("公共前后缀应保持相等")
+	}
+	middle := ops[1 : len(ops)-1]
+	for i, op := range middle {
+		want := diffDelete
+		if i >= maxDiffEdits {
+			want = diffInsert
+		}
+		if op.Op != want {
+			t.Fatalf("第%d行为%s，期望先整段删除再整段新增", i, op.Op)
+		}
+	}
+}
+
+func TestMyersDiffMaxEdits(t *testing.T) {
+	a := strings.Split("a b c d", " ")
+	b := strings.Split("w x y z", " ")
+	if _, ok := myersDiff(a, b, 7); ok {
+		t.Fatal("编辑距离为8，超过上限时应返回false")
+	}
+	if ops, ok := myersDiff(a, b, 8); !ok || countEdits(ops) != 8 {
+		t.Fatalf("编辑距离恰好等于上限时应成功: ok=%v ops=%v", ok, ops)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "sync"
+
+// === 进程内事件总线 ===
+// Handler完成写操作后发布事件，通知、实时推送等功能各自订阅，互不依赖。
+// 订阅者在发布者的goroutine中同步执行，耗时操作应自行转为异步；
+// 单个订阅者panic只记录日志，不影响其他订阅者和发布者。
+
+// 事件主题
+const (
+	eventCommentCreated = "comment.created"
+	eventCommentUpdated = "comment.updated"
+	eventCommentDeleted = "comment.deleted"
+)
+
+// CommentEvent 评论相关事件的内容
+type CommentEvent struct {
+	Comment *Comment // 评论（含ParentID）
+	Post    *Post    // 评论所属文章（仅创建事件提供）
+	ActorID uint     // 触发事件的用户
+}
+
+// eventBus 按主题分发事件（并发安全）
+type eventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(payload interface{})
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{handlers: make(map[string][]func(payload interface{}))}
+}
+
+// Subscribe 订阅主题，通常在启动时调用
+func (b *eventBus) Subscribe(topic string, fn func(payload interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = append(b.handlers[topic], fn)
+}
+
+// Publish 依次调用该主题的全部订阅者
+func (b *eventBus) Publish(topic string, payload interface{}) {
+	b.mu.RLock()
+	handlers := b.handlers[topic]
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Printf("事件处理失败: topic=%s err=%v", topic, r)
+				}
+			}()
+			fn(payload)
+		}()
+	}
+}
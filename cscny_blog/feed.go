@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// === RSS/Atom订阅 ===
+// 全站、作者、标签三种订阅源，均只包含已发布的文章，按发布时间倒序。
+// 响应带ETag和Last-Modified，阅读器带条件请求且内容未变化时返回304。
+
+const (
+	siteTitle      = "博客"
+	feedSize       = 20  // 每个订阅源包含的文章数
+	feedExcerptLen = 200 // 摘要最大字符数
+
+	feedRSS  = "rss"
+	feedAtom = "atom"
+)
+
+var siteBaseURL string // 站点根地址（用于生成绝对链接，为空时按请求推断；由配置加载）
+
+var stripTags = bluemonday.StrictPolicy()
+
+// --- RSS 2.0 ---
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// --- Atom 1.0 ---
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// feedMeta 订阅源的标题、对应的网页路径和订阅源自身路径
+type feedMeta struct {
+	Title    string
+	PagePath string
+	SelfPath string
+}
+
+// baseURL 站点根地址（未配置时按请求的协议和Host推断）
+func baseURL(c *gin.Context) string {
+	if siteBaseURL != "" {
+		return siteBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// postExcerpt 由渲染后的HTML生成纯文本摘要
+func postExcerpt(contentHTML string) string {
+	text := html.UnescapeString(stripTags.Sanitize(contentHTML))
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > feedExcerptLen {
+		return string(runes[:feedExcerptLen]) + "…"
+	}
+	return text
+}
+
+// publishedTime 文章发布时间（升级前的旧数据回退为创建时间）
+func publishedTime(post *Post) time.Time {
+	if post.PublishedAt != nil {
+		return *post.PublishedAt
+	}
+	return post.CreatedAt
+}
+
+// buildFeed 生成RSS或Atom文档，返回内容及最后修改时间
+func buildFeed(c *gin.Context, format string, meta feedMeta, posts []Post, renderer *contentRenderer) ([]byte, time.Time, error) {
+	base := baseURL(c)
+	var lastModified time.Time
+	for i := range posts {
+		if posts[i].UpdatedAt.After(lastModified) {
+			lastModified = posts[i].UpdatedAt
+		}
+	}
+	if lastModified.IsZero() {
+		lastModified = time.Unix(0, 0)
+	}
+
+	excerpts := make([]string, len(posts))
+	for i := range posts {
+		contentHTML, err := renderer.Render(&posts[i])
+		if err != nil {
+			return nil, lastModified, err
+		}
+		excerpts[i] = postExcerpt(contentHTML)
+	}
+	// 链接使用slug；唯一标识使用文章ID路径，改标题后阅读器不会把文章当作新条目
+	permalink := func(post *Post) string {
+		return base + "/posts/" + strconv.FormatUint(uint64(post.ID), 10)
+	}
+
+	var doc interface{}
+	if format == feedAtom {
+		feed := atomFeed{
+			Title:   meta.Title,
+			ID:      base + meta.SelfPath,
+			Updated: lastModified.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: base + meta.SelfPath, Rel: "self", Type: "application/atom+xml"},
+				{Href: base + meta.PagePath, Rel: "alternate", Type: "text/html"},
+			},
+			Entries: make([]atomEntry, 0, len(posts)),
+		}
+		for i := range posts {
+			post := &posts[i]
+			entry := atomEntry{
+				Title:     post.Title,
+				ID:        permalink(post),
+				Updated:   post.UpdatedAt.UTC().Format(time.RFC3339),
+				Published: publishedTime(post).UTC().Format(time.RFC3339),
+				Link:      atomLink{Href: base + postPath(post), Rel: "alternate", Type: "text/html"},
+				Author:    atomPerson{Name: post.User.Username},
+				Summary:   excerpts[i],
+			}
+			for _, tag := range post.Tags {
+				entry.Categories = append(entry.Categories, atomCategory{Term: tag.Name})
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		doc = feed
+	} else {
+		feed := rssFeed{
+			Version: "2.0",
+			AtomNS:  "http://www.w3.org/2005/Atom",
+			DCNS:    "http://purl.org/dc/elements/1.1/",
+			Channel: rssChannel{
+				Title:         meta.Title,
+				Link:          base + meta.PagePath,
+				Description:   meta.Title,
+				SelfLink:      atomLink{Href: base + meta.SelfPath, Rel: "self", Type: "application/rss+xml"},
+				LastBuildDate: lastModified.UTC().Format(time.RFC1123Z),
+				Items:         make([]rssItem, 0, len(posts)),
+			},
+		}
+		for i := range posts {
+			post := &posts[i]
+			item := rssItem{
+				Title:       post.Title,
+				Link:        base + postPath(post),
+				Description: excerpts[i],
+				Creator:     post.User.Username,
+				GUID:        rssGUID{IsPermaLink: true, Value: permalink(post)},
+				PubDate:     publishedTime(post).UTC().Format(time.RFC1123Z),
+			}
+			for _, tag := range post.Tags {
+				item.Categories = append(item.Categories, tag.Name)
+			}
+			feed.Channel.Items = append(feed.Channel.Items, item)
+		}
+		doc = feed
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, lastModified, err
+	}
+	return append([]byte(xml.Header), body...), lastModified, nil
+}
+
+// notModified 根据If-None-Match/If-Modified-Since判断客户端缓存是否仍然有效
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false // 有If-None-Match时忽略If-Modified-Since
+	}
+	if since := c.GetHeader("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveFeed 查询文章并输出订阅源
+func serveFeed(c *gin.Context, format string, meta feedMeta, posts PostRepository, renderer *contentRenderer, query PostQuery) {
+	query.Status = postStatusPublished
+	query.PageQuery = PageQuery{Limit: feedSize, Sort: sortPublishedAt, Desc: true}
+	list, _, err := posts.List(query)
+	if err != nil {
+		logger.Printf("查询订阅文章失败: %v", err)
+		c.String(http.StatusInternalServerError, "查询文章失败")
+		return
+	}
+
+	body, lastModified, err := buildFeed(c, format, meta, list, renderer)
+	if err != nil {
+		logger.Printf("生成订阅源失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成订阅源失败")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	if notModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == feedAtom {
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// === 订阅Handler ===
+// 全站订阅
+func siteFeedHandler(posts PostRepository, renderer *contentRenderer, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		meta := feedMeta{Title: siteTitle, PagePath: "/", SelfPath: c.Request.URL.Path}
+		serveFeed(c, format, meta, posts, renderer, PostQuery{})
+	}
+}
+
+// 作者订阅
+func authorFeedHandler(users UserRepository, posts PostRepository, renderer *contentRenderer, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		author, err := users.FindByUsername(c.Param("username"))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.String(http.StatusNotFound, "用户不存在")
+				return
+			}
+			logger.Printf("查询用户失败: %v", err)
+			c.String(http.StatusInternalServerError, "查询用户失败")
+			return
+		}
+		meta := feedMeta{
+			Title:    siteTitle + " - " + author.Username,
+			PagePath: "/authors/" + author.Username,
+			SelfPath: c.Request.URL.Path,
+		}
+		serveFeed(c, format, meta, posts, renderer, PostQuery{AuthorID: author.ID})
+	}
+}
+
+// 标签订阅
+func tagFeedHandler(tags TagRepository, posts PostRepository, renderer *contentRenderer, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, err := tags.FindBySlug(tagSlug(normalizeTagName(c.Param("slug"))))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.String(http.StatusNotFound, "标签不存在")
+				return
+			}
+			logger.Printf("查询标签失败: %v", err)
+			c.String(http.StatusInternalServerError, "查询标签失败")
+			return
+		}
+		meta := feedMeta{
+			Title:    siteTitle + " - #" + tag.Name,
+			PagePath: "/tags/" + tag.Slug,
+			SelfPath: c.Request.URL.Path,
+		}
+		serveFeed(c, format, meta, posts, renderer, PostQuery{TagID: tag.ID})
+	}
+}
+
+// setupFeedRoutes 注册订阅路由（不经过网页会话中间件，避免响应中带Set-Cookie影响缓存）
+func setupFeedRoutes(r *gin.Engine, repos *Repositories, renderer *contentRenderer) {
+	for _, format := range []string{feedRSS, feedAtom} {
+		r.GET("/feed."+format, siteFeedHandler(repos.Posts, renderer, format))
+		r.GET("/authors/:username/feed."+format, authorFeedHandler(repos.Users, repos.Posts, renderer, format))
+		r.GET("/tags/:slug/feed."+format, tagFeedHandler(repos.Tags, repos.Posts, renderer, format))
+	}
+}
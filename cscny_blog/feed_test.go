@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getFeed 请求订阅源（可附加请求头）
+func (a *testApp) getFeed(path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// alternateLink 返回Atom订阅源中指向网页的链接
+func alternateLink(feed atomFeed) string {
+	for _, link := range feed.Links {
+		if link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func TestPostExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"去掉标签并合并空白", "<p>第一段</p>\n\n<p>第二  段</p>", "第一段 第二 段"},
+		{"反转义实体", "<p>a &amp; b</p>", "a & b"},
+		{"超长时截断", "<p>" + strings.Repeat("字", feedExcerptLen+1) + "</p>", strings.Repeat("字", feedExcerptLen) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postExcerpt(tt.html); got != tt.want {
+				t.Fatalf("摘要为%q，期望%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   bool
+	}{
+		{"无条件请求", nil, false},
+		{"ETag一致", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"弱ETag与列表", map[string]string{"If-None-Match": `"x", W/"abc"`}, true},
+		{"ETag不一致时忽略时间", map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": lastModified.Add(time.Hour).Format(http.TimeFormat)}, false},
+		{"未修改", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{"已修改", map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+			for k, v := range tt.header {
+				c.Request.Header.Set(k, v)
+			}
+			if got := notModified(c, `"abc"`, lastModified); got != tt.want {
+				t.Fatalf("notModified = %v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bob")
+	app.createPost(alice, gin.H{"title": "Alice的文章", "tags": []string{"Go"}})
+	app.createPost(alice, gin.H{"title": "Alice的草稿", "tags": []string{"Go"}, "status": postStatusDraft})
+	app.createPost(bob, gin.H{"title": "Bob的文章", "tags": []string{"Go", "Web"}})
+
+	const base = "http://example.com"
+	tests := []struct {
+		name      string
+		path      string
+		entries   int
+		alternate string
+	}{
+		{"全站", "/feed.atom", 2, base + "/"},
+		{"作者", "/authors/alice/feed.atom", 1, base + "/authors/alice"},
+		{"标签", "/tags/go/feed.atom", 2, base + "/tags/go"},
+		{"标签slug规范化", "/tags/WEB/feed.atom", 1, base + "/tags/web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.getFeed(tt.path, nil)
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+				t.Fatalf("状态码为%d，Content-Type为%s", w.Code, w.Header().Get("Content-Type"))
+			}
+			var feed atomFeed
+			if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+				t.Fatal(err)
+			}
+			if len(feed.Entries) != tt.entries {
+				t.Fatalf("共%d条，期望%d条", len(feed.Entries), tt.entries)
+			}
+			for _, entry := range feed.Entries {
+				if strings.Contains(entry.Title, "草稿") {
+					t.Fatal("订阅源中出现了草稿")
+				}
+			}
+			// 订阅源指向的网页存在
+			link := alternateLink(feed)
+			if link != tt.alternate {
+				t.Fatalf("网页链接为%s，期望%s", link, tt.alternate)
+			}
+			if page := app.newWebClient().get(strings.TrimPrefix(link, base)); page.Code != http.StatusOK {
+				t.Fatalf("网页%s返回%d", link, page.Code)
+			}
+		})
+	}
+
+	w := app.getFeed("/tags/go/feed.rss", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<link>"+base+"/tags/go</link>") ||
+		strings.Count(w.Body.String(), "<item>") != 2 {
+		t.Fatalf("RSS订阅源为%s", w.Body.String())
+	}
+
+	for _, path := range []string{"/authors/nobody/feed.rss", "/tags/rust/feed.atom"} {
+		if w := app.getFeed(path, nil); w.Code != http.StatusNotFound {
+			t.Fatalf("%s返回%d", path, w.Code)
+		}
+	}
+	expectPage(t, app.newWebClient().get("/tags/rust"), http.StatusNotFound)
+}
+
+func TestFeedConditionalRequest(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+	app.createPost(token, nil)
+
+	w := app.getFeed("/feed.rss", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("状态码为%d，ETag=%s，Last-Modified=%s", w.Code, etag, lastModified)
+	}
+	if w := app.getFeed("/feed.rss", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("带ETag请求返回%d", w.Code)
+	}
+	if w := app.getFeed("/feed.rss", map[string]string{"If-Modified-Since": lastModified}); w.Code != http.StatusNotModified {
+		t.Fatalf("带If-Modified-Since请求返回%d", w.Code)
+	}
+
+	// 发布新文章后ETag变化
+	app.createPost(token, gin.H{"title": "新文章"})
+	if w := app.getFeed("/feed.rss", map[string]string{"If-None-Match": etag}); w.Code != http.StatusOK {
+		t.Fatalf("内容变化后返回%d", w.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 关注作者与个人动态 ===
+// 关注关系只记录关注者和被关注者；个人动态按发布时间倒序返回已关注作者的已发布文章，
+// 通过子查询筛选作者并使用(user_id, published_at)索引，关注数百位作者时也无需逐个查询。
+
+// Follow 关注关系
+type Follow struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follow_pair,priority:1" json:"follower_id"`       // 关注者
+	FolloweeID uint      `gorm:"not null;uniqueIndex:idx_follow_pair,priority:2;index" json:"followee_id"` // 被关注者
+	Follower   *User     `gorm:"foreignKey:FollowerID" json:"follower,omitempty"`
+	Followee   *User     `gorm:"foreignKey:FolloweeID" json:"followee,omitempty"`
+	CreatedAt  time.Time `json:"created_at"` // 关注时间
+}
+
+// FollowRepository 关注关系仓储
+type FollowRepository interface {
+	Follow(followerID, followeeID uint) (bool, error)                      // 返回是否新建了关注（已关注时为false）
+	Unfollow(followerID, followeeID uint) (bool, error)                    // 返回是否确实取消了关注
+	ListFollowers(userID uint, q PageQuery) ([]Follow, *Pagination, error) // 粉丝列表（含关注者信息）
+	ListFollowing(userID uint, q PageQuery) ([]Follow, *Pagination, error) // 关注列表（含被关注者信息）
+}
+
+// loadUserParam 解析路径中的用户ID并确认用户存在，失败时已写入响应
+func loadUserParam(c *gin.Context, users UserRepository) (*User, bool) {
+	userID, err := paramID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	user, err := users.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return nil, false
+		}
+		logger.Printf("查询用户失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return nil, false
+	}
+	return user, true
+}
+
+// === 关注Handler ===
+// 关注用户（重复关注视为成功）
+func followHandler(users UserRepository, follows FollowRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		followee, ok := loadUserParam(c, users)
+		if !ok {
+			return
+		}
+		if followee.ID == userId.(uint) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能关注自己"})
+			return
+		}
+
+		created, err := follows.Follow(userId.(uint), followee.ID)
+		if err != nil {
+			logger.Printf("关注用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "关注失败"})
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, gin.H{"message": "已关注 " + followee.Username})
+	}
+}
+
+// 取消关注
+func unfollowHandler(users UserRepository, follows FollowRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		followee, ok := loadUserParam(c, users)
+		if !ok {
+			return
+		}
+
+		removed, err := follows.Unfollow(userId.(uint), followee.ID)
+		if err != nil {
+			logger.Printf("取消关注失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "取消关注失败"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "尚未关注该用户"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "已取消关注 " + followee.Username})
+	}
+}
+
+// 粉丝列表/关注列表（无需认证，按关注时间倒序分页）
+func listFollowsHandler(users UserRepository, list func(userID uint, q PageQuery) ([]Follow, *Pagination, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUserParam(c, users)
+		if !ok {
+			return
+		}
+		query, err := parsePageQuery(c, true, sortCreatedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, page, err := list(user.ID, query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询关注列表失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询关注列表失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": result, "pagination": page})
+	}
+}
+
+// 个人动态：已关注作者最近发布的文章（默认按发布时间倒序，参数limit、cursor、order）
+func feedHandler(posts PostRepository, reactions ReactionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		page, err := parsePageQuery(c, true, sortPublishedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query := PostQuery{PageQuery: page, FollowerID: userId.(uint), Status: postStatusPublished}
+		list, pagination, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询个人动态失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询动态失败"})
+			return
+		}
+		if err := attachPostReactions(c, reactions, list); err != nil {
+			logger.Printf("查询文章回应失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询动态失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list, "pagination": pagination})
+	}
+}
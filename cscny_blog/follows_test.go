@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFollowUnfollow(t *testing.T) {
+	app := newTestApp(t)
+	aliceID, alice := app.newUser("alice")
+	bobID := app.register("bob")
+
+	follow := fmt.Sprintf("/api/protected/users/%d/follow", bobID)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"关注", http.MethodPost, follow, http.StatusCreated},
+		{"重复关注视为成功", http.MethodPost, follow, http.StatusOK},
+		{"不能关注自己", http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", aliceID), http.StatusBadRequest},
+		{"用户不存在", http.MethodPost, "/api/protected/users/9999/follow", http.StatusNotFound},
+		{"用户ID无效", http.MethodPost, "/api/protected/users/abc/follow", http.StatusBadRequest},
+		{"取消关注", http.MethodDelete, follow, http.StatusOK},
+		{"尚未关注", http.MethodDelete, follow, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(tt.method, tt.path, nil, alice), tt.status, nil)
+		})
+	}
+}
+
+func TestListFollows(t *testing.T) {
+	app := newTestApp(t)
+	targetID := app.register("target")
+	var names []string
+	for _, name := range []string{"fan1", "fan2", "fan3"} {
+		_, token := app.newUser(name)
+		expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", targetID), nil, token), http.StatusCreated, nil)
+		names = append([]string{name}, names...) // 按关注时间倒序
+	}
+
+	// 粉丝列表分页
+	var got []string
+	path := fmt.Sprintf("/api/public/users/%d/followers?limit=2", targetID)
+	for path != "" {
+		var resp listResponse[Follow]
+		expect(t, app.request(http.MethodGet, path, nil, ""), http.StatusOK, &resp)
+		for _, f := range resp.Data {
+			got = append(got, f.Follower.Username)
+		}
+		path = ""
+		if resp.Pagination.HasMore {
+			path = fmt.Sprintf("/api/public/users/%d/followers?limit=2&cursor=%s", targetID, resp.Pagination.NextCursor)
+		}
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Fatalf("粉丝列表为%v，期望%v", got, names)
+	}
+
+	var following listResponse[Follow]
+	fanID := app.register("fan4")
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/users/%d/following", fanID), nil, ""), http.StatusOK, &following)
+	if len(following.Data) != 0 {
+		t.Fatalf("关注列表为%+v", following.Data)
+	}
+	expect(t, app.request(http.MethodGet, "/api/public/users/9999/followers", nil, ""), http.StatusNotFound, nil)
+}
+
+func TestPersonalFeed(t *testing.T) {
+	app := newTestApp(t)
+	_, reader := app.newUser("reader")
+	followedID, followed := app.newUser("followed")
+	_, other := app.newUser("other")
+
+	first := app.createPost(followed, gin.H{"title": "关注作者的文章一"})
+	app.createPost(followed, gin.H{"title": "关注作者的草稿", "status": postStatusDraft})
+	second := app.createPost(followed, gin.H{"title": "关注作者的文章二"})
+	app.createPost(other, gin.H{"title": "其他作者的文章"})
+
+	feed := func() []uint {
+		t.Helper()
+		var resp listResponse[Post]
+		expect(t, app.request(http.MethodGet, "/api/protected/feed", nil, reader), http.StatusOK, &resp)
+		var ids []uint
+		for _, post := range resp.Data {
+			ids = append(ids, post.ID)
+		}
+		return ids
+	}
+
+	if ids := feed(); len(ids) != 0 {
+		t.Fatalf("未关注任何人时动态为%v", ids)
+	}
+	expect(t, app.request(http.MethodPost, fmt.Sprintf("/api/protected/users/%d/follow", followedID), nil, reader), http.StatusCreated, nil)
+	if ids, want := feed(), []uint{second.ID, first.ID}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("动态为%v，期望%v", ids, want)
+	}
+
+	expect(t, app.request(http.MethodGet, "/api/protected/feed?sort=comment_count", nil, reader), http.StatusBadRequest, nil)
+	expect(t, app.request(http.MethodGet, "/api/protected/feed", nil, ""), http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// === 图片处理 ===
+// 上传的图片一律解码后重新编码：编码器不会写入EXIF等元数据，从而去掉GPS位置、设备信息等；
+// JPEG在去掉EXIF之前先按其中的方向标记旋转像素，避免手机照片显示方向错误。
+
+const (
+	thumbnailSize  = 320        // 缩略图最长边（像素）
+	avatarSize     = 256        // 头像边长（像素）
+	maxImagePixels = 50_000_000 // 最大像素数，防止解压炸弹；GIF动画按所有帧合计
+	jpegQuality    = 90
+)
+
+// processedImage 处理后的图片
+type processedImage struct {
+	Data      []byte // 去除元数据后的原图
+	Thumbnail []byte // 缩略图（原图不大于缩略图尺寸时为空）
+	Width     int
+	Height    int
+}
+
+// processImage 校验尺寸、去除元数据并生成缩略图；contentType为检测出的图片类型
+func processImage(data []byte, contentType string) (*processedImage, error) {
+	img, err := decodeImage(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/gif":
+		// GIF没有EXIF；重新编码以保留动画并去掉注释等扩展块。
+		// DecodeAll会一次解码所有帧，先不解码地数出帧数，按画布面积×帧数限制内存占用
+		if gifPixels(data) > maxImagePixels {
+			return nil, fmt.Errorf("GIF动画过大（所有帧合计最多%d万像素）", maxImagePixels/10000)
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("无法解码GIF图片")
+		}
+		err = gif.EncodeAll(&buf, anim)
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	result := &processedImage{Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}
+	if result.Width > thumbnailSize || result.Height > thumbnailSize {
+		if result.Thumbnail, err = encodeThumbnail(img, contentType); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// decodeImage 校验尺寸后解码（GIF取第一帧），JPEG按EXIF方向标记转正
+func decodeImage(data []byte, contentType string) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("无法识别的图片")
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("图片尺寸过大（最多%d万像素）", maxImagePixels/10000)
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	case "image/jpeg":
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err == nil {
+			img = applyOrientation(img, jpegOrientation(data))
+		}
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("不支持的图片类型: %s", contentType)
+	}
+	if err != nil {
+		return nil, errors.New("无法解码图片")
+	}
+	return img, nil
+}
+
+// processAvatar 居中裁剪为正方形并缩放到avatarSize；JPEG输出JPEG，其余输出PNG（保留透明）
+func processAvatar(data []byte, contentType string) ([]byte, error) {
+	img, err := decodeImage(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	size := min(side, avatarSize)
+	avatar := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(avatar, avatar.Bounds(), img, crop, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, avatar, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, avatar)
+	}
+	return buf.Bytes(), err
+}
+
+// encodeThumbnail 按比例缩小到最长边为thumbnailSize；JPEG输出JPEG，其余输出PNG（保留透明）
+func encodeThumbnail(img image.Image, contentType string) ([]byte, error) {
+	bounds := img.Bounds()
+	w, h := thumbnailSize, thumbnailSize
+	if bounds.Dx() > bounds.Dy() {
+		h = max(1, bounds.Dy()*thumbnailSize/bounds.Dx())
+	} else {
+		w = max(1, bounds.Dx()*thumbnailSize/bounds.Dy())
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	return buf.Bytes(), err
+}
+
+// applyOrientation 按EXIF方向标记(2-8)翻转/旋转图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dw, dh := w, h
+	if orientation >= 5 { // 5-8需要交换宽高
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90°
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90°
+				dx, dy = y, w-1-x
+			}
+			s, d := src.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
+
+// gifPixels 不解码像素地遍历GIF的数据块，返回画布面积×帧数（每帧不超过画布）；
+// 超过maxImagePixels时提前返回，数据截断时按已读到的帧计算（交给解码器报错）
+func gifPixels(data []byte) int {
+	if len(data) < 13 {
+		return 0
+	}
+	area := int(binary.LittleEndian.Uint16(data[6:])) * int(binary.LittleEndian.Uint16(data[8:]))
+	i := 13
+	if data[10]&0x80 != 0 { // 全局颜色表
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	// skipSubBlocks 跳过以长度0结束的子块序列
+	skipSubBlocks := func(i int) int {
+		for i < len(data) && data[i] != 0 {
+			i += 1 + int(data[i])
+		}
+		return i + 1
+	}
+
+	total := 0
+	for i < len(data) && total <= maxImagePixels {
+		switch data[i] {
+		case 0x21: // 扩展块：标签 + 子块
+			i = skipSubBlocks(i + 2)
+		case 0x2C: // 图像描述符（10字节）+ 局部颜色表 + LZW最小码长 + 子块
+			if i+10 > len(data) {
+				return total
+			}
+			total += area
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i = skipSubBlocks(i + 1)
+		default: // 0x3B为结束标记，其他字节说明数据有误，都停止遍历
+			return total
+		}
+	}
+	return total
+}
+
+// jpegOrientation 读取JPEG中EXIF的方向标记，没有或无法解析时返回1（正常方向）
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // 无长度的标记
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9: // 图像数据开始，之后不会再有EXIF
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation 在TIFF结构的第一个IFD中查找方向标记(0x0112)
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for j := 0; j < count; j++ {
+		entry := offset + 2 + j*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
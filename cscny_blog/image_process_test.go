@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testImage 生成w×h的图片，左上角像素为红色
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{0, 0, 255, 255})
+		}
+	}
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	return img
+}
+
+// encodeTestImage 按类型编码测试图片
+func encodeTestImage(t *testing.T, contentType string, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, testImage(w, h), nil)
+	case "image/gif":
+		err = gif.Encode(&buf, testImage(w, h), nil)
+	default:
+		err = png.Encode(&buf, testImage(w, h))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeTestAnimation 编码画布为w×h、共frames帧的GIF动画，每帧为左上角size×size的图片
+func encodeTestAnimation(t *testing.T, w, h, size, frames int) []byte {
+	t.Helper()
+	anim := &gif.GIF{Config: image.Config{Width: w, Height: h}}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.Black, color.White}))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIFOrientation 在JPEG的SOI之后插入只含方向标记的EXIF段
+func withEXIFOrientation(data []byte, order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)            // 1个条目
+	order.PutUint16(tiff[10:], 0x0112)      // 方向标记
+	order.PutUint16(tiff[12:], 3)           // SHORT
+	order.PutUint32(tiff[14:], 1)           // 数量
+	order.PutUint16(tiff[18:], orientation) // 值
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeTestImage(t, "image/jpeg", 4, 2)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"没有EXIF", plain, 1},
+		{"小端序", withEXIFOrientation(plain, binary.LittleEndian, 6), 6},
+		{"大端序", withEXIFOrientation(plain, binary.BigEndian, 8), 8},
+		{"无效的方向值", withEXIFOrientation(plain, binary.LittleEndian, 9), 1},
+		{"不是JPEG", encodeTestImage(t, "image/png", 4, 2), 1},
+		{"EXIF段被截断", withEXIFOrientation(plain, binary.BigEndian, 6)[:20], 1},
+		{"空数据", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Fatalf("方向为%d，期望%d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3×2的图片，左上角为红色；旋转后检查尺寸和红色像素的位置
+	tests := []struct {
+		orientation int
+		w, h        int
+		red         image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0)},
+		{2, 3, 2, image.Pt(2, 0)},
+		{3, 3, 2, image.Pt(2, 1)},
+		{4, 3, 2, image.Pt(0, 1)},
+		{5, 2, 3, image.Pt(0, 0)},
+		{6, 2, 3, image.Pt(1, 0)},
+		{7, 2, 3, image.Pt(1, 2)},
+		{8, 2, 3, image.Pt(0, 2)},
+	}
+	for _, tt := range tests {
+		img := applyOrientation(testImage(3, 2), tt.orientation)
+		if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Fatalf("方向%d：尺寸为%dx%d", tt.orientation, b.Dx(), b.Dy())
+		}
+		if r, _, _, _ := img.At(tt.red.X, tt.red.Y).RGBA(); r == 0 {
+			t.Fatalf("方向%d：红色像素不在%v", tt.orientation, tt.red)
+		}
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	// 只有头部的GIF，声明的尺寸超过像素上限
+	huge := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00")
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		w, h        int
+		thumbnail   image.Point // 为零表示没有缩略图
+		err         string
+	}{
+		{"大图生成缩略图", "image/png", encodeTestImage(t, "image/png", 640, 320), 640, 320, image.Pt(thumbnailSize, thumbnailSize/2), ""},
+		{"竖图按高度缩放", "image/jpeg", encodeTestImage(t, "image/jpeg", 100, 400), 100, 400, image.Pt(80, thumbnailSize), ""},
+		{"GIF缩略图为PNG", "image/gif", encodeTestImage(t, "image/gif", 400, 400), 400, 400, image.Pt(thumbnailSize, thumbnailSize), ""},
+		{"小图没有缩略图", "image/png", encodeTestImage(t, "image/png", 100, 50), 100, 50, image.Point{}, ""},
+		{"按EXIF方向转正", "image/jpeg", withEXIFOrientation(encodeTestImage(t, "image/jpeg", 40, 20), binary.BigEndian, 6), 20, 40, image.Point{}, ""},
+		{"GIF动画", "image/gif", encodeTestAnimation(t, 40, 40, 40, 3), 40, 40, image.Point{}, ""},
+		{"尺寸过大", "image/gif", huge, 0, 0, image.Point{}, "尺寸过大"},
+		{"GIF帧数过多", "image/gif", encodeTestAnimation(t, 5000, 5000, 1, 3), 0, 0, image.Point{}, "GIF动画过大"},
+		{"内容损坏", "image/png", []byte("\x89PNG\r\n\x1a\n损坏"), 0, 0, image.Point{}, "无法识别"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := processImage(tt.data, tt.contentType)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("错误为%v，期望包含%q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Width != tt.w || result.Height != tt.h {
+				t.Fatalf("尺寸为%dx%d", result.Width, result.Height)
+			}
+			// 重新编码后的原图与检测出的类型一致，且不再带EXIF
+			if got := detectContentType(result.Data); got != tt.contentType {
+				t.Fatalf("原图类型为%s", got)
+			}
+			if bytes.Contains(result.Data, []byte("Exif\x00\x00")) {
+				t.Fatal("原图中仍有EXIF")
+			}
+
+			if tt.thumbnail == (image.Point{}) {
+				if result.Thumbnail != nil {
+					t.Fatal("小图生成了缩略图")
+				}
+				return
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(result.Thumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if image.Pt(cfg.Width, cfg.Height) != tt.thumbnail {
+				t.Fatalf("缩略图尺寸为%dx%d，期望%v", cfg.Width, cfg.Height, tt.thumbnail)
+			}
+			want := "png"
+			if tt.contentType == "image/jpeg" {
+				want = "jpeg"
+			}
+			if format != want {
+				t.Fatalf("缩略图格式为%s，期望%s", format, want)
+			}
+		})
+	}
+}
+
+func TestGIFPixels(t *testing.T) {
+	anim := encodeTestAnimation(t, 100, 50, 10, 4)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"单帧（带全局颜色表）", encodeTestImage(t, "image/gif", 30, 20), 30 * 20},
+		{"动画按画布面积×帧数计算（带扩展块）", anim, 100 * 50 * 4},
+		{"数据截断", anim[:len(anim)/2], 100 * 50 * 2},
+		{"不是GIF", []byte("GIF"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gifPixels(tt.data); got != tt.want {
+				t.Fatalf("像素数为%d，期望%d", got, tt.want)
+			}
+		})
+	}
+
+	// 超过上限后提前停止遍历
+	if got := gifPixels(encodeTestAnimation(t, 5000, 5000, 1, 10)); got > 2*maxImagePixels {
+		t.Fatalf("超过上限后仍继续计数: %d", got)
+	}
+}
+
+func TestProcessAvatar(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		w, h        int
+		size        int
+		format      string
+	}{
+		{"大图缩放到头像尺寸", "image/jpeg", 600, 400, avatarSize, "jpeg"},
+		{"小图只裁剪不放大", "image/png", 300, 200, 200, "png"},
+		{"GIF输出PNG", "image/gif", 100, 300, 100, "png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := processAvatar(encodeTestImage(t, tt.contentType, tt.w, tt.h), tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.size || cfg.Height != tt.size || format != tt.format {
+				t.Fatalf("头像为%dx%d %s", cfg.Width, cfg.Height, format)
+			}
+		})
+	}
+}
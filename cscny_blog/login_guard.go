@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// === 登录防暴力破解 ===
+// 分别按用户名和客户端IP统计连续登录失败次数：失败次数达到阈值的一半后开始指数退避
+// （每次需等待loginBackoffBase×2^n才能再次尝试），达到阈值后锁定loginLockoutDuration。
+// 不存在的用户名同样计数、同样执行一次bcrypt比较，响应内容和耗时与密码错误一致，避免暴露用户是否存在。
+// 登录成功只清除该用户名的计数；IP的计数在loginFailureWindow内没有新的失败后自动失效。
+
+const loginBackoffBase = time.Second
+
+// 登录防护参数（由配置加载）
+var (
+	loginMaxFailures     = 5                // 同一用户名连续失败多少次后锁定
+	loginIPMaxFailures   = 20               // 同一IP连续失败多少次后锁定
+	loginLockoutDuration = 15 * time.Minute // 锁定时长（也是退避等待的上限）
+	loginFailureWindow   = time.Hour        // 超过此时间没有新的失败则重新计数
+)
+
+// 计数范围
+const (
+	loginScopeUser = "user"
+	loginScopeIP   = "ip"
+)
+
+var errInvalidCredentials = errors.New("用户名或密码错误")
+
+// loginBlockedError 因失败次数过多暂时不允许登录
+type loginBlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *loginBlockedError) Error() string {
+	return fmt.Sprintf("登录失败次数过多，请%s后再试", humanizeWait(e.RetryAfter))
+}
+
+// LoginFailure 登录失败计数
+type LoginFailure struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Scope        string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_failure_key,priority:1" json:"scope"`   // user或ip
+	Target       string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_login_failure_key,priority:2" json:"target"` // 小写用户名或IP
+	Failures     int        `gorm:"not null" json:"failures"`
+	LastFailedAt time.Time  `gorm:"index;not null" json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"` // 达到阈值后的锁定截止时间
+}
+
+// blockedFor 距离允许再次尝试还需等待的时间（0表示可以尝试）
+func (f *LoginFailure) blockedFor(now time.Time) time.Duration {
+	if f.LockedUntil != nil && now.Before(*f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+	if now.Sub(f.LastFailedAt) > loginFailureWindow {
+		return 0
+	}
+	wait := loginBackoff(f.Failures, loginThreshold(f.Scope))
+	if until := f.LastFailedAt.Add(wait); now.Before(until) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+func loginThreshold(scope string) int {
+	if scope == loginScopeIP {
+		return loginIPMaxFailures
+	}
+	return loginMaxFailures
+}
+
+// loginBackoff 连续失败failures次后需等待的时间：阈值一半以内不等待，之后每次翻倍，不超过锁定时长
+func loginBackoff(failures, threshold int) time.Duration {
+	n := failures - threshold/2
+	if n < 0 {
+		return 0
+	}
+	wait := time.Duration(float64(loginBackoffBase) * math.Pow(2, float64(n)))
+	if wait <= 0 || wait > loginLockoutDuration {
+		return loginLockoutDuration
+	}
+	return wait
+}
+
+// humanizeWait 将时长转换为“N秒”“N分钟”“N小时”“N天”（向上取整）
+func humanizeWait(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d秒", int(math.Ceil(d.Seconds())))
+	case d < time.Hour:
+		return fmt.Sprintf("%d分钟", int(math.Ceil(d.Minutes())))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d小时", int(math.Ceil(d.Hours())))
+	}
+	return fmt.Sprintf("%d天", int(math.Ceil(d.Hours()/24)))
+}
+
+// normalizeLoginKey 用户名不区分大小写计数，防止变换大小写绕过
+func normalizeLoginKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// securityEvent 记录安全相关事件，统一前缀便于检索和告警
+func securityEvent(event string, format string, args ...interface{}) {
+	logger.Printf("[安全] %s "+format, append([]interface{}{event}, args...)...)
+}
+
+// loginGuard 登录校验与失败计数
+type loginGuard struct {
+	db    *gorm.DB
+	users UserRepository
+
+	dummyOnce sync.Once
+	dummyHash []byte // 不存在的用户名也与此哈希比较，保证耗时一致
+}
+
+func newLoginGuard(db *gorm.DB, users UserRepository) *loginGuard {
+	return &loginGuard{db: db, users: users}
+}
+
+// dummyPasswordHash 按当前配置的bcrypt成本生成（首次使用时计算）
+func (g *loginGuard) dummyPasswordHash() []byte {
+	g.dummyOnce.Do(func() {
+		token, _ := randomToken(16)
+		g.dummyHash, _ = bcrypt.GenerateFromPassword([]byte(token), bcryptCost)
+	})
+	return g.dummyHash
+}
+
+// authenticate 校验用户名和密码；返回errInvalidCredentials、*loginBlockedError或其他内部错误
+func (g *loginGuard) authenticate(username, password, ip string) (*User, error) {
+	key := normalizeLoginKey(username)
+	now := time.Now()
+
+	// 已被退避或锁定时不校验密码，避免继续被用来猜测
+	for _, scope := range []struct{ scope, key string }{{loginScopeUser, key}, {loginScopeIP, ip}} {
+		wait, err := g.blockedFor(scope.scope, scope.key, now)
+		if err != nil {
+			return nil, err
+		}
+		if wait > 0 {
+			securityEvent("login_blocked", "scope=%s username=%q ip=%s retry_after=%s", scope.scope, username, ip, wait.Round(time.Second))
+			return nil, &loginBlockedError{RetryAfter: wait}
+		}
+	}
+
+	user, err := g.users.FindByUsername(username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	hash := g.dummyPasswordHash()
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		if err := g.recordFailure(username, key, ip, now); err != nil {
+			logger.Printf("记录登录失败次数失败: %v", err)
+		}
+		return nil, errInvalidCredentials
+	}
+
+	if err := g.db.Where("scope = ? AND target = ?", loginScopeUser, key).Delete(&LoginFailure{}).Error; err != nil {
+		logger.Printf("清除登录失败次数失败: %v", err)
+	}
+	return user, nil
+}
+
+// blockedFor 查询某个范围当前需要等待的时间
+func (g *loginGuard) blockedFor(scope, key string, now time.Time) (time.Duration, error) {
+	var f LoginFailure
+	if err := g.db.Where("scope = ? AND target = ?", scope, key).First(&f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return f.blockedFor(now), nil
+}
+
+// recordFailure 用户名和IP的失败次数各加一，达到阈值时锁定
+func (g *loginGuard) recordFailure(username, key, ip string, now time.Time) error {
+	securityEvent("login_failed", "username=%q ip=%s", username, ip)
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		for _, scope := range []struct{ scope, key string }{{loginScopeUser, key}, {loginScopeIP, ip}} {
+			var f LoginFailure
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("scope = ? AND target = ?", scope.scope, scope.key).First(&f).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if f.ID == 0 || now.Sub(f.LastFailedAt) > loginFailureWindow {
+				f = LoginFailure{ID: f.ID, Scope: scope.scope, Target: scope.key}
+			}
+			f.Failures++
+			f.LastFailedAt = now
+			if f.Failures >= loginThreshold(scope.scope) { // 锁定到期后再失败一次即重新锁定
+				until := now.Add(loginLockoutDuration)
+				f.LockedUntil = &until
+				securityEvent("login_locked", "scope=%s target=%q failures=%d until=%s", scope.scope, scope.key, f.Failures, until.Format(time.RFC3339))
+			}
+			if err := tx.Save(&f).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// listLocked 当前处于锁定状态的记录
+func (g *loginGuard) listLocked() ([]LoginFailure, error) {
+	list := []LoginFailure{}
+	err := g.db.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&list).Error
+	return list, err
+}
+
+// unlock 清除计数和锁定；返回是否存在该记录
+func (g *loginGuard) unlock(scope, key string) (bool, error) {
+	result := g.db.Where("scope = ? AND target = ?", scope, key).Delete(&LoginFailure{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// purgeLoop 定期清理已失效的计数（在独立goroutine中运行）
+func (g *loginGuard) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if err := g.db.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-loginFailureWindow), now).
+			Delete(&LoginFailure{}).Error; err != nil {
+			logger.Printf("清理登录失败记录失败: %v", err)
+		}
+	}
+}
+
+// === 管理员Handler ===
+// 当前被锁定的用户名和IP
+func listLoginLockoutsHandler(guard *loginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := guard.listLocked()
+		if err != nil {
+			logger.Printf("查询登录锁定失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": list})
+	}
+}
+
+// 解除用户的登录锁定
+func unlockUserHandler(users UserRepository, guard *loginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, err := users.FindByID(userID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解锁失败"})
+			return
+		}
+		unlockLogin(c, guard, loginScopeUser, normalizeLoginKey(user.Username))
+	}
+}
+
+// 解除IP的登录锁定
+func unlockIPHandler(guard *loginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		unlockLogin(c, guard, loginScopeIP, c.Param("ip"))
+	}
+}
+
+func unlockLogin(c *gin.Context, guard *loginGuard, scope, key string) {
+	found, err := guard.unlock(scope, key)
+	if err != nil {
+		logger.Printf("解除登录锁定失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解锁失败"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有登录失败记录"})
+		return
+	}
+
+	operatorId, _ := c.Get("userId")
+	securityEvent("login_unlocked", "scope=%s target=%q operator=%d", scope, key, operatorId)
+	c.JSON(http.StatusOK, gin.H{"message": "已解除锁定"})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures  int
+		threshold int
+		want      time.Duration
+	}{
+		{0, 5, 0},
+		{1, 5, 0},
+		{2, 5, time.Second},
+		{3, 5, 2 * time.Second},
+		{4, 5, 4 * time.Second},
+		{10, 20, time.Second},
+		{30, 5, loginLockoutDuration},
+		{2000, 5, loginLockoutDuration}, // 溢出时按锁定时长
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures, tt.threshold); got != tt.want {
+			t.Fatalf("loginBackoff(%d, %d) = %v，期望%v", tt.failures, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestHumanizeWait(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{500 * time.Millisecond, "1秒"},
+		{59 * time.Second, "59秒"},
+		{time.Minute, "1分钟"},
+		{61 * time.Second, "2分钟"},
+		{15 * time.Minute, "15分钟"},
+		{90 * time.Minute, "2小时"},
+		{47 * time.Hour, "47小时"},
+		{49 * time.Hour, "3天"},
+	}
+	for _, tt := range tests {
+		if got := humanizeWait(tt.d); got != tt.want {
+			t.Fatalf("humanizeWait(%v) = %s，期望%s", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestLoginFailureBlockedFor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(10 * time.Minute)
+	earlier := now.Add(-time.Minute)
+	tests := []struct {
+		name    string
+		failure LoginFailure
+		want    time.Duration
+	}{
+		{"未到退避次数", LoginFailure{Scope: loginScopeUser, Failures: 1, LastFailedAt: now}, 0},
+		{"退避中", LoginFailure{Scope: loginScopeUser, Failures: 3, LastFailedAt: now.Add(-time.Second)}, time.Second},
+		{"退避已结束", LoginFailure{Scope: loginScopeUser, Failures: 3, LastFailedAt: now.Add(-2 * time.Second)}, 0},
+		{"IP阈值更高", LoginFailure{Scope: loginScopeIP, Failures: 3, LastFailedAt: now}, 0},
+		{"锁定中", LoginFailure{Scope: loginScopeUser, Failures: 5, LastFailedAt: now, LockedUntil: &later}, 10 * time.Minute},
+		{"锁定到期后仍需退避", LoginFailure{Scope: loginScopeUser, Failures: 5, LastFailedAt: now.Add(-5 * time.Second), LockedUntil: &earlier}, 3 * time.Second},
+		{"超出计数窗口", LoginFailure{Scope: loginScopeUser, Failures: 4, LastFailedAt: now.Add(-loginFailureWindow - time.Second)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.failure.blockedFor(now); got != tt.want {
+				t.Fatalf("需等待%v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ageLoginFailures 将登录失败记录整体提前d，模拟时间流逝
+func (a *testApp) ageLoginFailures(d time.Duration) {
+	a.t.Helper()
+	var list []LoginFailure
+	if err := a.db.Find(&list).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	for _, f := range list {
+		f.LastFailedAt = f.LastFailedAt.Add(-d)
+		if f.LockedUntil != nil {
+			until := f.LockedUntil.Add(-d)
+			f.LockedUntil = &until
+		}
+		if err := a.db.Save(&f).Error; err != nil {
+			a.t.Fatal(err)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Auth.LoginMaxFailures = 3 })
+	_, admin := app.newUser("admin", roleAdmin)
+	aliceID := app.register("alice")
+
+	login := func(username, password string) gin.H {
+		return gin.H{"username": username, "password": password}
+	}
+	steps := []struct {
+		name       string
+		body       gin.H
+		age        time.Duration // 请求前模拟经过的时间
+		status     int
+		retryAfter string
+	}{
+		{"密码错误", login("alice", "wrong"), 0, http.StatusUnauthorized, ""},
+		{"立即重试需退避", login("alice", testPassword), 0, http.StatusTooManyRequests, "1"},
+		{"退避结束后再次错误", login("alice", "wrong"), 2 * time.Second, http.StatusUnauthorized, ""},
+		{"达到阈值后锁定", login("ALICE", "wrong"), time.Hour / 2, http.StatusUnauthorized, ""},
+		{"锁定后正确密码也被拒绝", login("alice", testPassword), 0, http.StatusTooManyRequests, strconv.Itoa(int(loginLockoutDuration.Seconds()))},
+		{"变换大小写同样被锁定", login(" Alice ", testPassword), time.Minute, http.StatusTooManyRequests, strconv.Itoa(int((loginLockoutDuration - time.Minute).Seconds()))},
+		{"不存在的用户与密码错误响应一致", login("nobody", "wrong"), 0, http.StatusUnauthorized, ""},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			app.ageLoginFailures(step.age)
+			w := app.request(http.MethodPost, "/api/public/auth/login", step.body, "")
+			expect(t, w, step.status, nil)
+			if got := w.Header().Get("Retry-After"); got != step.retryAfter {
+				t.Fatalf("Retry-After为%q，期望%q", got, step.retryAfter)
+			}
+		})
+	}
+
+	// 网页登录同样被锁定
+	web := app.newWebClient()
+	expectPage(t, web.post("/login", url.Values{"username": {"alice"}, "password": {testPassword}}), http.StatusTooManyRequests)
+
+	// 管理员查看并解除锁定
+	var lockouts listResponse[LoginFailure]
+	expect(t, app.request(http.MethodGet, "/api/admin/login-lockouts", nil, admin), http.StatusOK, &lockouts)
+	if len(lockouts.Data) != 1 || lockouts.Data[0].Target != "alice" || lockouts.Data[0].Failures != 3 {
+		t.Fatalf("锁定列表为%+v", lockouts.Data)
+	}
+	_, bob := app.newUser("bob")
+	unlock := fmt.Sprintf("/api/admin/users/%d/unlock", aliceID)
+	expect(t, app.request(http.MethodPost, unlock, nil, bob), http.StatusForbidden, nil)
+	expect(t, app.request(http.MethodPost, unlock, nil, admin), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPost, unlock, nil, admin), http.StatusNotFound, nil)
+	expect(t, app.request(http.MethodPost, "/api/admin/users/9999/unlock", nil, admin), http.StatusNotFound, nil)
+	app.login("alice")
+
+	// 登录成功不清除IP的计数，IP由管理员单独解锁
+	ipPath := "/api/admin/login-lockouts/ips/" + url.PathEscape(testQueryContext("").ClientIP())
+	expect(t, app.request(http.MethodDelete, ipPath, nil, admin), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, ipPath, nil, admin), http.StatusNotFound, nil)
+}
+
+func TestLoginIPLockout(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Auth.LoginIPMaxFailures = 4 })
+	app.register("alice")
+
+	// 同一IP尝试不同用户名，达到IP阈值后所有用户都无法登录
+	for i := 0; i < 4; i++ {
+		app.ageLoginFailures(time.Minute)
+		expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": fmt.Sprintf("user%d", i), "password": "wrong"}, ""), http.StatusUnauthorized, nil)
+	}
+	w := app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": testPassword}, "")
+	expect(t, w, http.StatusTooManyRequests, nil)
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("缺少Retry-After")
+	}
+}
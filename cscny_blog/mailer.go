@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// === 邮件发送 ===
+// 业务代码只依赖Mailer接口：生产环境使用SMTP，本地开发时写入outbox目录（每封邮件一个.eml文件，可直接用邮件客户端打开）。
+
+// 邮件发送方式
+const (
+	mailDriverSMTP   = "smtp"
+	mailDriverOutbox = "outbox"
+)
+
+// Mail 一封纯文本邮件
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送
+type Mailer interface {
+	Send(m Mail) error
+}
+
+// newMailer 按配置创建邮件发送实现
+func newMailer(cfg MailConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("发件人地址格式不正确: %w", err)
+	}
+	switch cfg.Driver {
+	case mailDriverSMTP:
+		return &smtpMailer{
+			addr:     net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(cfg.SMTP.Port)),
+			host:     cfg.SMTP.Host,
+			username: cfg.SMTP.Username,
+			password: cfg.SMTP.Password,
+			from:     from.String(),
+			envelope: from.Address,
+		}, nil
+	case mailDriverOutbox:
+		if err := os.MkdirAll(cfg.OutboxDir, 0o755); err != nil {
+			return nil, err
+		}
+		return &outboxMailer{dir: cfg.OutboxDir, from: from.String()}, nil
+	}
+	return nil, fmt.Errorf("不支持的邮件发送方式: %s", cfg.Driver)
+}
+
+// buildMessage 生成RFC 5322格式的邮件（标题按RFC 2047编码，正文base64编码，支持中文）
+func buildMessage(from string, m Mail, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(m.Body))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+	return buf.Bytes()
+}
+
+// validMailHeader 收件人中不允许出现换行，防止注入额外的邮件头
+func validMailHeader(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("邮件地址包含非法字符: %q", s)
+	}
+	return nil
+}
+
+// smtpMailer 通过SMTP服务器发送（服务器支持时自动使用STARTTLS）
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string // 邮件头中的发件人（可含显示名）
+	envelope string // SMTP信封中的发件地址
+}
+
+func (s *smtpMailer) Send(m Mail) error {
+	if err := validMailHeader(m.To); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+	return smtp.SendMail(s.addr, auth, s.envelope, []string{m.To}, buildMessage(s.from, m, time.Now()))
+}
+
+// outboxMailer 将邮件写入本地目录（开发、测试用）
+type outboxMailer struct {
+	dir  string
+	from string
+}
+
+func (o *outboxMailer) Send(m Mail) error {
+	if err := validMailHeader(m.To); err != nil {
+		return err
+	}
+	now := time.Now()
+	suffix, err := randomToken(4)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(o.dir, now.Format("20060102-150405.000")+"-"+suffix+".eml")
+	if err := os.WriteFile(name, buildMessage(o.from, m, now), 0o600); err != nil {
+		return err
+	}
+	logger.Printf("邮件已写入%s（收件人%s）", name, m.To)
+	return nil
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
@@ -16,28 +17,60 @@ import (
 
 // === 全局配置 ===
 var (
-	jwtSecret = []byte(os.Getenv("JWT_SECRET"))                             // JWT密钥（环境变量获取）
-	logger    = log.New(os.Stdout, "[Blog] ", log.LstdFlags|log.Lshortfile) // 日志实例
+	jwtSecret  []byte                                                        // JWT密钥（由配置加载，见config.go）
+	bcryptCost = bcrypt.DefaultCost                                          // 密码哈希成本（由配置加载）
+	logger     = log.New(os.Stdout, "[Blog] ", log.LstdFlags|log.Lshortfile) // 日志实例
 )
 
 // === 数据模型 ===
 // User 用户模型
 type User struct {
 	gorm.Model
-	Username string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"` // 用户名（唯一）
-	Password string    `gorm:"not null" json:"-"`                                     // 密码哈希（json:-表示不返回）
-	Posts    []Post    `gorm:"foreignKey:UserID" json:"-"`                            // 关联文章
-	Comments []Comment `gorm:"foreignKey:UserID" json:"-"`                            // 关联评论
+	Username string     `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"` // 用户名（唯一）
+	Password string     `gorm:"not null" json:"-"`                                     // 密码哈希（json:-表示不返回）
+	Posts    []Post     `gorm:"foreignKey:UserID" json:"-"`                            // 关联文章
+	Comments []Comment  `gorm:"foreignKey:UserID" json:"-"`                            // 关联评论
+	Roles    []UserRole `gorm:"foreignKey:UserID" json:"-"`                            // 关联角色
+
+	Email           *string    `gorm:"type:varchar(255);uniqueIndex" json:"-"` // 邮箱（小写，老用户可能为空），见account_email.go
+	EmailVerifiedAt *time.Time `json:"-"`                                      // 邮箱验证时间（未验证为null）
+
+	Bio       string `gorm:"type:varchar(500);not null;default:''" json:"-"` // 个人简介，见profile.go
+	AvatarKey string `gorm:"type:varchar(255);not null;default:''" json:"-"` // 头像的存储键（为空表示未设置）
+	AvatarURL string `gorm:"-" json:"avatar_url,omitempty"`                  // 头像访问地址
+
+	DeletionScheduledAt *time.Time `gorm:"index" json:"-"` // 计划注销时间（未申请为null），见account_data.go
+}
+
+// AfterFind 根据存储键填充头像地址
+func (u *User) AfterFind(*gorm.DB) error {
+	if u.AvatarKey != "" {
+		u.AvatarURL = uploadURLPrefix + u.AvatarKey
+	}
+	return nil
 }
 
 // Post 文章模型
 type Post struct {
 	gorm.Model
-	Title    string    `gorm:"type:varchar(100);not null" json:"title"` // 标题
-	Content  string    `gorm:"type:text;not null" json:"content"`       // 内容
-	UserID   uint      `gorm:"not null" json:"user_id"`                 // 作者ID（外键）
-	User     User      `gorm:"foreignKey:UserID" json:"author"`         // 作者信息（关联用户）
-	Comments []Comment `gorm:"foreignKey:PostID" json:"comments"`       // 关联评论
+	Title    string    `gorm:"type:varchar(100);not null" json:"title"`                            // 标题
+	Slug     string    `gorm:"type:varchar(100);uniqueIndex" json:"slug"`                          // 永久链接标识，由标题生成，见post_slug.go
+	Content  string    `gorm:"type:text;not null" json:"content"`                                  // 内容
+	UserID   uint      `gorm:"not null;index:idx_post_author_published,priority:1" json:"user_id"` // 作者ID（外键）
+	User     User      `gorm:"foreignKey:UserID" json:"author"`                                    // 作者信息（关联用户）
+	Comments []Comment `gorm:"foreignKey:PostID" json:"comments"`                                  // 关联评论
+	Tags     []Tag     `gorm:"many2many:post_tags" json:"tags"`                                    // 关联标签
+
+	Attachments []Attachment `gorm:"foreignKey:PostID" json:"attachments,omitempty"` // 附件（仅详情接口返回），见attachments.go
+
+	Status      string     `gorm:"type:varchar(20);not null;default:published;index" json:"status"` // 状态，见post_status.go
+	PublishAt   *time.Time `gorm:"index" json:"publish_at"`                                         // 定时发布时间
+	PublishedAt *time.Time `gorm:"index:idx_post_author_published,priority:2" json:"published_at"`  // 首次发布时间（与作者ID组成索引，供作者文章和个人动态查询）
+
+	CommentCount int64          `gorm:"->;-:migration" json:"comment_count"` // 评论数（只读，查询时由子查询填充）
+	ContentHTML  string         `gorm:"-" json:"content_html,omitempty"`     // 内容渲染后的HTML（仅详情接口返回）
+	Reactions    ReactionCounts `gorm:"-" json:"reactions"`                  // 各种回应数，见reactions.go
+	MyReactions  []string       `gorm:"-" json:"my_reactions,omitempty"`     // 当前登录用户的回应
 }
 
 // Comment 评论模型
@@ -48,10 +81,23 @@ type Comment struct {
 	PostID  uint   `gorm:"not null" json:"post_id"`           // 文章ID（外键）
 	User    User   `gorm:"foreignKey:UserID" json:"author"`   // 评论者信息（关联用户）
 	Post    Post   `gorm:"foreignKey:PostID" json:"-"`        // 关联文章（不返回）
+
+	ParentID *uint     `gorm:"index" json:"parent_id"`              // 父评论ID（为空表示根评论）
+	RootID   uint      `gorm:"index" json:"root_id"`                // 所在楼层的根评论ID
+	Depth    int       `gorm:"not null;default:0" json:"depth"`     // 深度（根评论为0）
+	Path     string    `gorm:"type:varchar(255);index" json:"path"` // 物化路径，见comment_tree.go
+	Replies  []Comment `gorm:"-" json:"replies,omitempty"`          // 子回复（树形返回时填充）
+	Deleted  bool      `gorm:"-" json:"deleted,omitempty"`          // 是否为已删除评论的占位符
+
+	EditedAt    *time.Time `json:"edited_at"`                         // 最后编辑时间（未编辑过为null）
+	DeletedByID *uint      `gorm:"index" json:"deleted_by,omitempty"` // 删除操作人ID（软删除后供版主审核）
+
+	Reactions   ReactionCounts `gorm:"-" json:"reactions"`              // 各种回应数
+	MyReactions []string       `gorm:"-" json:"my_reactions,omitempty"` // 当前登录用户的回应
 }
 
 // === 认证中间件（已实现，直接复用） ===
-func authMiddleware() gin.HandlerFunc {
+func authMiddleware(tokens *tokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -89,77 +135,196 @@ func authMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		userId := uint(claims["sub"].(float64))
-		c.Set("userId", userId)
+		sub, okSub := claims["sub"].(float64)
+		jti, okJti := claims["jti"].(string)
+		exp, okExp := claims["exp"].(float64)
+		if !okSub || !okJti || !okExp || claims["typ"] != "access" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token载荷"})
+			c.Abort()
+			return
+		}
+
+		// 检查吊销列表
+		revoked, err := tokens.isRevoked(jti)
+		if err != nil {
+			logger.Printf("查询令牌吊销状态失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "认证失败，请重试"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token已被吊销"})
+			c.Abort()
+			return
+		}
+
+		c.Set("userId", uint(sub))
+		c.Set("roles", claimStrings(claims["roles"]))
+		c.Set("permissions", claimStrings(claims["perms"]))
+		c.Set("jti", jti)
+		c.Set("tokenExp", time.Unix(int64(exp), 0))
 		c.Next()
 	}
 }
 
+// optionalAuthMiddleware 公开接口使用：携带token时按authMiddleware校验并设置用户信息，未携带时匿名访问
+func optionalAuthMiddleware(tokens *tokenStore) gin.HandlerFunc {
+	auth := authMiddleware(tokens)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// claimStrings 将JWT中的字符串数组载荷转换为[]string
+func claimStrings(v interface{}) []string {
+	items, _ := v.([]interface{})
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
 // === 文章管理功能 ===
 // 创建文章（需认证）
-func createPostHandler(db *gorm.DB) gin.HandlerFunc {
+func createPostHandler(posts PostRepository, revisions RevisionRepository, index *searchIndex) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, _ := c.Get("userId") // 从上下文获取当前用户ID
 
 		var input struct {
-			Title   string `json:"title" binding:"required,min=1,max=100"` // 标题必填，1-100字
-			Content string `json:"content" binding:"required,min=10"`      // 内容必填，至少10字
+			Title   string   `json:"title" binding:"required,min=1,max=100"` // 标题必填，1-100字
+			Content string   `json:"content" binding:"required,min=10"`      // 内容必填，至少10字
+			Tags    []string `json:"tags"`                                   // 可选标签
+
+			Status    string     `json:"status" binding:"omitempty,oneof=draft scheduled published"` // 默认直接发布
+			PublishAt *time.Time `json:"publish_at"`                                                 // 定时发布时间（status为scheduled时必填）
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		tags, err := normalizeTags(input.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		post := Post{
 			Title:   input.Title,
 			Content: input.Content,
 			UserID:  userId.(uint), // 关联当前用户为作者
 		}
-		if err := db.Create(&post).Error; err != nil {
+		if input.Status == "" {
+			input.Status = postStatusPublished
+		}
+		if err := applyPostStatus(&post, input.Status, input.PublishAt, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := posts.Create(&post); err != nil {
 			logger.Printf("创建文章失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建文章失败"})
 			return
 		}
+		if len(tags) > 0 {
+			if err := posts.SetTags(&post, tags); err != nil {
+				logger.Printf("保存文章标签失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文章标签失败"})
+				return
+			}
+		}
+		if err := recordRevision(revisions, &post, post.UserID, []string{revisionFieldTitle, revisionFieldContent}, nil); err != nil {
+			logger.Printf("保存文章修订失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存修订记录失败"})
+			return
+		}
+
+		index.IndexPost(&post)
 
 		// 关联查询作者信息（返回给客户端）
-		db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username") // 只返回作者ID和用户名，避免敏感信息
-		}).First(&post)
+		created, err := posts.FindWithAuthor(post.ID)
+		if err != nil {
+			logger.Printf("查询文章失败: %v", err)
+			created = &post
+		}
 
-		c.JSON(http.StatusCreated, gin.H{"data": post})
+		c.JSON(http.StatusCreated, gin.H{"data": created})
 	}
 }
 
-// 获取所有文章列表（无需认证）
-func listPostsHandler(db *gorm.DB) gin.HandlerFunc {
+// 获取文章列表（无需认证）
+// 查询参数：limit、cursor、sort(created_at/updated_at/comment_count)、order(asc/desc)、
+// author_id、author（用户名）、from/to（创建日期范围）、title（标题包含）、
+// status（默认published；查询其他状态需登录，且只返回自己的文章）
+func listPostsHandler(posts PostRepository, reactions ReactionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var posts []Post
-		// 预加载作者信息（只返回ID和用户名）
-		if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).Find(&posts).Error; err != nil {
+		query, err := parsePostQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query.Status = c.DefaultQuery("status", postStatusPublished)
+		if !isValidPostStatus(query.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文章状态: " + query.Status})
+			return
+		}
+		if query.Status != postStatusPublished && !hasPermission(c, permPostEditAny) {
+			userId, ok := c.Get("userId")
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "查看未发布的文章需要登录"})
+				return
+			}
+			query.AuthorID = userId.(uint)
+			query.AuthorName = ""
+		}
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Printf("查询文章列表失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
 			return
 		}
+		if err := attachPostReactions(c, reactions, list); err != nil {
+			logger.Printf("查询文章回应失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{"data": posts})
+		c.JSON(http.StatusOK, gin.H{"data": list, "pagination": page})
 	}
 }
 
 // 获取单篇文章详情（无需认证）
-func getPostHandler(db *gorm.DB) gin.HandlerFunc {
+// 路径参数可以是文章ID或slug；使用改名前的旧slug访问时301跳转到新slug
+func getPostHandler(posts PostRepository, reactions ReactionRepository, renderer *contentRenderer) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		postID := c.Param("id") // 从URL参数获取文章ID
-
-		var post Post
-		// 预加载作者信息和评论列表（评论需预加载评论者信息）
-		if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).Preload("Comments.User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).First(&post, postID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+		key := c.Param("id") // 文章ID或slug
+		post, err := lookupPost(posts, key)
+		if err == nil {
+			if !canViewPost(c, post) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+				return
+			}
+			if isOldSlug(key, post) {
+				redirectToSlug(c, key, post.Slug)
+				return
+			}
+			// 包含作者信息和评论列表（评论含评论者信息）
+			post, err = posts.FindWithComments(post.ID)
+		}
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 				return
 			}
@@ -167,20 +332,35 @@ func getPostHandler(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
 			return
 		}
+		if post.ContentHTML, err = renderer.Render(post); err != nil {
+			logger.Printf("渲染文章内容失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "渲染文章失败"})
+			return
+		}
+		if err := attachPostDetailReactions(c, reactions, post); err != nil {
+			logger.Printf("查询文章回应失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+		post.Comments = buildCommentTree(post.Comments)
 
 		c.JSON(http.StatusOK, gin.H{"data": post})
 	}
 }
 
-// 更新文章（仅作者可操作）
-func updatePostHandler(db *gorm.DB) gin.HandlerFunc {
+// 更新文章（作者或拥有post:edit_any权限的用户可操作）
+func updatePostHandler(posts PostRepository, revisions RevisionRepository, index *searchIndex, renderer *contentRenderer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, _ := c.Get("userId")
-		postID := c.Param("id")
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		var post Post
-		if err := db.First(&post, postID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 				return
 			}
@@ -189,53 +369,96 @@ func updatePostHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// 验证当前用户是否为作者
-		if post.UserID != userId.(uint) {
+		// 验证当前用户是否为作者或拥有编辑任意文章的权限
+		if post.UserID != userId.(uint) && !hasPermission(c, permPostEditAny) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限修改此文章"})
 			return
 		}
 
 		var input struct {
-			Title   string `json:"title" binding:"omitempty,min=1,max=100"` // 可选更新，1-100字
-			Content string `json:"content" binding:"omitempty,min=10"`      // 可选更新，至少10字
+			Title   string    `json:"title" binding:"omitempty,min=1,max=100"` // 可选更新，1-100字
+			Content string    `json:"content" binding:"omitempty,min=10"`      // 可选更新，至少10字
+			Tags    *[]string `json:"tags"`                                    // 可选更新，传空数组表示清空标签
+
+			Status    string     `json:"status" binding:"omitempty,oneof=draft scheduled published archived"` // 可选，状态变更需符合发布流程
+			PublishAt *time.Time `json:"publish_at"`                                                          // 定时发布时间
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		var tags []Tag
+		if input.Tags != nil {
+			if tags, err = normalizeTags(*input.Tags); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
 		// 只更新非空字段
+		oldTitle, oldContent := post.Title, post.Content
 		if input.Title != "" {
 			post.Title = input.Title
 		}
 		if input.Content != "" {
 			post.Content = input.Content
 		}
+		if input.Status != "" || input.PublishAt != nil {
+			status := input.Status
+			if status == "" {
+				status = post.Status // 仅修改定时发布时间
+			}
+			if err := applyPostStatus(post, status, input.PublishAt, time.Now()); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
-		if err := db.Save(&post).Error; err != nil {
+		if err := posts.Update(post); err != nil {
 			logger.Printf("更新文章失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新文章失败"})
 			return
 		}
+		if input.Tags != nil {
+			if err := posts.SetTags(post, tags); err != nil {
+				logger.Printf("更新文章标签失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "更新文章标签失败"})
+				return
+			}
+		}
+		// 标题或内容有变化时保存修订
+		if changes := changedFields(oldTitle, oldContent, post); len(changes) > 0 {
+			if err := recordRevision(revisions, post, userId.(uint), changes, nil); err != nil {
+				logger.Printf("保存文章修订失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "保存修订记录失败"})
+				return
+			}
+		}
+		index.IndexPost(post)
+		renderer.Invalidate(post.ID)
 
 		// 关联作者信息返回
-		db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).First(&post, postID)
+		if updated, err := posts.FindWithAuthor(postID); err == nil {
+			post = updated
+		}
 
 		c.JSON(http.StatusOK, gin.H{"data": post})
 	}
 }
 
-// 删除文章（仅作者可操作）
-func deletePostHandler(db *gorm.DB) gin.HandlerFunc {
+// 删除文章（作者或拥有post:delete_any权限的用户可操作）
+func deletePostHandler(posts PostRepository, attachments AttachmentRepository, storage Storage, index *searchIndex, renderer *contentRenderer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, _ := c.Get("userId")
-		postID := c.Param("id")
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		var post Post
-		if err := db.First(&post, postID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 				return
 			}
@@ -245,24 +468,28 @@ func deletePostHandler(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		// 验证权限
-		if post.UserID != userId.(uint) {
+		if post.UserID != userId.(uint) && !hasPermission(c, permPostDeleteAny) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限删除此文章"})
 			return
 		}
 
-		// 级联删除评论（或在数据库设置外键级联删除）
-		if err := db.Where("post_id = ?", postID).Delete(&Comment{}).Error; err != nil {
-			logger.Printf("删除评论失败: %v", err)
+		// 附件记录随文章删除，文件在删除成功后清理
+		files, err := attachments.ListByPost(post.ID)
+		if err != nil {
+			logger.Printf("查询附件失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 			return
 		}
 
-		// 删除文章
-		if err := db.Delete(&post).Error; err != nil {
+		// 删除文章（仓储负责级联删除评论）
+		if err := posts.Delete(post); err != nil {
 			logger.Printf("删除文章失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 			return
 		}
+		deleteStoredFiles(storage, files)
+		index.RemovePost(post.ID)
+		renderer.Invalidate(post.ID)
 
 		c.JSON(http.StatusOK, gin.H{"message": "文章删除成功"})
 	}
@@ -270,15 +497,19 @@ func deletePostHandler(db *gorm.DB) gin.HandlerFunc {
 
 // === 评论功能 ===
 // 创建评论（需认证）
-func createCommentHandler(db *gorm.DB) gin.HandlerFunc {
+func createCommentHandler(posts PostRepository, comments CommentRepository, index *searchIndex, events *eventBus) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, _ := c.Get("userId")
-		postID := c.Param("post_id") // 从URL参数获取文章ID
+		postID, err := paramID(c, "id") // 从URL参数获取文章ID（与/posts/:id共用参数名，gin不允许同级不同名通配符）
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		// 验证文章是否存在
-		var post Post
-		if err := db.First(&post, postID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 				return
 			}
@@ -286,9 +517,18 @@ func createCommentHandler(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建评论失败"})
 			return
 		}
+		if !canViewPost(c, post) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return
+		}
+		if post.Status != postStatusPublished {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "文章未发布，不能评论"})
+			return
+		}
 
 		var input struct {
-			Content string `json:"content" binding:"required,min=1,max=500"` // 评论内容，1-500字
+			Content  string `json:"content" binding:"required,min=1,max=500"` // 评论内容，1-500字
+			ParentID *uint  `json:"parent_id"`                                // 回复的评论ID（可选）
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -300,36 +540,212 @@ func createCommentHandler(db *gorm.DB) gin.HandlerFunc {
 			UserID:  userId.(uint),
 			PostID:  post.ID,
 		}
-		if err := db.Create(&comment).Error; err != nil {
+
+		// 回复：父评论必须属于同一篇文章，且不超过最大深度
+		if input.ParentID != nil {
+			parent, err := comments.FindByID(*input.ParentID)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "回复的评论不存在"})
+					return
+				}
+				logger.Printf("查询父评论失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "创建评论失败"})
+				return
+			}
+			if parent.PostID != post.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "回复的评论不属于该文章"})
+				return
+			}
+			if parent.Depth+1 > maxCommentDepth {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("回复层级不能超过%d层", maxCommentDepth)})
+				return
+			}
+			comment.ParentID = &parent.ID
+		}
+		if err := comments.Create(&comment); err != nil {
 			logger.Printf("创建评论失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建评论失败"})
 			return
 		}
+		index.IndexComment(&comment)
+		events.Publish(eventCommentCreated, CommentEvent{Comment: &comment, Post: post, ActorID: comment.UserID})
 
 		// 关联评论者信息
-		db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).First(&comment)
+		created, err := comments.FindWithAuthor(comment.ID)
+		if err != nil {
+			logger.Printf("查询评论失败: %v", err)
+			created = &comment
+		}
 
-		c.JSON(http.StatusCreated, gin.H{"data": comment})
+		c.JSON(http.StatusCreated, gin.H{"data": created})
 	}
 }
 
 // 获取文章评论列表（无需认证）
-func listCommentsHandler(db *gorm.DB) gin.HandlerFunc {
+// 按根评论分页，每条根评论连同其全部回复一起返回
+// 查询参数：limit、cursor、order(asc/desc，默认asc)、format(tree/flat，默认tree)
+func listCommentsHandler(posts PostRepository, comments CommentRepository, reactions ReactionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		postID := c.Param("post_id")
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		var comments []Comment
-		if err := db.Where("post_id = ?", postID).Preload("User", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Username")
-		}).Find(&comments).Error; err != nil {
+		post, err := posts.FindByID(postID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评论失败"})
+			return
+		}
+		if post == nil || !canViewPost(c, post) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return
+		}
+
+		query, err := parseCommentQuery(c, postID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		format := c.DefaultQuery("format", "tree")
+		if format != "tree" && format != "flat" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format只能为tree或flat"})
+			return
+		}
+
+		list, page, err := comments.ListByPost(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Printf("查询评论列表失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评论失败"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": comments})
+		if err := attachCommentReactions(c, reactions, list); err != nil {
+			logger.Printf("查询评论回应失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询评论失败"})
+			return
+		}
+
+		tree := buildCommentTree(list)
+		if format == "flat" {
+			c.JSON(http.StatusOK, gin.H{"data": flattenCommentTree(tree), "pagination": page})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": tree, "pagination": page})
+	}
+}
+
+// 编辑评论（作者可在commentEditWindow时限内修改，拥有comment:edit_any权限的版主不受限）
+func updateCommentHandler(comments CommentRepository, index *searchIndex, events *eventBus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		commentID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		comment, err := comments.FindByID(commentID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
+				return
+			}
+			logger.Printf("查询评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新评论失败"})
+			return
+		}
+
+		// 作者只能在编辑时限内修改，拥有comment:edit_any权限的用户不受限
+		if !hasPermission(c, permCommentEditAny) {
+			if comment.UserID != userId.(uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "没有权限修改此评论"})
+				return
+			}
+			if !canEditComment(comment, time.Now()) {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("评论发布超过%s后不能再编辑", commentEditWindow)})
+				return
+			}
+		}
+
+		var input struct {
+			Content string `json:"content" binding:"required,min=1,max=500"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		comment.Content = input.Content
+		comment.EditedAt = &now
+		if err := comments.Update(comment); err != nil {
+			logger.Printf("更新评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新评论失败"})
+			return
+		}
+		index.IndexComment(comment)
+
+		if updated, err := comments.FindWithAuthor(commentID); err == nil {
+			comment = updated
+		}
+		events.Publish(eventCommentUpdated, CommentEvent{Comment: comment, ActorID: userId.(uint)})
+
+		c.JSON(http.StatusOK, gin.H{"data": comment})
+	}
+}
+
+// 删除评论（评论作者、文章作者或拥有comment:delete_any权限的用户可操作）
+// 软删除：记录操作人，内容保留供版主审核
+func deleteCommentHandler(posts PostRepository, comments CommentRepository, index *searchIndex, events *eventBus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		commentID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		comment, err := comments.FindByID(commentID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
+				return
+			}
+			logger.Printf("查询评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+			return
+		}
+
+		if comment.UserID != userId.(uint) && !hasPermission(c, permCommentDeleteAny) {
+			post, err := posts.FindByID(comment.PostID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				logger.Printf("查询文章失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+				return
+			}
+			if post == nil || post.UserID != userId.(uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "没有权限删除此评论"})
+				return
+			}
+		}
+
+		if err := comments.Delete(comment, userId.(uint)); err != nil {
+			logger.Printf("删除评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+			return
+		}
+		index.RemoveComment(comment.ID)
+		events.Publish(eventCommentDeleted, CommentEvent{Comment: comment, ActorID: userId.(uint)})
+
+		c.JSON(http.StatusOK, gin.H{"message": "评论删除成功"})
 	}
 }
 
@@ -352,54 +768,142 @@ func errorHandler() gin.HandlerFunc {
 }
 
 // === 路由设置 ===
-func setupRoutes(r *gin.Engine, db *gorm.DB) {
+func setupRoutes(r *gin.Engine, repos *Repositories, tokens *tokenStore, index *searchIndex, renderer *contentRenderer, events *eventBus, hub *commentHub, storage Storage, limiter RateLimitStore, guard *loginGuard, mails *accountMailer, accounts *accountService) {
 	r.Use(errorHandler()) // 全局错误处理中间件
+	limitAuth := rateLimit(limiter, authRateLimit)
+	limitComment := rateLimit(limiter, commentRateLimit)
+	limitWrite := rateLimit(limiter, writeRateLimit)
 
 	// 公开路由
 	public := r.Group("/api/public")
+	public.Use(optionalAuthMiddleware(tokens)) // 登录用户可看到自己未发布的文章
 	{
 		// 认证相关
-		public.POST("/auth/register", registerHandler(db))
-		public.POST("/auth/login", loginHandler(db))
+		public.POST("/auth/register", rateLimit(limiter, registerRateLimit), registerHandler(repos.Users, mails))
+		public.POST("/auth/login", limitAuth, loginHandler(guard, tokens))
+		public.POST("/auth/refresh", limitAuth, refreshHandler(tokens))
+		public.POST("/auth/verify-email", limitAuth, verifyEmailHandler(repos.Users, tokens))
+		public.POST("/auth/forgot-password", limitAuth, forgotPasswordHandler(repos.Users, mails))
+		public.POST("/auth/reset-password", limitAuth, resetPasswordHandler(repos.Users, tokens, guard))
 		// 文章相关（无需认证）
-		public.GET("/posts", listPostsHandler(db))                      // 所有文章列表
-		public.GET("/posts/:id", getPostHandler(db))                    // 单篇文章详情
-		public.GET("/posts/:post_id/comments", listCommentsHandler(db)) // 文章评论列表
+		public.GET("/posts", listPostsHandler(repos.Posts, repos.Reactions))                                 // 所有文章列表
+		public.GET("/posts/:id", getPostHandler(repos.Posts, repos.Reactions, renderer))                     // 单篇文章详情（ID或slug）
+		public.GET("/posts/:id/comments", listCommentsHandler(repos.Posts, repos.Comments, repos.Reactions)) // 文章评论列表
+		public.GET("/posts/:id/comments/stream", streamCommentsHandler(repos.Posts, hub))                    // 评论实时推送（SSE）
+		public.GET("/search", searchHandler(index))                                                          // 全文搜索
+		public.GET("/users/:id", getProfileHandler(repos.Users, repos.Posts))                                // 用户公开资料
+		public.GET("/users/:id/posts", listUserPostsHandler(repos.Users, repos.Posts, repos.Reactions))      // 用户的文章
+		public.GET("/users/:id/followers", listFollowsHandler(repos.Users, repos.Follows.ListFollowers))     // 粉丝列表
+		public.GET("/users/:id/following", listFollowsHandler(repos.Users, repos.Follows.ListFollowing))     // 关注列表
+		public.GET("/reactions", listReactionKindsHandler())                                                 // 支持的回应种类
+		public.GET("/posts/:id/attachments", listAttachmentsHandler(repos.Posts, repos.Attachments))         // 文章附件列表
+		// 标签相关
+		public.GET("/tags", listTagsHandler(repos.Tags))                              // 标签列表（含文章数）
+		public.GET("/tags/:slug/posts", listTagPostsHandler(repos.Tags, repos.Posts)) // 标签下的文章
 	}
 
 	// 保护路由（需认证）
 	protected := r.Group("/api/protected")
-	protected.Use(authMiddleware())
+	protected.Use(authMiddleware(tokens))
 	{
+		// 认证相关
+		protected.POST("/auth/logout", logoutHandler(tokens)) // 登出并吊销令牌
+		protected.POST("/auth/resend-verification", limitAuth, resendVerificationHandler(repos.Users, mails))
+		protected.PUT("/auth/email", limitAuth, changeEmailHandler(repos.Users, mails))                // 设置或更换邮箱（需当前密码）
+		protected.POST("/auth/change-password", limitAuth, changePasswordHandler(repos.Users, tokens)) // 修改密码（需当前密码）
+		// 个人资料
+		protected.GET("/profile", getMyProfileHandler(repos.Users, repos.Posts))
+		protected.PUT("/profile", limitWrite, updateProfileHandler(repos.Users))
+		protected.POST("/profile/avatar", limitWrite, uploadAvatarHandler(repos.Users, storage)) // multipart字段file
+		protected.DELETE("/profile/avatar", limitWrite, deleteAvatarHandler(repos.Users, storage))
+		// 个人数据导出与账号注销
+		protected.GET("/account/export", limitWrite, exportAccountHandler(accounts))       // zip：data.json、posts/*.md、files/
+		protected.POST("/account/deletion", limitAuth, scheduleDeletionHandler(accounts))  // 申请注销（需当前密码）
+		protected.DELETE("/account/deletion", limitWrite, cancelDeletionHandler(accounts)) // 撤销注销
 		// 文章相关
-		protected.POST("/posts", createPostHandler(db))       // 创建文章
-		protected.PUT("/posts/:id", updatePostHandler(db))    // 更新文章
-		protected.DELETE("/posts/:id", deletePostHandler(db)) // 删除文章
+		protected.POST("/posts", limitWrite, createPostHandler(repos.Posts, repos.Revisions, index))                            // 创建文章
+		protected.PUT("/posts/:id", limitWrite, updatePostHandler(repos.Posts, repos.Revisions, index, renderer))               // 更新文章
+		protected.DELETE("/posts/:id", limitWrite, deletePostHandler(repos.Posts, repos.Attachments, storage, index, renderer)) // 删除文章
+		// 附件（作者或拥有post:edit_any权限的用户）
+		protected.POST("/posts/:id/attachments", limitWrite, uploadAttachmentHandler(repos.Posts, repos.Attachments, storage)) // multipart字段file
+		protected.DELETE("/attachments/:id", limitWrite, deleteAttachmentHandler(repos.Posts, repos.Attachments, storage))
+		// 修订历史（作者或拥有post:edit_any权限的用户）
+		protected.GET("/posts/:id/revisions", listRevisionsHandler(repos.Posts, repos.Revisions))
+		protected.GET("/posts/:id/revisions/diff", diffRevisionsHandler(repos.Posts, repos.Revisions)) // ?from=1&to=2
+		protected.GET("/posts/:id/revisions/:rev", getRevisionHandler(repos.Posts, repos.Revisions))
+		protected.POST("/posts/:id/revisions/:rev/restore", limitWrite, restoreRevisionHandler(repos.Posts, repos.Revisions, index, renderer))
 		// 评论相关
-		protected.POST("/posts/:post_id/comments", createCommentHandler(db)) // 创建评论
+		protected.POST("/posts/:id/comments", limitComment, createCommentHandler(repos.Posts, repos.Comments, index, events)) // 创建评论
+		// 评论管理（版主/管理员）
+		protected.PUT("/comments/:id", limitWrite, updateCommentHandler(repos.Comments, index, events))                 // 编辑评论
+		protected.DELETE("/comments/:id", limitWrite, deleteCommentHandler(repos.Posts, repos.Comments, index, events)) // 删除评论
+		// 回应（点赞/表情，重复提交同一种回应即取消）
+		protected.POST("/posts/:id/reactions/:kind", limitWrite, togglePostReactionHandler(repos.Posts, repos.Reactions))
+		protected.POST("/comments/:id/reactions/:kind", limitWrite, toggleCommentReactionHandler(repos.Posts, repos.Comments, repos.Reactions))
+		// 关注与个人动态
+		protected.POST("/users/:id/follow", limitWrite, followHandler(repos.Users, repos.Follows))     // 关注用户
+		protected.DELETE("/users/:id/follow", limitWrite, unfollowHandler(repos.Users, repos.Follows)) // 取消关注
+		protected.GET("/feed", feedHandler(repos.Posts, repos.Reactions))                              // 已关注作者的文章
+		// 站内通知
+		protected.GET("/notifications", listNotificationsHandler(repos.Notifications))                  // 通知列表（?unread=true只看未读）
+		protected.POST("/notifications/:id/read", markNotificationReadHandler(repos.Notifications))     // 标记已读
+		protected.POST("/notifications/read-all", markAllNotificationsReadHandler(repos.Notifications)) // 全部标记已读
+		// 评论审核（需comment:delete_any权限）
+		moderation := protected.Group("/moderation", requirePermission(permCommentDeleteAny))
+		moderation.GET("/comments/deleted", listDeletedCommentsHandler(repos.Comments))
+		moderation.POST("/comments/:id/restore", restoreCommentHandler(repos.Comments, index, events))
+	}
+
+	// 服务端渲染页面与订阅源（与接口共用同一个引擎）
+	setupWebRoutes(r, repos, tokens, index, renderer, events, storage, limiter, guard, mails, accounts)
+	setupFeedRoutes(r, repos, renderer)
+
+	// 上传的文件
+	r.GET(strings.TrimSuffix(uploadURLPrefix, "/")+"/*key", serveUploadHandler(storage))
+
+	// 管理员路由（需认证且拥有role:manage权限）
+	admin := r.Group("/api/admin")
+	admin.Use(authMiddleware(tokens), requirePermission(permRoleManage))
+	{
+		admin.GET("/users/:id/roles", listUserRolesHandler(repos.Users))               // 查询用户角色
+		admin.POST("/users/:id/roles", grantRoleHandler(repos.Users))                  // 授予角色
+		admin.DELETE("/users/:id/roles/:role", revokeRoleHandler(repos.Users, tokens)) // 撤销角色
+		// 登录锁定
+		admin.GET("/login-lockouts", listLoginLockoutsHandler(guard))          // 当前被锁定的用户名和IP
+		admin.POST("/users/:id/unlock", unlockUserHandler(repos.Users, guard)) // 解除用户的登录锁定
+		admin.DELETE("/login-lockouts/ips/:ip", unlockIPHandler(guard))        // 解除IP的登录锁定
 	}
 }
 
 // === 原有注册/登录Handler（复用并优化） ===
-func registerHandler(db *gorm.DB) gin.HandlerFunc {
+func registerHandler(users UserRepository, mails *accountMailer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Username string `json:"username" binding:"required,min=3,max=20"` // 用户名3-20字
+			Email    string `json:"email" binding:"required"`                 // 邮箱（需验证）
 			Password string `json:"password" binding:"required,min=6,max=32"` // 密码6-32字
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		email, err := normalizeEmail(input.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		// 检查用户名是否已存在
-		var existingUser User
-		if err := db.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
+		// 检查用户名和邮箱是否已存在
+		if _, err := users.FindByUsername(input.Username); err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "用户名已存在"})
 			return
 		}
+		if _, err := users.FindByEmail(email); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "邮箱已被使用"})
+			return
+		}
 
-		hashpassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		hashpassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcryptCost)
 		if err != nil {
 			logger.Printf("密码加密失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
@@ -409,18 +913,22 @@ func registerHandler(db *gorm.DB) gin.HandlerFunc {
 		user := User{
 			Username: input.Username,
 			Password: string(hashpassword),
+			Email:    &email,
 		}
-		if err := db.Create(&user).Error; err != nil {
+		if err := users.Create(&user); err != nil {
 			logger.Printf("用户创建失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
 			return
 		}
+		if err := mails.sendVerification(&user, email); err != nil {
+			logger.Printf("发送验证邮件失败: %v", err) // 用户可稍后重新发送
+		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "用户注册成功"})
+		c.JSON(http.StatusCreated, gin.H{"message": "用户注册成功，请查收验证邮件"})
 	}
 }
 
-func loginHandler(db *gorm.DB) gin.HandlerFunc {
+func loginHandler(guard *loginGuard, tokens *tokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Username string `json:"username" binding:"required"`
@@ -431,23 +939,24 @@ func loginHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var user User
-		if err := db.Where("username = ?", input.Username).First(&user).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
-			return
-		}
-
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		// 校验密码并统计失败次数，见login_guard.go
+		user, err := guard.authenticate(input.Username, input.Password, c.ClientIP())
+		if err != nil {
+			var blocked *loginBlockedError
+			switch {
+			case errors.Is(err, errInvalidCredentials):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			case errors.As(err, &blocked):
+				c.Header("Retry-After", ceilSeconds(blocked.RetryAfter))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			default:
+				logger.Printf("登录校验失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请重试"})
+			}
 			return
 		}
 
-		claims := jwt.MapClaims{
-			"sub": user.ID,
-			"exp": time.Now().Add(24 * time.Hour).Unix(),
-		}
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		tokenString, err := token.SignedString(jwtSecret)
+		pair, err := tokens.issue(user.ID, "")
 		if err != nil {
 			logger.Printf("JWT生成失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请重试"})
@@ -455,32 +964,107 @@ func loginHandler(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "登录成功",
-			"token":   tokenString,
+			"message":       "登录成功",
+			"token":         pair.AccessToken, // 兼容旧客户端
+			"access_token":  pair.AccessToken,
+			"refresh_token": pair.RefreshToken,
+			"token_type":    pair.TokenType,
+			"expires_in":    pair.ExpiresIn,
 		})
 	}
 }
 
 // === 主函数 ===
 func main() {
-	dsn := "root:123456@tcp(127.0.0.1:3306)/dbtest?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	cfg, printOnly, err := loadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		logger.Fatalf("加载配置失败: %v", err)
+	}
+	if printOnly {
+		if err := printConfig(cfg); err != nil {
+			logger.Fatalf("打印配置失败: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "配置校验未通过:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Fatalf("配置校验未通过，拒绝启动:\n%v", err)
+	}
+	applyConfig(cfg)
+
+	// 数据库驱动：mysql（默认）或sqlite（纯Go，可在本地无MySQL运行）
+	db, err := openDatabase(cfg.Database.Driver, cfg.Database.DSN)
 	if err != nil {
 		logger.Fatalf("数据库连接失败: %v", err)
 	}
 
 	// 自动迁移表结构（添加外键约束）
-	err = db.AutoMigrate(&User{}, &Post{}, &Comment{})
-	if err != nil {
+	if err := migrate(db); err != nil {
 		logger.Fatalf("自动迁移失败: %v", err)
 	}
 	logger.Println("数据库表迁移成功")
 
+	repos := newGormRepositories(db)
+
+	// 初始化管理员（配置中指定的已注册用户）
+	if cfg.Auth.AdminUsername != "" {
+		if err := ensureAdmin(repos.Users, cfg.Auth.AdminUsername); err != nil {
+			logger.Printf("初始化管理员失败: %v", err)
+		}
+	}
+
+	// 从数据库重建全文索引
+	index := newSearchIndex()
+	if err := rebuildSearchIndex(index, repos.Posts, repos.Comments); err != nil {
+		logger.Fatalf("重建搜索索引失败: %v", err)
+	}
+	logger.Println("搜索索引构建完成")
+
+	tokens := newTokenStore(db)
+	go tokens.purgeLoop(time.Hour) // 定期清理过期令牌
+	guard := newLoginGuard(db, repos.Users)
+	go guard.purgeLoop(time.Hour)                                // 定期清理失效的登录失败记录
+	go publishLoop(repos.Posts, index, publishSchedulerInterval) // 定时发布文章
+
+	// 事件总线：评论等事件由通知服务和实时推送订阅
+	events := newEventBus()
+	newNotificationService(repos.Notifications, repos.Comments, repos.Users).Subscribe(events)
+	hub := newCommentHub(repos.Comments)
+	hub.Subscribe(events)
+	go hub.pruneLoop(time.Minute)
+
+	storage, err := newLocalStorage(cfg.Uploads.Dir)
+	if err != nil {
+		logger.Fatalf("初始化附件存储失败: %v", err)
+	}
+
+	limiter := newMemoryRateLimitStore()
+	go limiter.pruneLoop(time.Minute)
+
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		logger.Fatalf("初始化邮件发送失败: %v", err)
+	}
+
 	r := gin.Default()
-	setupRoutes(r, db)
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatalf("可信代理配置错误: %v", err)
+	}
+	renderer := newContentRenderer()
+	mails := newAccountMailer(mailer, tokens)
+	accounts := newAccountService(db, tokens, storage, index, renderer, mails)
+	go accounts.purgeLoop(time.Hour) // 执行到期的账号注销
 
-	logger.Println("服务器启动成功，监听端口: 8080")
-	if err := r.Run(":8080"); err != nil {
+	setupRoutes(r, repos, tokens, index, renderer, events, hub, storage, limiter, guard, mails, accounts)
+
+	logger.Printf("服务器启动成功，监听地址: %s", cfg.Server.Addr)
+	if err := r.Run(cfg.Server.Addr); err != nil {
 		logger.Fatalf("服务器启动失败: %v", err)
 	}
-}
\ No newline at end of file
+}
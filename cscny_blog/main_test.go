@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// === 测试基础设施 ===
+// 每个测试使用独立的SQLite内存库和完整的路由，通过httptest发起请求，无需MySQL。
+
+const (
+	testPassword  = "password123"
+	testJWTSecret = "Zq3v9Xk2LmP8rT4wY7uB1nE6hJ0sD5fG"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.SetOutput(io.Discard)
+	jwtSecret = []byte(testJWTSecret)
+	os.Exit(m.Run())
+}
+
+// testMailer 记录发出的邮件
+type testMailer struct {
+	sent chan Mail
+}
+
+func (m *testMailer) Send(mail Mail) error {
+	m.sent <- mail
+	return nil
+}
+
+// newTestDB 打开迁移好的SQLite内存库，测试结束后关闭
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := openDatabase(driverSQLite, "file::memory:")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	db.Logger = gormlogger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return db
+}
+
+// testApp 一套完整的博客服务
+type testApp struct {
+	t        *testing.T
+	db       *gorm.DB
+	repos    *Repositories
+	tokens   *tokenStore
+	index    *searchIndex
+	renderer *contentRenderer
+	events   *eventBus
+	guard    *loginGuard
+	storage  Storage
+	limiter  *memoryRateLimitStore
+	mailer   *testMailer
+	accounts *accountService
+	router   *gin.Engine
+}
+
+// testConfig 测试用配置：最低bcrypt成本、关闭限流、临时附件和邮件目录
+func testConfig(t *testing.T) Config {
+	cfg := defaultConfig()
+	cfg.Auth.JWTSecret = testJWTSecret
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+	cfg.RateLimit.Enabled = false
+	cfg.Uploads.Dir = t.TempDir()
+	cfg.Mail.OutboxDir = t.TempDir()
+	return cfg
+}
+
+// newTestApp 按测试配置（可由opts修改）创建服务；测试结束后恢复全局配置
+func newTestApp(t *testing.T, opts ...func(*Config)) *testApp {
+	t.Helper()
+	cfg := testConfig(t)
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("测试配置无效: %v", err)
+	}
+	applyConfig(cfg)
+	t.Cleanup(func() { applyConfig(testConfig(t)) })
+
+	db := newTestDB(t)
+	storage, err := newLocalStorage(cfg.Uploads.Dir)
+	if err != nil {
+		t.Fatalf("初始化存储失败: %v", err)
+	}
+	app := &testApp{
+		t:        t,
+		db:       db,
+		repos:    newGormRepositories(db),
+		tokens:   newTokenStore(db),
+		index:    newSearchIndex(),
+		renderer: newContentRenderer(),
+		events:   newEventBus(),
+		storage:  storage,
+		limiter:  newMemoryRateLimitStore(),
+		mailer:   &testMailer{sent: make(chan Mail, 32)},
+		router:   gin.New(),
+	}
+	app.guard = newLoginGuard(db, app.repos.Users)
+	newNotificationService(app.repos.Notifications, app.repos.Comments, app.repos.Users).Subscribe(app.events)
+	hub := newCommentHub(app.repos.Comments)
+	hub.Subscribe(app.events)
+	mails := newAccountMailer(app.mailer, app.tokens)
+	app.accounts = newAccountService(db, app.tokens, storage, app.index, app.renderer, mails)
+	setupRoutes(app.router, app.repos, app.tokens, app.index, app.renderer, app.events, hub, storage, app.limiter, app.guard, mails, app.accounts)
+	return app
+}
+
+// request 发起请求；body非nil时编码为JSON，token非空时带上Bearer认证
+func (a *testApp) request(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("编码请求失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect 检查状态码并把响应解码到out（out可为nil）
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, out interface{}) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("状态码为%d，期望%d，响应: %s", w.Code, status, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("解码响应失败: %v，响应: %s", err, w.Body.String())
+		}
+	}
+}
+
+// register 注册用户并返回其ID
+func (a *testApp) register(username string) uint {
+	a.t.Helper()
+	w := a.request(http.MethodPost, "/api/public/auth/register", gin.H{
+		"username": username, "email": username + "@example.com", "password": testPassword,
+	}, "")
+	expect(a.t, w, http.StatusCreated, nil)
+	user, err := a.repos.Users.FindByUsername(username)
+	if err != nil {
+		a.t.Fatalf("查询新用户失败: %v", err)
+	}
+	return user.ID
+}
+
+// loginResponse 登录和刷新令牌的响应
+type loginResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// login 登录并返回令牌
+func (a *testApp) login(username string) loginResponse {
+	a.t.Helper()
+	var resp loginResponse
+	w := a.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": username, "password": testPassword}, "")
+	expect(a.t, w, http.StatusOK, &resp)
+	return resp
+}
+
+// newUser 注册并登录，返回用户ID和访问令牌
+func (a *testApp) newUser(username string, roles ...string) (uint, string) {
+	a.t.Helper()
+	id := a.register(username)
+	for _, role := range roles {
+		if err := a.repos.Users.GrantRole(id, role); err != nil {
+			a.t.Fatalf("授予角色失败: %v", err)
+		}
+	}
+	return id, a.login(username).AccessToken
+}
+
+// authenticated 令牌能否通过认证（删除不存在的文章：通过认证时返回404而不是401）
+func (a *testApp) authenticated(token string) bool {
+	a.t.Helper()
+	return a.request(http.MethodDelete, "/api/protected/posts/9999", nil, token).Code != http.StatusUnauthorized
+}
+
+// postResponse 文章接口的响应
+type postResponse struct {
+	Data Post `json:"data"`
+}
+
+// createPost 创建文章，fields覆盖默认的标题、内容
+func (a *testApp) createPost(token string, fields gin.H) Post {
+	a.t.Helper()
+	body := gin.H{"title": "测试文章", "content": "这是一篇用于测试的文章内容。"}
+	for k, v := range fields {
+		body[k] = v
+	}
+	var resp postResponse
+	expect(a.t, a.request(http.MethodPost, "/api/protected/posts", body, token), http.StatusCreated, &resp)
+	return resp.Data
+}
+
+// createComment 在文章下发表评论，parentID为0时为根评论
+func (a *testApp) createComment(token string, postID, parentID uint, content string) Comment {
+	a.t.Helper()
+	body := gin.H{"content": content}
+	if parentID != 0 {
+		body["parent_id"] = parentID
+	}
+	var resp struct {
+		Data Comment `json:"data"`
+	}
+	expect(a.t, a.request(http.MethodPost, fmt.Sprintf("/api/protected/posts/%d/comments", postID), body, token), http.StatusCreated, &resp)
+	return resp.Data
+}
+
+// nextMail 等待下一封邮件（邮件在后台goroutine中发送）
+func (a *testApp) nextMail() Mail {
+	a.t.Helper()
+	select {
+	case m := <-a.mailer.sent:
+		return m
+	case <-time.After(2 * time.Second):
+		a.t.Fatal("没有收到邮件")
+		return Mail{}
+	}
+}
+
+// === 注册、登录与文章接口 ===
+
+func TestRegisterValidation(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+
+	tests := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"用户名过短", gin.H{"username": "al", "email": "al@example.com", "password": testPassword}, http.StatusBadRequest},
+		{"密码过短", gin.H{"username": "bobby", "email": "bob@example.com", "password": "123"}, http.StatusBadRequest},
+		{"邮箱格式错误", gin.H{"username": "bobby", "email": "not-an-email", "password": testPassword}, http.StatusBadRequest},
+		{"用户名已存在", gin.H{"username": "alice", "email": "other@example.com", "password": testPassword}, http.StatusConflict},
+		{"邮箱已被使用", gin.H{"username": "bobby", "email": "alice@example.com", "password": testPassword}, http.StatusConflict},
+		{"成功", gin.H{"username": "bobby", "email": "bob@example.com", "password": testPassword}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, "/api/public/auth/register", tt.body, ""), tt.status, nil)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		status   int
+	}{
+		{"密码错误", "alice", "wrong-password", http.StatusUnauthorized},
+		{"用户不存在", "nobody", testPassword, http.StatusUnauthorized},
+		{"成功", "alice", testPassword, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": tt.username, "password": tt.password}, "")
+			expect(t, w, tt.status, nil)
+		})
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	app := newTestApp(t)
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"无令牌", ""},
+		{"无效令牌", "not-a-jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.request(http.MethodPost, "/api/protected/posts", gin.H{"title": "标题", "content": "足够长的文章内容。"}, tt.token)
+			expect(t, w, http.StatusUnauthorized, nil)
+		})
+	}
+}
+
+func TestPostCRUD(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bobby")
+
+	post := app.createPost(alice, gin.H{"title": "第一篇文章"})
+	path := fmt.Sprintf("/api/protected/posts/%d", post.ID)
+
+	var got postResponse
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusOK, &got)
+	if got.Data.Title != "第一篇文章" {
+		t.Fatalf("标题为%q", got.Data.Title)
+	}
+
+	update := gin.H{"title": "改过的标题", "content": "修改后的文章内容，足够长。"}
+	expect(t, app.request(http.MethodPut, path, update, bob), http.StatusForbidden, nil)
+	expect(t, app.request(http.MethodPut, path, update, alice), http.StatusOK, &got)
+	if got.Data.Title != "改过的标题" {
+		t.Fatalf("更新后标题为%q", got.Data.Title)
+	}
+
+	expect(t, app.request(http.MethodDelete, path, nil, bob), http.StatusForbidden, nil)
+	expect(t, app.request(http.MethodDelete, path, nil, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusNotFound, nil)
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+
+	tests := []struct {
+		name string
+		body gin.H
+	}{
+		{"缺少标题", gin.H{"content": "足够长的文章内容。"}},
+		{"内容过短", gin.H{"title": "标题", "content": "太短"}},
+		{"未知状态", gin.H{"title": "标题", "content": "足够长的文章内容。", "status": "archived"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, "/api/protected/posts", tt.body, token), http.StatusBadRequest, nil)
+		})
+	}
+}
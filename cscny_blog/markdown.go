@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// === Markdown渲染 ===
+// 文章内容按Markdown存储，服务端渲染为HTML后再经白名单过滤，
+// 客户端可直接使用content_html而无需自行处理XSS。
+
+const maxRenderCacheEntries = 1000
+
+// markdown GFM扩展（表格、删除线、任务列表、自动链接）；原始HTML不会被输出
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// htmlPolicy 在UGC白名单基础上允许代码高亮所需的language-*类名；
+// 链接只允许http/https/mailto，并添加rel="nofollow noopener"，站外链接在新窗口打开
+var htmlPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("type", "checked", "disabled").OnElements("input") // GFM任务列表
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// renderMarkdown 将Markdown渲染为过滤后的HTML
+func renderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
+
+// renderedPost 缓存的渲染结果（以更新时间校验是否过期）
+type renderedPost struct {
+	updatedAt time.Time
+	html      string
+}
+
+// contentRenderer 文章HTML渲染缓存（并发安全）
+type contentRenderer struct {
+	mu      sync.Mutex
+	entries map[uint]renderedPost
+}
+
+func newContentRenderer() *contentRenderer {
+	return &contentRenderer{entries: make(map[uint]renderedPost)}
+}
+
+// Render 返回文章内容的HTML，命中缓存时不重复渲染
+func (r *contentRenderer) Render(post *Post) (string, error) {
+	r.mu.Lock()
+	entry, ok := r.entries[post.ID]
+	r.mu.Unlock()
+	if ok && entry.updatedAt.Equal(post.UpdatedAt) {
+		return entry.html, nil
+	}
+
+	html, err := renderMarkdown(post.Content)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) >= maxRenderCacheEntries {
+		for id := range r.entries { // 缓存已满时随机淘汰一条
+			delete(r.entries, id)
+			break
+		}
+	}
+	r.entries[post.ID] = renderedPost{updatedAt: post.UpdatedAt, html: html}
+	return html, nil
+}
+
+// Invalidate 文章修改或删除后清除缓存
+func (r *contentRenderer) Invalidate(postID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, postID)
+}
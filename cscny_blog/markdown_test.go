@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string // 输出中应包含的片段
+		notWant []string // 输出中不应包含的片段
+	}{
+		{"基本语法", "# 标题\n\n**加粗**", []string{"<h1", "标题</h1>", "<strong>加粗</strong>"}, nil},
+		{"代码块保留语言类名", "```go\nfmt.Println()\n```", []string{`<code class="language-go">`}, nil},
+		{"任意类名被过滤", "<code class=\"evil\">x</code>", nil, []string{"evil"}},
+		{"GFM表格和删除线", "| a | b |\n|---|---|\n| 1 | 2 |\n\n~~删除~~", []string{"<table>", "<del>删除</del>"}, nil},
+		{"任务列表", "- [x] 完成", []string{`<input checked="" disabled="" type="checkbox"`}, nil},
+		{"原始HTML不输出", "<script>alert(1)</script>", nil, []string{"<script", "alert(1)"}},
+		{"javascript链接被移除", "[点击](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"站外链接", "[示例](https://example.com)", []string{`href="https://example.com"`, `rel="nofollow noopener"`, `target="_blank"`}, nil},
+		{"图片事件属性被移除", `<img src="x" onerror="alert(1)">`, nil, []string{"onerror"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := renderMarkdown(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(html, s) {
+					t.Errorf("输出中缺少%q: %s", s, html)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(html, s) {
+					t.Errorf("输出中不应包含%q: %s", s, html)
+				}
+			}
+		})
+	}
+}
+
+func TestContentRendererCache(t *testing.T) {
+	r := newContentRenderer()
+	updated := time.Now()
+	post := &Post{Model: gorm.Model{ID: 1, UpdatedAt: updated}, Content: "第一版"}
+
+	render := func() string {
+		t.Helper()
+		html, err := r.Render(post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return html
+	}
+
+	if html := render(); !strings.Contains(html, "第一版") {
+		t.Fatalf("渲染结果为%s", html)
+	}
+	// 更新时间未变时使用缓存
+	post.Content = "第二版"
+	if html := render(); !strings.Contains(html, "第一版") {
+		t.Fatalf("未命中缓存: %s", html)
+	}
+	// 更新时间变化后重新渲染
+	post.UpdatedAt = updated.Add(time.Second)
+	if html := render(); !strings.Contains(html, "第二版") {
+		t.Fatalf("缓存未过期: %s", html)
+	}
+	// 清除缓存后重新渲染
+	post.Content = "第三版"
+	r.Invalidate(post.ID)
+	if html := render(); !strings.Contains(html, "第三版") {
+		t.Fatalf("清除缓存后仍为旧内容: %s", html)
+	}
+}
+
+func TestPostContentHTML(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+	post := app.createPost(token, gin.H{"content": "正文<script>alert(1)</script>\n\n**重点**"})
+
+	var resp postResponse
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusOK, &resp)
+	if !strings.Contains(resp.Data.ContentHTML, "<strong>重点</strong>") || strings.Contains(resp.Data.ContentHTML, "<script") {
+		t.Fatalf("content_html为%s", resp.Data.ContentHTML)
+	}
+
+	// 修改后返回新内容
+	path := fmt.Sprintf("/api/protected/posts/%d", post.ID)
+	expect(t, app.request(http.MethodPut, path, gin.H{"content": "修改后的**正文内容**"}, token), http.StatusOK, nil)
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusOK, &resp)
+	if !strings.Contains(resp.Data.ContentHTML, "<strong>正文内容</strong>") {
+		t.Fatalf("修改后content_html为%s", resp.Data.ContentHTML)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 站内通知 ===
+// 通知服务订阅评论事件：文章收到评论通知作者，评论收到回复通知评论者，评论中@用户名通知被提及的用户。
+// 同一条评论对同一用户只产生一条通知（回复 > 评论 > 提及），自己的操作不通知自己。
+
+// 通知类型
+const (
+	notificationComment = "comment" // 有人评论了你的文章
+	notificationReply   = "reply"   // 有人回复了你的评论
+	notificationMention = "mention" // 有人在评论中提到了你
+)
+
+const maxMentionsPerComment = 10 // 单条评论最多通知的被提及用户数
+
+// mentionPattern 评论中的@用户名（用户名为3-20个字母、数字、下划线、点或横线）
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_.\-]{3,20})`)
+
+// Notification 站内通知
+type Notification struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index:idx_notification_user,priority:1" json:"-"` // 接收者
+	Type      string     `gorm:"type:varchar(20);not null" json:"type"`
+	ActorID   uint       `gorm:"not null" json:"actor_id"`                              // 触发通知的用户
+	Actor     User       `gorm:"foreignKey:ActorID" json:"actor"`                       // 触发者信息
+	PostID    uint       `gorm:"not null;index" json:"post_id"`                         // 相关文章
+	CommentID uint       `gorm:"not null" json:"comment_id"`                            // 相关评论
+	ReadAt    *time.Time `gorm:"index:idx_notification_user,priority:2" json:"read_at"` // 已读时间（未读为null）
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationRepository 通知仓储
+type NotificationRepository interface {
+	CreateBatch(list []Notification) error
+	List(userID uint, unreadOnly bool, q PageQuery) ([]Notification, *Pagination, error) // 按创建时间倒序，含触发者信息
+	CountUnread(userID uint) (int64, error)
+	MarkRead(userID, id uint) (bool, error) // 返回通知是否存在（已读的通知重复标记也返回true）
+	MarkAllRead(userID uint) (int64, error) // 返回本次标记的数量
+}
+
+// notificationService 根据事件生成通知
+type notificationService struct {
+	notifications NotificationRepository
+	comments      CommentRepository
+	users         UserRepository
+}
+
+func newNotificationService(notifications NotificationRepository, comments CommentRepository, users UserRepository) *notificationService {
+	return &notificationService{notifications: notifications, comments: comments, users: users}
+}
+
+// Subscribe 订阅需要产生通知的事件
+func (s *notificationService) Subscribe(bus *eventBus) {
+	bus.Subscribe(eventCommentCreated, func(payload interface{}) {
+		if err := s.commentCreated(payload.(CommentEvent)); err != nil {
+			logger.Printf("生成评论通知失败: %v", err)
+		}
+	})
+}
+
+// commentCreated 为新评论生成通知
+func (s *notificationService) commentCreated(e CommentEvent) error {
+	recipients := make(map[uint]string) // 接收者 → 通知类型
+	add := func(userID uint, kind string) {
+		if userID == 0 || userID == e.ActorID {
+			return
+		}
+		if _, ok := recipients[userID]; !ok {
+			recipients[userID] = kind
+		}
+	}
+
+	if e.Comment.ParentID != nil {
+		parent, err := s.comments.FindByID(*e.Comment.ParentID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if parent != nil {
+			add(parent.UserID, notificationReply)
+		}
+	}
+	add(e.Post.UserID, notificationComment)
+
+	if names := mentionedUsernames(e.Comment.Content); len(names) > 0 {
+		users, err := s.users.FindByUsernames(names)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			add(user.ID, notificationMention)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil
+	}
+	list := make([]Notification, 0, len(recipients))
+	for userID, kind := range recipients {
+		list = append(list, Notification{
+			UserID:    userID,
+			Type:      kind,
+			ActorID:   e.ActorID,
+			PostID:    e.Post.ID,
+			CommentID: e.Comment.ID,
+		})
+	}
+	return s.notifications.CreateBatch(list)
+}
+
+// mentionedUsernames 提取评论中被@的用户名（去重，最多maxMentionsPerComment个）
+func mentionedUsernames(content string) []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".-") // 句末标点不属于用户名
+		if _, ok := seen[name]; ok || len([]rune(name)) < 3 {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+		if len(names) == maxMentionsPerComment {
+			break
+		}
+	}
+	return names
+}
+
+// === 通知Handler ===
+// 当前用户的通知列表（参数unread=true只看未读，limit、cursor分页），同时返回未读数
+func listNotificationsHandler(notifications NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		query, err := parsePageQuery(c, true, sortCreatedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+
+		list, page, err := notifications.List(userId.(uint), unreadOnly, query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询通知失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通知失败"})
+			return
+		}
+		unread, err := notifications.CountUnread(userId.(uint))
+		if err != nil {
+			logger.Printf("查询未读通知数失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通知失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list, "unread_count": unread, "pagination": page})
+	}
+}
+
+// 标记单条通知为已读
+func markNotificationReadHandler(notifications NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		found, err := notifications.MarkRead(userId.(uint), id)
+		if err != nil {
+			logger.Printf("标记通知已读失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "标记已读失败"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "通知不存在"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "已标记为已读"})
+	}
+}
+
+// 将全部通知标记为已读
+func markAllNotificationsReadHandler(notifications NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		n, err := notifications.MarkAllRead(userId.(uint))
+		if err != nil {
+			logger.Printf("标记全部通知已读失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "标记已读失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "已全部标记为已读", "marked": n})
+	}
+}
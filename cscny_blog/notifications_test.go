@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// notificationResponse 通知列表接口的响应
+type notificationResponse struct {
+	Data        []Notification `json:"data"`
+	UnreadCount int64          `json:"unread_count"`
+	Pagination  Pagination     `json:"pagination"`
+}
+
+// notifications 查询当前用户的通知
+func (a *testApp) notifications(token, query string) notificationResponse {
+	a.t.Helper()
+	var resp notificationResponse
+	expect(a.t, a.request(http.MethodGet, "/api/protected/notifications"+query, nil, token), http.StatusOK, &resp)
+	return resp
+}
+
+// notificationTypes 按"触发者:类型"描述通知，便于比较
+func notificationTypes(list []Notification) []string {
+	out := make([]string, 0, len(list))
+	for _, n := range list {
+		out = append(out, n.Actor.Username+":"+n.Type)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestMentionedUsernames(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"没有提及", nil},
+		{"@alice 你好", []string{"alice"}},
+		{"@alice 和 @bob.", []string{"alice", "bob"}},
+		{"@alice @alice", []string{"alice"}},
+		{"@ab 太短", nil},
+		{"请看 @carol-- 和 @dave...", []string{"carol", "dave"}},
+		{"@张三丰 中文用户名", []string{"张三丰"}},
+		{strings.Repeat("@user_x ", 3) + "@user-y", []string{"user_x", "user-y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			if got := mentionedUsernames(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("提及的用户为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+
+	var many []string
+	for i := 0; i < maxMentionsPerComment+5; i++ {
+		many = append(many, fmt.Sprintf("@user%02d", i))
+	}
+	if got := mentionedUsernames(strings.Join(many, " ")); len(got) != maxMentionsPerComment {
+		t.Fatalf("提及了%d个用户", len(got))
+	}
+}
+
+func TestCommentNotifications(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bob")
+	_, carol := app.newUser("carol")
+	post := app.createPost(alice, nil)
+
+	// 同一条评论对同一用户只通知一次：评论 > 提及
+	comment := app.createComment(bob, post.ID, 0, "写得好 @alice @carol @nobody")
+	// 回复 > 评论；自己不通知自己
+	app.createComment(carol, post.ID, comment.ID, "同意 @bob @carol")
+	app.createComment(alice, post.ID, 0, "谢谢大家")
+
+	tests := []struct {
+		name  string
+		token string
+		want  []string
+	}{
+		{"文章作者", alice, []string{"bob:comment", "carol:comment"}},
+		{"被回复的评论者", bob, []string{"carol:reply"}},
+		{"被提及的用户", carol, []string{"bob:mention"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := app.notifications(tt.token, "")
+			if got := notificationTypes(resp.Data); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("通知为%v，期望%v", got, tt.want)
+			}
+			if resp.UnreadCount != int64(len(tt.want)) {
+				t.Fatalf("未读数为%d", resp.UnreadCount)
+			}
+			for _, n := range resp.Data {
+				if n.PostID != post.ID || n.CommentID == 0 {
+					t.Fatalf("通知缺少关联信息: %+v", n)
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationReadState(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bob")
+	post := app.createPost(alice, nil)
+	for i := 0; i < 3; i++ {
+		app.createComment(bob, post.ID, 0, fmt.Sprintf("评论%d", i))
+	}
+
+	list := app.notifications(alice, "")
+	if len(list.Data) != 3 || list.UnreadCount != 3 {
+		t.Fatalf("通知%d条，未读%d条", len(list.Data), list.UnreadCount)
+	}
+	first := list.Data[0].ID
+	readPath := fmt.Sprintf("/api/protected/notifications/%d/read", first)
+
+	// 只能标记自己的通知；重复标记也成功
+	expect(t, app.request(http.MethodPost, readPath, nil, bob), http.StatusNotFound, nil)
+	expect(t, app.request(http.MethodPost, readPath, nil, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPost, readPath, nil, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPost, "/api/protected/notifications/abc/read", nil, alice), http.StatusBadRequest, nil)
+
+	unread := app.notifications(alice, "?unread=true")
+	if len(unread.Data) != 2 || unread.UnreadCount != 2 {
+		t.Fatalf("未读通知%d条，未读数%d", len(unread.Data), unread.UnreadCount)
+	}
+	for _, n := range unread.Data {
+		if n.ID == first || n.ReadAt != nil {
+			t.Fatalf("已读通知出现在未读列表中: %+v", n)
+		}
+	}
+
+	var resp struct {
+		Marked int64 `json:"marked"`
+	}
+	expect(t, app.request(http.MethodPost, "/api/protected/notifications/read-all", nil, alice), http.StatusOK, &resp)
+	if resp.Marked != 2 {
+		t.Fatalf("标记了%d条", resp.Marked)
+	}
+	if after := app.notifications(alice, ""); after.UnreadCount != 0 || len(after.Data) != 3 {
+		t.Fatalf("全部已读后未读数为%d，共%d条", after.UnreadCount, len(after.Data))
+	}
+}
+
+func TestEventBusRecoversPanic(t *testing.T) {
+	bus := newEventBus()
+	var got []string
+	bus.Subscribe(eventCommentCreated, func(interface{}) { panic("订阅者出错") })
+	bus.Subscribe(eventCommentCreated, func(payload interface{}) { got = append(got, payload.(string)) })
+	bus.Subscribe(eventCommentDeleted, func(interface{}) { got = append(got, "其他主题") })
+
+	bus.Publish(eventCommentCreated, "事件")
+	if !reflect.DeepEqual(got, []string{"事件"}) {
+		t.Fatalf("订阅者收到%v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 游标分页 ===
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// 排序字段
+const (
+	sortCreatedAt    = "created_at"
+	sortUpdatedAt    = "updated_at"
+	sortCommentCount = "comment_count"
+	sortDeletedAt    = "deleted_at"
+	sortPublishedAt  = "published_at"
+)
+
+var errInvalidCursor = errors.New("无效的分页游标")
+
+// pageCursor 游标内容（对客户端不透明，base64编码后传输）
+type pageCursor struct {
+	Sort  string `json:"s"` // 排序字段
+	Desc  bool   `json:"d"` // 是否降序
+	Value string `json:"v"` // 上一页最后一条记录的排序值
+	ID    uint   `json:"i"` // 上一页最后一条记录的ID（排序值相同时用于定位）
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// timeCursorValue/countCursorValue 将排序值编码为游标中的字符串
+func timeCursorValue(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
+func countCursorValue(n int64) string    { return strconv.FormatInt(n, 10) }
+
+// Pagination 分页响应信息（与CRUD示例的"pagination"字段保持一致的位置）
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"` // 为空表示没有下一页
+}
+
+// PageQuery 通用分页与排序参数
+type PageQuery struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	Cursor *pageCursor
+}
+
+// PostQuery 文章列表查询条件
+type PostQuery struct {
+	PageQuery
+	AuthorID      uint
+	AuthorName    string
+	CreatedFrom   *time.Time // 创建时间下限（含）
+	CreatedTo     *time.Time // 创建时间上限（不含）
+	TitleContains string
+	TagID         uint   // 按标签筛选
+	Status        string // 按状态筛选（为空表示不限）
+	FollowerID    uint   // 只返回该用户关注的作者的文章（个人动态）
+}
+
+// CommentQuery 评论列表查询条件
+type CommentQuery struct {
+	PageQuery
+	PostID uint
+}
+
+// parsePageQuery 解析limit、cursor、sort、order参数；allowedSorts第一个为默认排序字段
+func parsePageQuery(c *gin.Context, defaultDesc bool, allowedSorts ...string) (PageQuery, error) {
+	q := PageQuery{Limit: defaultPageLimit, Sort: allowedSorts[0], Desc: defaultDesc}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, errors.New("limit必须为正整数")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		q.Limit = limit
+	}
+
+	if v := c.Query("sort"); v != "" {
+		valid := false
+		for _, s := range allowedSorts {
+			if v == s {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return q, errors.New("不支持的排序字段: " + v)
+		}
+		q.Sort = v
+	}
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, errors.New("order只能为asc或desc")
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return q, err
+		}
+		// 游标必须与当前排序方式一致，否则翻页结果没有意义
+		if cursor.Sort != q.Sort || cursor.Desc != q.Desc {
+			return q, errInvalidCursor
+		}
+		q.Cursor = cursor
+	}
+	return q, nil
+}
+
+// parseDateParam 解析日期参数，支持RFC3339和2006-01-02两种格式
+func parseDateParam(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return nil, errors.New(name + "日期格式错误（支持2006-01-02或RFC3339）")
+	}
+	return &t, nil
+}
+
+// parsePostQuery 解析文章列表的筛选、排序与分页参数
+func parsePostQuery(c *gin.Context) (PostQuery, error) {
+	page, err := parsePageQuery(c, true, sortCreatedAt, sortUpdatedAt, sortCommentCount)
+	if err != nil {
+		return PostQuery{}, err
+	}
+	q := PostQuery{
+		PageQuery:     page,
+		AuthorName:    strings.TrimSpace(c.Query("author")),
+		TitleContains: strings.TrimSpace(c.Query("title")),
+	}
+
+	if v := c.Query("author_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return q, errors.New("author_id必须为数字")
+		}
+		q.AuthorID = uint(id)
+	}
+
+	if q.CreatedFrom, err = parseDateParam(c, "from"); err != nil {
+		return q, err
+	}
+	if q.CreatedTo, err = parseDateParam(c, "to"); err != nil {
+		return q, err
+	}
+	// 仅给出日期时，to包含当天
+	if q.CreatedTo != nil && len(c.Query("to")) == len("2006-01-02") {
+		end := q.CreatedTo.AddDate(0, 0, 1)
+		q.CreatedTo = &end
+	}
+	return q, nil
+}
+
+// parseCommentQuery 解析评论列表的排序与分页参数（默认按时间正序）
+func parseCommentQuery(c *gin.Context, postID uint) (CommentQuery, error) {
+	page, err := parsePageQuery(c, false, sortCreatedAt)
+	if err != nil {
+		return CommentQuery{}, err
+	}
+	return CommentQuery{PageQuery: page, PostID: postID}, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listResponse 分页列表接口的响应
+type listResponse[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// testQueryContext 构造只带查询参数的gin上下文
+func testQueryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return c
+}
+
+func TestDecodeCursor(t *testing.T) {
+	valid := pageCursor{Sort: sortCreatedAt, Desc: true, Value: "2024-01-01T00:00:00Z", ID: 7}
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"往返编码", valid.encode(), false},
+		{"非base64", "!!!", true},
+		{"非JSON", "bm90LWpzb24", true},
+		{"缺少ID", pageCursor{Sort: sortCreatedAt}.encode(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.raw)
+			if tt.wantErr {
+				if err != errInvalidCursor {
+					t.Fatalf("错误为%v，期望errInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil || *got != valid {
+				t.Fatalf("解码结果为%+v（err=%v）", got, err)
+			}
+		})
+	}
+}
+
+func TestParsePageQuery(t *testing.T) {
+	ascCursor := pageCursor{Sort: sortCreatedAt, Desc: false, ID: 1}.encode()
+	descCursor := pageCursor{Sort: sortCreatedAt, Desc: true, ID: 1}.encode()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    PageQuery
+		wantErr bool
+	}{
+		{"默认值", "", PageQuery{Limit: defaultPageLimit, Sort: sortCreatedAt, Desc: true}, false},
+		{"limit超过上限", "limit=1000", PageQuery{Limit: maxPageLimit, Sort: sortCreatedAt, Desc: true}, false},
+		{"升序", "sort=comment_count&order=ASC", PageQuery{Limit: defaultPageLimit, Sort: sortCommentCount}, false},
+		{"limit非法", "limit=0", PageQuery{}, true},
+		{"排序字段非法", "sort=title", PageQuery{}, true},
+		{"排序方向非法", "order=up", PageQuery{}, true},
+		{"游标与排序方向不一致", "cursor=" + ascCursor, PageQuery{}, true},
+		{"游标无效", "cursor=xyz", PageQuery{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePageQuery(testQueryContext(tt.query), true, sortCreatedAt, sortCommentCount)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望返回错误")
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("结果为%+v（err=%v），期望%+v", got, err, tt.want)
+			}
+		})
+	}
+
+	got, err := parsePageQuery(testQueryContext("cursor="+descCursor), true, sortCreatedAt)
+	if err != nil || got.Cursor == nil || got.Cursor.ID != 1 {
+		t.Fatalf("游标未解析: %+v（err=%v）", got, err)
+	}
+}
+
+// listPostIDs 按游标翻完文章列表，返回全部文章ID
+func (a *testApp) listPostIDs(query url.Values) []uint {
+	a.t.Helper()
+	var ids []uint
+	for page := 0; ; page++ {
+		if page > 20 {
+			a.t.Fatal("翻页没有结束")
+		}
+		var resp listResponse[Post]
+		expect(a.t, a.request(http.MethodGet, "/api/public/posts?"+query.Encode(), nil, ""), http.StatusOK, &resp)
+		for _, post := range resp.Data {
+			ids = append(ids, post.ID)
+		}
+		if !resp.Pagination.HasMore {
+			return ids
+		}
+		query.Set("cursor", resp.Pagination.NextCursor)
+	}
+}
+
+func TestListPostsPagination(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bobby")
+
+	var posts []Post
+	for i := 0; i < 5; i++ {
+		posts = append(posts, app.createPost(alice, gin.H{"title": fmt.Sprintf("文章%d", i)}))
+	}
+	posts = append(posts, app.createPost(bob, gin.H{"title": "Bob的文章"}))
+	// 评论数：文章1有2条，文章3有1条
+	app.createComment(bob, posts[1].ID, 0, "评论一")
+	app.createComment(bob, posts[1].ID, 0, "评论二")
+	app.createComment(bob, posts[3].ID, 0, "评论三")
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []uint
+	}{
+		{"默认按创建时间倒序", url.Values{"limit": {"2"}},
+			[]uint{posts[5].ID, posts[4].ID, posts[3].ID, posts[2].ID, posts[1].ID, posts[0].ID}},
+		{"按创建时间正序", url.Values{"limit": {"4"}, "order": {"asc"}},
+			[]uint{posts[0].ID, posts[1].ID, posts[2].ID, posts[3].ID, posts[4].ID, posts[5].ID}},
+		{"按评论数倒序，相同时按ID", url.Values{"limit": {"2"}, "sort": {"comment_count"}},
+			[]uint{posts[1].ID, posts[3].ID, posts[5].ID, posts[4].ID, posts[2].ID, posts[0].ID}},
+		{"按作者筛选", url.Values{"limit": {"2"}, "author": {"bobby"}}, []uint{posts[5].ID}},
+		{"按标题筛选", url.Values{"title": {"文章3"}}, []uint{posts[3].ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := app.listPostIDs(tt.query)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("文章顺序为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPostsRejectsInvalidQuery(t *testing.T) {
+	app := newTestApp(t)
+	for _, query := range []string{"limit=-1", "sort=title", "cursor=abc", "author_id=x", "from=yesterday"} {
+		t.Run(query, func(t *testing.T) {
+			expect(t, app.request(http.MethodGet, "/api/public/posts?"+query, nil, ""), http.StatusBadRequest, nil)
+		})
+	}
+}
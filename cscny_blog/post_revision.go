@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === 文章修订历史 ===
+// 每次创建或修改标题/内容都保存一份完整快照，最新的修订即文章当前内容。
+// 超出保留策略（每篇最多保留条数、最长保留时间）的旧修订会被清理，但始终保留最新一条。
+
+var (
+	revisionMaxPerPost = 50          // 每篇文章最多保留的修订数（0表示不限，由配置加载）
+	revisionMaxAge     time.Duration // 修订最长保留时间（0表示不限，由配置加载）
+)
+
+// 修订中可能变化的字段
+const (
+	revisionFieldTitle   = "title"
+	revisionFieldContent = "content"
+)
+
+// PostRevision 文章修订
+type PostRevision struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	PostID       uint      `gorm:"not null;uniqueIndex:idx_post_revision" json:"post_id"`
+	Number       int       `gorm:"not null;uniqueIndex:idx_post_revision" json:"number"` // 文章内的修订序号，从1开始
+	Title        string    `gorm:"type:varchar(100);not null" json:"title"`              // 修订后的标题
+	Content      string    `gorm:"type:text;not null" json:"content,omitempty"`          // 修订后的内容（列表中不返回）
+	Changes      []string  `gorm:"type:varchar(100);serializer:json" json:"changes"`     // 相对上一修订变化的字段
+	EditorID     uint      `gorm:"not null" json:"editor_id"`                            // 修改人ID
+	Editor       User      `gorm:"foreignKey:EditorID" json:"editor"`                    // 修改人信息
+	RestoredFrom *int      `json:"restored_from,omitempty"`                              // 由哪个修订恢复而来
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RevisionRepository 修订仓储
+type RevisionRepository interface {
+	Create(rev *PostRevision) error                      // 自动分配修订序号
+	ListByPost(postID uint) ([]PostRevision, error)      // 按序号倒序，不含内容
+	Find(postID uint, number int) (*PostRevision, error) // 含修改人信息
+	Prune(postID uint, keep int, before time.Time) (int64, error)
+}
+
+// changedFields 比较修改前后的标题和内容
+func changedFields(oldTitle, oldContent string, post *Post) []string {
+	var changes []string
+	if oldTitle != post.Title {
+		changes = append(changes, revisionFieldTitle)
+	}
+	if oldContent != post.Content {
+		changes = append(changes, revisionFieldContent)
+	}
+	return changes
+}
+
+// recordRevision 保存文章当前内容为新修订，并按保留策略清理旧修订
+func recordRevision(revisions RevisionRepository, post *Post, editorID uint, changes []string, restoredFrom *int) error {
+	rev := PostRevision{
+		PostID:       post.ID,
+		Title:        post.Title,
+		Content:      post.Content,
+		Changes:      changes,
+		EditorID:     editorID,
+		RestoredFrom: restoredFrom,
+	}
+	if err := revisions.Create(&rev); err != nil {
+		return err
+	}
+
+	var before time.Time
+	if revisionMaxAge > 0 {
+		before = time.Now().Add(-revisionMaxAge)
+	}
+	if revisionMaxPerPost > 0 || !before.IsZero() {
+		if _, err := revisions.Prune(post.ID, revisionMaxPerPost, before); err != nil {
+			logger.Printf("清理文章修订失败: post=%d err=%v", post.ID, err) // 清理失败不影响本次修改
+		}
+	}
+	return nil
+}
+
+// backfillPostRevisions 为升级前创建、尚无修订记录的文章补建初始修订
+func backfillPostRevisions(db *gorm.DB) error {
+	var batch []Post
+	return db.Where("NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_revisions.post_id = posts.id)").
+		FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+			for _, post := range batch {
+				rev := PostRevision{
+					PostID:    post.ID,
+					Number:    1,
+					Title:     post.Title,
+					Content:   post.Content,
+					Changes:   []string{revisionFieldTitle, revisionFieldContent},
+					EditorID:  post.UserID,
+					CreatedAt: post.UpdatedAt,
+				}
+				if err := tx.Create(&rev).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// loadRevisablePost 加载文章并校验当前用户能否查看/恢复其修订（作者或拥有post:edit_any权限）
+// 失败时已写入响应
+func loadRevisablePost(c *gin.Context, posts PostRepository) (*Post, bool) {
+	userId, _ := c.Get("userId")
+	postID, err := paramID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	post, err := posts.FindByID(postID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return nil, false
+		}
+		logger.Printf("查询文章失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+		return nil, false
+	}
+
+	if post.UserID != userId.(uint) && !hasPermission(c, permPostEditAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限查看此文章的修订记录"})
+		return nil, false
+	}
+	return post, true
+}
+
+// findRevision 按序号查询修订，失败时已写入响应
+func findRevision(c *gin.Context, revisions RevisionRepository, postID uint, value string) (*PostRevision, bool) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的修订序号: " + value})
+		return nil, false
+	}
+
+	rev, err := revisions.Find(postID, number)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("修订%d不存在或已被清理", number)})
+			return nil, false
+		}
+		logger.Printf("查询文章修订失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询修订失败"})
+		return nil, false
+	}
+	return rev, true
+}
+
+// === 修订Handler ===
+// 文章修订列表（作者或拥有post:edit_any权限的用户）
+func listRevisionsHandler(posts PostRepository, revisions RevisionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := loadRevisablePost(c, posts)
+		if !ok {
+			return
+		}
+
+		list, err := revisions.ListByPost(post.ID)
+		if err != nil {
+			logger.Printf("查询文章修订失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询修订失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list})
+	}
+}
+
+// 单个修订详情（含完整内容）
+func getRevisionHandler(posts PostRepository, revisions RevisionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := loadRevisablePost(c, posts)
+		if !ok {
+			return
+		}
+		rev, ok := findRevision(c, revisions, post.ID, c.Param("rev"))
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": rev})
+	}
+}
+
+// 两个修订之间的行级差异
+// 查询参数：from、to（修订序号）
+func diffRevisionsHandler(posts PostRepository, revisions RevisionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := loadRevisablePost(c, posts)
+		if !ok {
+			return
+		}
+		from, ok := findRevision(c, revisions, post.ID, c.Query("from"))
+		if !ok {
+			return
+		}
+		to, ok := findRevision(c, revisions, post.ID, c.Query("to"))
+		if !ok {
+			return
+		}
+
+		oldLines, newLines := splitLines(from.Content), splitLines(to.Content)
+		if len(oldLines) > maxDiffLines || len(newLines) > maxDiffLines {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("内容超过%d行，无法比较", maxDiffLines)})
+			return
+		}
+		lines := diffLines(oldLines, newLines)
+
+		added, removed := 0, 0
+		for _, line := range lines {
+			switch line.Op {
+			case diffInsert:
+				added++
+			case diffDelete:
+				removed++
+			}
+		}
+
+		result := gin.H{
+			"from":    from.Number,
+			"to":      to.Number,
+			"lines":   lines,
+			"added":   added,
+			"removed": removed,
+		}
+		if from.Title != to.Title {
+			result["title"] = gin.H{"from": from.Title, "to": to.Title}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": result})
+	}
+}
+
+// 恢复到指定修订（以旧修订内容生成一条新修订，不删除中间的历史）
+func restoreRevisionHandler(posts PostRepository, revisions RevisionRepository, index *searchIndex, renderer *contentRenderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		post, ok := loadRevisablePost(c, posts)
+		if !ok {
+			return
+		}
+		rev, ok := findRevision(c, revisions, post.ID, c.Param("rev"))
+		if !ok {
+			return
+		}
+
+		oldTitle, oldContent := post.Title, post.Content
+		post.Title, post.Content = rev.Title, rev.Content
+		changes := changedFields(oldTitle, oldContent, post)
+		if len(changes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "文章内容与该修订相同，无需恢复"})
+			return
+		}
+
+		if err := posts.Update(post); err != nil {
+			logger.Printf("恢复文章修订失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复修订失败"})
+			return
+		}
+		if err := recordRevision(revisions, post, userId.(uint), changes, &rev.Number); err != nil {
+			logger.Printf("保存文章修订失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存修订记录失败"})
+			return
+		}
+		index.IndexPost(post)
+		renderer.Invalidate(post.ID)
+
+		if updated, err := posts.FindWithAuthor(post.ID); err == nil {
+			post = updated
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": post})
+	}
+}
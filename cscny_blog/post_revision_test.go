@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listRevisions 查询文章的修订序号（按序号倒序）
+func (a *testApp) listRevisions(postID uint, token string) []int {
+	a.t.Helper()
+	var resp struct {
+		Data []PostRevision `json:"data"`
+	}
+	expect(a.t, a.request(http.MethodGet, fmt.Sprintf("/api/protected/posts/%d/revisions", postID), nil, token), http.StatusOK, &resp)
+	numbers := make([]int, 0, len(resp.Data))
+	for _, rev := range resp.Data {
+		numbers = append(numbers, rev.Number)
+	}
+	return numbers
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    []string
+	}{
+		{"没有变化", "标题", "内容", nil},
+		{"只改标题", "旧标题", "内容", []string{revisionFieldTitle}},
+		{"只改内容", "标题", "旧内容", []string{revisionFieldContent}},
+		{"都有变化", "旧标题", "旧内容", []string{revisionFieldTitle, revisionFieldContent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := &Post{Title: "标题", Content: "内容"}
+			if got := changedFields(tt.title, tt.content, post); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("变化字段为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostRevisions(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	post := app.createPost(author, gin.H{"title": "第一版", "content": "第一行\n第二行\n第三行"})
+	path := fmt.Sprintf("/api/protected/posts/%d", post.ID)
+
+	expect(t, app.request(http.MethodPut, path, gin.H{"content": "第一行\n修改后的第二行\n第三行"}, author), http.StatusOK, nil)
+	expect(t, app.request(http.MethodPut, path, gin.H{"title": "第二版"}, author), http.StatusOK, nil)
+	if got := app.listRevisions(post.ID, author); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Fatalf("修订序号为%v", got)
+	}
+
+	var diff struct {
+		Data struct {
+			Added   int        `json:"added"`
+			Removed int        `json:"removed"`
+			Lines   []DiffLine `json:"lines"`
+			Title   gin.H      `json:"title"`
+		} `json:"data"`
+	}
+	expect(t, app.request(http.MethodGet, path+"/revisions/diff?from=1&to=3", nil, author), http.StatusOK, &diff)
+	if diff.Data.Added != 1 || diff.Data.Removed != 1 || len(diff.Data.Lines) != 4 || diff.Data.Title["to"] != "第二版" {
+		t.Fatalf("差异为%+v", diff.Data)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"其他用户不能查看", http.MethodGet, path + "/revisions", stranger, http.StatusForbidden},
+		{"修订不存在", http.MethodGet, path + "/revisions/99", author, http.StatusNotFound},
+		{"修订序号无效", http.MethodGet, path + "/revisions/x", author, http.StatusBadRequest},
+		{"差异缺少参数", http.MethodGet, path + "/revisions/diff?from=1", author, http.StatusBadRequest},
+		{"与当前内容相同时不能恢复", http.MethodPost, path + "/revisions/3/restore", author, http.StatusBadRequest},
+		{"其他用户不能恢复", http.MethodPost, path + "/revisions/1/restore", stranger, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(tt.method, tt.path, nil, tt.token), tt.status, nil)
+		})
+	}
+
+	// 恢复生成新修订，不删除中间的历史
+	var restored postResponse
+	expect(t, app.request(http.MethodPost, path+"/revisions/1/restore", nil, author), http.StatusOK, &restored)
+	if restored.Data.Title != "第一版" || restored.Data.Content != "第一行\n第二行\n第三行" {
+		t.Fatalf("恢复后的文章为%+v", restored.Data)
+	}
+	var rev struct {
+		Data PostRevision `json:"data"`
+	}
+	expect(t, app.request(http.MethodGet, path+"/revisions/4", nil, author), http.StatusOK, &rev)
+	if rev.Data.RestoredFrom == nil || *rev.Data.RestoredFrom != 1 ||
+		!reflect.DeepEqual(rev.Data.Changes, []string{revisionFieldTitle, revisionFieldContent}) {
+		t.Fatalf("恢复产生的修订为%+v", rev.Data)
+	}
+}
+
+func TestRevisionRetention(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) { cfg.Revision.MaxPerPost = 2 })
+	_, author := app.newUser("author")
+	post := app.createPost(author, nil)
+	for i := 0; i < 3; i++ {
+		w := app.request(http.MethodPut, fmt.Sprintf("/api/protected/posts/%d", post.ID), gin.H{"title": fmt.Sprintf("第%d次修改", i)}, author)
+		expect(t, w, http.StatusOK, nil)
+	}
+	if got := app.listRevisions(post.ID, author); !reflect.DeepEqual(got, []int{4, 3}) {
+		t.Fatalf("保留的修订为%v", got)
+	}
+}
+
+func TestRevisionNumberingConcurrent(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	post := app.createPost(author, nil)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rev := PostRevision{PostID: post.ID, Title: fmt.Sprintf("并发修改%d", i), Content: "并发修改的内容", EditorID: 1}
+			errs <- app.repos.Revisions.Create(&rev)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("并发保存修订失败: %v", err)
+		}
+	}
+
+	numbers := app.listRevisions(post.ID, author)
+	sort.Ints(numbers)
+	for i, n := range numbers {
+		if n != i+1 {
+			t.Fatalf("修订序号不连续: %v", numbers)
+		}
+	}
+	if len(numbers) != writers+1 {
+		t.Fatalf("共%d条修订，期望%d条", len(numbers), writers+1)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// === 文章永久链接 ===
+// 文章slug由标题生成：英文和数字保留，中文转为不带声调的拼音，其他文字去掉；
+// 标题修改后slug随之变化，旧slug保存为跳转记录，原有链接继续有效。
+
+const (
+	maxSlugLength = 80     // slug最大长度（不含去重后缀），超出时按单词截断
+	fallbackSlug  = "post" // 标题无法生成slug时使用
+	slugSeparator = "-"
+)
+
+// pinyinArgs 拼音转换参数（不带声调，多音字取常用读音）
+var pinyinArgs = pinyin.NewArgs()
+
+// PostSlugRedirect 文章改名前使用过的slug
+type PostSlugRedirect struct {
+	ID        uint   `gorm:"primarykey"`
+	Slug      string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	PostID    uint   `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// slugify 由标题生成slug（不保证唯一）
+// 例如 "Go 语言入门" → "go-yu-yan-ru-men"，"Café crème" → "cafe-creme"
+func slugify(title string) string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	// NFKD：全角转半角，并把带变音符号的字母拆成基本字母+组合符号
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Mn, r): // 丢弃组合符号，é → e
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				words = append(words, py[0])
+			}
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	slug := ""
+	for _, w := range words {
+		if len(slug)+len(slugSeparator)+len(w) > maxSlugLength {
+			break
+		}
+		if slug != "" {
+			slug += slugSeparator
+		}
+		slug += w
+	}
+	if slug == "" {
+		return fallbackSlug
+	}
+	// 纯数字的slug会与文章ID混淆
+	if _, err := strconv.ParseUint(slug, 10, 64); err == nil {
+		slug = fallbackSlug + slugSeparator + slug
+	}
+	return slug
+}
+
+// slugHasBase 判断slug是否由base生成（base本身或base加数字后缀）
+func slugHasBase(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+slugSeparator)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseUint(suffix, 10, 64)
+	return err == nil
+}
+
+// lookupPost 按数字ID或slug（含旧slug）查找文章，返回的文章不含关联数据
+func lookupPost(posts PostRepository, key string) (*Post, error) {
+	if id, err := strconv.ParseUint(key, 10, 64); err == nil {
+		if id == 0 {
+			return nil, ErrNotFound
+		}
+		return posts.FindByID(uint(id))
+	}
+	return posts.FindBySlug(key)
+}
+
+// isOldSlug 判断是否通过改名前的旧slug访问（通过ID访问不算）
+func isOldSlug(key string, post *Post) bool {
+	if post.Slug == "" || key == post.Slug {
+		return false
+	}
+	_, err := strconv.ParseUint(key, 10, 64)
+	return err != nil
+}
+
+// postPath 文章页面的规范路径
+func postPath(post *Post) string {
+	if post.Slug == "" {
+		return "/posts/" + strconv.FormatUint(uint64(post.ID), 10)
+	}
+	return "/posts/" + post.Slug
+}
+
+// redirectToSlug 将请求永久跳转到以新slug结尾的同一路径（保留查询参数）
+func redirectToSlug(c *gin.Context, key, slug string) {
+	target := strings.TrimSuffix(c.Request.URL.Path, key) + slug
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, target)
+}
+
+// backfillPostSlugs 为升级前创建、尚无slug的文章生成slug
+func backfillPostSlugs(db *gorm.DB) error {
+	var batch []Post
+	return db.Unscoped().Where("slug IS NULL OR slug = ''").
+		FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+			for _, post := range batch {
+				slug, err := uniquePostSlug(db, slugify(post.Title), post.ID)
+				if err != nil {
+					return err
+				}
+				if err := db.Model(&Post{}).Unscoped().Where("id = ?", post.ID).Update("slug", slug).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
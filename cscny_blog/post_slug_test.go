@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Go 语言入门", "go-yu-yan-ru-men"},
+		{"Café crème", "cafe-creme"},
+		{"ＧＩＮ　框架", "gin-kuang-jia"},
+		{"Hello, World!", "hello-world"},
+		{"!!!", fallbackSlug},
+		{"こんにちは", fallbackSlug},
+		{"2024", "post-2024"},
+		{strings.Repeat("word ", 30), strings.TrimSuffix(strings.Repeat("word-", 16), "-")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got := slugify(tt.title)
+			if got != tt.want {
+				t.Fatalf("slugify(%q) = %q，期望%q", tt.title, got, tt.want)
+			}
+			if len(got) > maxSlugLength {
+				t.Fatalf("slug长度为%d", len(got))
+			}
+		})
+	}
+}
+
+func TestSlugHasBase(t *testing.T) {
+	tests := []struct {
+		slug string
+		want bool
+	}{
+		{"go", true},
+		{"go-2", true},
+		{"go-12", true},
+		{"go-yu-yan", false},
+		{"go-", false},
+		{"golang", false},
+	}
+	for _, tt := range tests {
+		if got := slugHasBase(tt.slug, "go"); got != tt.want {
+			t.Fatalf("slugHasBase(%q) = %v，期望%v", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestPostSlugs(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+
+	first := app.createPost(token, gin.H{"title": "Hello World"})
+	second := app.createPost(token, gin.H{"title": "hello world!"})
+	if first.Slug != "hello-world" || second.Slug != "hello-world-2" {
+		t.Fatalf("slug为%s和%s", first.Slug, second.Slug)
+	}
+
+	// 删除的文章仍占用slug
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/posts/%d", second.ID), nil, token), http.StatusOK, nil)
+	if third := app.createPost(token, gin.H{"title": "Hello World"}); third.Slug != "hello-world-3" {
+		t.Fatalf("slug为%s", third.Slug)
+	}
+
+	// 只改内容或改成同slug的标题时slug不变
+	path := fmt.Sprintf("/api/protected/posts/%d", first.ID)
+	var resp postResponse
+	expect(t, app.request(http.MethodPut, path, gin.H{"title": "Hello, World"}, token), http.StatusOK, &resp)
+	if resp.Data.Slug != "hello-world" {
+		t.Fatalf("slug变为%s", resp.Data.Slug)
+	}
+
+	// 改标题后旧slug跳转到新slug
+	expect(t, app.request(http.MethodPut, path, gin.H{"title": "新标题"}, token), http.StatusOK, &resp)
+	if resp.Data.Slug != "xin-biao-ti" {
+		t.Fatalf("改名后slug为%s", resp.Data.Slug)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		location string
+	}{
+		{"按ID访问", fmt.Sprintf("/api/public/posts/%d", first.ID), http.StatusOK, ""},
+		{"按slug访问", "/api/public/posts/xin-biao-ti", http.StatusOK, ""},
+		{"旧slug跳转并保留查询参数", "/api/public/posts/hello-world?x=1", http.StatusMovedPermanently, "/api/public/posts/xin-biao-ti?x=1"},
+		{"网页旧slug跳转", "/posts/hello-world", http.StatusMovedPermanently, "/posts/xin-biao-ti"},
+		{"slug不存在", "/api/public/posts/no-such-post", http.StatusNotFound, ""},
+		{"ID为0", "/api/public/posts/0", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.request(http.MethodGet, tt.path, nil, "")
+			expect(t, w, tt.status, nil)
+			if loc := w.Header().Get("Location"); loc != tt.location {
+				t.Fatalf("跳转到%q，期望%q", loc, tt.location)
+			}
+		})
+	}
+
+	// 改回原标题时重新使用自己的旧slug，其他文章不能占用
+	expect(t, app.request(http.MethodPut, path, gin.H{"title": "Hello World"}, token), http.StatusOK, &resp)
+	if resp.Data.Slug != "hello-world" {
+		t.Fatalf("改回原标题后slug为%s", resp.Data.Slug)
+	}
+	other := app.createPost(token, gin.H{"title": "新标题"})
+	if other.Slug != "xin-biao-ti-2" {
+		t.Fatalf("其他文章占用了旧slug: %s", other.Slug)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 文章发布流程 ===
+// 草稿(draft) → 定时(scheduled) → 已发布(published) → 已归档(archived)。
+// 公开接口只展示已发布的文章，作者可以看到自己的全部文章。
+
+const (
+	postStatusDraft     = "draft"
+	postStatusScheduled = "scheduled"
+	postStatusPublished = "published"
+	postStatusArchived  = "archived"
+)
+
+const publishSchedulerInterval = 30 * time.Second // 定时发布检查间隔
+
+// postStatusTransitions 允许的状态变更（状态不变视为合法）
+var postStatusTransitions = map[string][]string{
+	postStatusDraft:     {postStatusScheduled, postStatusPublished},
+	postStatusScheduled: {postStatusDraft, postStatusPublished},
+	postStatusPublished: {postStatusArchived},
+	postStatusArchived:  {postStatusPublished},
+}
+
+// isValidPostStatus 是否为已知的文章状态
+func isValidPostStatus(status string) bool {
+	_, ok := postStatusTransitions[status]
+	return ok
+}
+
+// applyPostStatus 校验并应用状态变更；publishAt仅对定时发布有效（nil表示沿用原定时间）
+func applyPostStatus(post *Post, status string, publishAt *time.Time, now time.Time) error {
+	if post.Status != "" && post.Status != status {
+		allowed := false
+		for _, to := range postStatusTransitions[post.Status] {
+			if to == status {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("文章状态不能从%s变更为%s", post.Status, status)
+		}
+	}
+
+	switch status {
+	case postStatusScheduled:
+		if publishAt == nil {
+			publishAt = post.PublishAt
+		}
+		if publishAt == nil || !publishAt.After(now) {
+			return errors.New("定时发布需要指定晚于当前时间的publish_at")
+		}
+		post.PublishAt = publishAt
+	case postStatusPublished:
+		if post.PublishedAt == nil {
+			post.PublishedAt = &now // 重新发布已归档的文章时保留首次发布时间
+		}
+		post.PublishAt = nil
+	case postStatusDraft:
+		post.PublishAt = nil
+	}
+	post.Status = status
+	return nil
+}
+
+// canViewPost 已发布的文章所有人可见，其余状态仅作者和拥有post:edit_any权限的用户可见
+func canViewPost(c *gin.Context, post *Post) bool {
+	if post.Status == postStatusPublished {
+		return true
+	}
+	userId, ok := c.Get("userId")
+	if !ok {
+		return false
+	}
+	return post.UserID == userId.(uint) || hasPermission(c, permPostEditAny)
+}
+
+// publishScheduledPosts 发布所有到期的定时文章
+func publishScheduledPosts(posts PostRepository, index *searchIndex) error {
+	published, err := posts.PublishDue(time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range published {
+		index.IndexPost(&published[i])
+		logger.Printf("定时文章已发布: id=%d", published[i].ID)
+	}
+	return nil
+}
+
+// publishLoop 后台定时发布到期的文章
+func publishLoop(posts PostRepository, index *searchIndex, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := publishScheduledPosts(posts, index); err != nil {
+			logger.Printf("定时发布文章失败: %v", err)
+		}
+		<-ticker.C
+	}
+}
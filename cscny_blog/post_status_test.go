@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplyPostStatus(t *testing.T) {
+	now := time.Now()
+	future, past := now.Add(time.Hour), now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		publishAt *time.Time
+		wantErr   bool
+	}{
+		{"新建草稿", "", postStatusDraft, nil, false},
+		{"草稿定时发布", postStatusDraft, postStatusScheduled, &future, false},
+		{"定时发布缺少时间", postStatusDraft, postStatusScheduled, nil, true},
+		{"定时发布时间已过", postStatusDraft, postStatusScheduled, &past, true},
+		{"草稿直接发布", postStatusDraft, postStatusPublished, nil, false},
+		{"定时改回草稿", postStatusScheduled, postStatusDraft, nil, false},
+		{"发布后归档", postStatusPublished, postStatusArchived, nil, false},
+		{"归档后重新发布", postStatusArchived, postStatusPublished, nil, false},
+		{"已发布不能改回草稿", postStatusPublished, postStatusDraft, nil, true},
+		{"草稿不能直接归档", postStatusDraft, postStatusArchived, nil, true},
+		{"状态不变", postStatusPublished, postStatusPublished, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := &Post{Status: tt.from}
+			err := applyPostStatus(post, tt.to, tt.publishAt, now)
+			if tt.wantErr {
+				if err == nil || post.Status != tt.from {
+					t.Fatalf("期望拒绝变更，err=%v status=%s", err, post.Status)
+				}
+				return
+			}
+			if err != nil || post.Status != tt.to {
+				t.Fatalf("变更失败: err=%v status=%s", err, post.Status)
+			}
+		})
+	}
+}
+
+func TestApplyPostStatusTimestamps(t *testing.T) {
+	now := time.Now()
+	first := now.Add(-24 * time.Hour)
+	future := now.Add(time.Hour)
+
+	// 重新发布已归档的文章时保留首次发布时间
+	post := &Post{Status: postStatusArchived, PublishedAt: &first}
+	if err := applyPostStatus(post, postStatusPublished, nil, now); err != nil || !post.PublishedAt.Equal(first) {
+		t.Fatalf("首次发布时间被修改: %v（err=%v）", post.PublishedAt, err)
+	}
+
+	// 定时文章未指定新时间时沿用原定时间，发布后清空
+	post = &Post{Status: postStatusScheduled, PublishAt: &future}
+	if err := applyPostStatus(post, postStatusScheduled, nil, now); err != nil || !post.PublishAt.Equal(future) {
+		t.Fatalf("定时时间为%v（err=%v）", post.PublishAt, err)
+	}
+	if err := applyPostStatus(post, postStatusPublished, nil, now); err != nil || post.PublishAt != nil || !post.PublishedAt.Equal(now) {
+		t.Fatalf("发布后publish_at=%v published_at=%v（err=%v）", post.PublishAt, post.PublishedAt, err)
+	}
+}
+
+func TestUnpublishedPostVisibility(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	_, moderator := app.newUser("moderator", roleModerator)
+	draft := app.createPost(author, gin.H{"status": postStatusDraft})
+	app.createPost(author, nil)
+
+	path := fmt.Sprintf("/api/public/posts/%d", draft.ID)
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"未登录", "", http.StatusNotFound},
+		{"其他用户", stranger, http.StatusNotFound},
+		{"作者", author, http.StatusOK},
+		{"版主", moderator, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodGet, path, nil, tt.token), tt.status, nil)
+		})
+	}
+
+	// 草稿不能评论
+	commentsPath := fmt.Sprintf("/api/protected/posts/%d/comments", draft.ID)
+	expect(t, app.request(http.MethodPost, commentsPath, gin.H{"content": "评论"}, author), http.StatusBadRequest, nil)
+
+	// 查询其他状态需要登录，且只返回自己的文章
+	expect(t, app.request(http.MethodGet, "/api/public/posts?status=draft", nil, ""), http.StatusUnauthorized, nil)
+	expect(t, app.request(http.MethodGet, "/api/public/posts?status=deleted", nil, author), http.StatusBadRequest, nil)
+	for token, want := range map[string]int{author: 1, stranger: 0, moderator: 1} {
+		var resp listResponse[Post]
+		expect(t, app.request(http.MethodGet, "/api/public/posts?status=draft", nil, token), http.StatusOK, &resp)
+		if len(resp.Data) != want {
+			t.Fatalf("草稿列表有%d篇，期望%d篇", len(resp.Data), want)
+		}
+	}
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	publishAt := time.Now().Add(time.Hour)
+	post := app.createPost(author, gin.H{"title": "定时文章", "status": postStatusScheduled, "publish_at": publishAt})
+	if post.Status != postStatusScheduled {
+		t.Fatalf("状态为%s", post.Status)
+	}
+
+	if err := publishScheduledPosts(app.repos.Posts, app.index); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusNotFound, nil)
+
+	// 到期后发布，并进入搜索结果
+	app.db.Model(&Post{}).Where("id = ?", post.ID).Update("publish_at", time.Now().Add(-time.Second))
+	if err := publishScheduledPosts(app.repos.Posts, app.index); err != nil {
+		t.Fatal(err)
+	}
+	var resp postResponse
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, ""), http.StatusOK, &resp)
+	if resp.Data.Status != postStatusPublished || resp.Data.PublishAt != nil || resp.Data.PublishedAt == nil {
+		t.Fatalf("发布后的文章为status=%s publish_at=%v published_at=%v", resp.Data.Status, resp.Data.PublishAt, resp.Data.PublishedAt)
+	}
+	if _, total := app.index.Search("定时", "", 0, 10); total != 1 {
+		t.Fatalf("发布后搜索命中%d条", total)
+	}
+}
+
+func TestUpdatePostStatusTransition(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	post := app.createPost(author, nil)
+	path := fmt.Sprintf("/api/protected/posts/%d", post.ID)
+
+	tests := []struct {
+		name   string
+		status string
+		code   int
+	}{
+		{"已发布不能改回草稿", postStatusDraft, http.StatusBadRequest},
+		{"归档", postStatusArchived, http.StatusOK},
+		{"未知状态", "hidden", http.StatusBadRequest},
+		{"重新发布", postStatusPublished, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPut, path, gin.H{"status": tt.status}, author), tt.code, nil)
+		})
+	}
+}
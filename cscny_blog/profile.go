@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// === 个人资料 ===
+// 公开资料包含用户名、简介、头像、加入时间和已发布文章数；邮箱只在本人查看时返回。
+// 头像居中裁剪为正方形并缩放（见image_process.go），以随机文件名保存在Storage的avatars/目录下，
+// 更换或移除后删除旧文件。修改密码需要当前密码，成功后注销该用户的其他全部会话。
+
+const (
+	maxBioLength  = 500     // 简介最大字符数
+	avatarMaxSize = 5 << 20 // 头像原图大小上限（字节）
+)
+
+// UserProfile 用户公开资料
+type UserProfile struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Bio       string    `json:"bio"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
+	JoinedAt  time.Time `json:"joined_at"`
+	PostCount int64     `json:"post_count"` // 已发布文章数
+}
+
+// buildProfile 组装公开资料
+func buildProfile(posts PostRepository, user *User) (*UserProfile, error) {
+	count, err := posts.CountByAuthor(user.ID, postStatusPublished)
+	if err != nil {
+		return nil, err
+	}
+	return &UserProfile{
+		ID:        user.ID,
+		Username:  user.Username,
+		Bio:       user.Bio,
+		AvatarURL: user.AvatarURL,
+		JoinedAt:  user.CreatedAt,
+		PostCount: count,
+	}, nil
+}
+
+// normalizeBio 去除首尾空白并校验长度
+func normalizeBio(bio string) (string, error) {
+	bio = strings.TrimSpace(bio)
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return "", errors.New("简介不能超过" + strconv.Itoa(maxBioLength) + "字")
+	}
+	return bio, nil
+}
+
+// saveAvatar 处理并保存头像，成功后删除旧头像文件；返回新的存储键
+func saveAvatar(users UserRepository, storage Storage, user *User, data []byte) (string, error) {
+	contentType := detectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok || !strings.HasPrefix(contentType, "image/") {
+		return "", errUnsupportedAvatar
+	}
+	if contentType == "image/gif" {
+		ext = ".png" // 只保留第一帧
+	}
+	avatar, err := processAvatar(data, contentType)
+	if err != nil {
+		return "", &avatarImageError{err}
+	}
+
+	name, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	key := "avatars/" + strconv.FormatUint(uint64(user.ID), 10) + "/" + name + ext
+	if err := storage.Save(key, bytes.NewReader(avatar)); err != nil {
+		return "", err
+	}
+	if err := users.SetAvatar(user.ID, key); err != nil {
+		deleteAvatarFile(storage, key)
+		return "", err
+	}
+	deleteAvatarFile(storage, user.AvatarKey)
+	return key, nil
+}
+
+var errUnsupportedAvatar = errors.New("头像只支持JPEG、PNG或GIF图片")
+
+// avatarImageError 图片无法处理（尺寸过大、无法解码等），错误信息可直接返回给用户
+type avatarImageError struct {
+	err error
+}
+
+func (e *avatarImageError) Error() string { return e.err.Error() }
+
+// deleteAvatarFile 删除头像文件；失败只记录日志
+func deleteAvatarFile(storage Storage, key string) {
+	if key == "" {
+		return
+	}
+	if err := storage.Delete(key); err != nil {
+		logger.Printf("删除头像文件失败: key=%s err=%v", key, err)
+	}
+}
+
+// changePassword 校验当前密码后设置新密码并注销该用户的全部会话（调用方负责为当前客户端重新登录）
+func changePassword(c *gin.Context, users UserRepository, tokens *tokenStore, userID uint, current, password string) error {
+	user, err := users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)) != nil {
+		return errWrongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+	if err := users.UpdatePassword(user.ID, string(hash)); err != nil {
+		return err
+	}
+	if err := tokens.revokeUser(user.ID); err != nil {
+		return err
+	}
+	securityEvent("password_changed", "user=%d ip=%s", user.ID, c.ClientIP())
+	return nil
+}
+
+var errWrongPassword = errors.New("当前密码错误")
+
+// === 个人资料Handler ===
+// 用户公开资料
+func getProfileHandler(users UserRepository, posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUserParam(c, users)
+		if !ok {
+			return
+		}
+		profile, err := buildProfile(posts, user)
+		if err != nil {
+			logger.Printf("查询用户文章数失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": profile})
+	}
+}
+
+// 用户的文章列表（已发布；本人可通过status参数查看其他状态，参数同文章列表）
+func listUserPostsHandler(users UserRepository, posts PostRepository, reactions ReactionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUserParam(c, users)
+		if !ok {
+			return
+		}
+		query, err := parsePostQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query.AuthorID = user.ID
+		query.AuthorName = ""
+		query.Status = c.DefaultQuery("status", postStatusPublished)
+		if !isValidPostStatus(query.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文章状态: " + query.Status})
+			return
+		}
+		if query.Status != postStatusPublished && currentUserID(c) != user.ID && !hasPermission(c, permPostEditAny) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "只能查看自己未发布的文章"})
+			return
+		}
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询用户文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+		if err := attachPostReactions(c, reactions, list); err != nil {
+			logger.Printf("查询文章回应失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": list, "pagination": page})
+	}
+}
+
+// 当前用户的资料（含邮箱及验证状态）
+func getMyProfileHandler(users UserRepository, posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+			return
+		}
+		profile, err := buildProfile(posts, user)
+		if err != nil {
+			logger.Printf("查询用户文章数失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{
+			"profile":        profile,
+			"email":          user.Email,
+			"email_verified": user.EmailVerifiedAt != nil,
+			// 已申请注销时为计划执行时间，见account_data.go
+			"deletion_scheduled_at": user.DeletionScheduledAt,
+		}})
+	}
+}
+
+// 修改个人简介
+func updateProfileHandler(users UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		var input struct {
+			Bio *string `json:"bio" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bio, err := normalizeBio(*input.Bio)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := users.UpdateProfile(userId.(uint), bio); err != nil {
+			logger.Printf("更新个人资料失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "个人资料已更新", "data": gin.H{"bio": bio}})
+	}
+}
+
+// 上传头像（multipart字段file，支持JPEG、PNG、GIF）
+func uploadAvatarHandler(users UserRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		data, _, uerr := readUploadedFile(c, avatarMaxSize)
+		if uerr != nil {
+			c.JSON(uerr.Status, gin.H{"error": uerr.Message})
+			return
+		}
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+			return
+		}
+
+		key, err := saveAvatar(users, storage, user, data)
+		if err != nil {
+			var imageErr *avatarImageError
+			switch {
+			case errors.Is(err, errUnsupportedAvatar):
+				c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			case errors.As(err, &imageErr):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				logger.Printf("保存头像失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"avatar_url": uploadURLPrefix + key}})
+	}
+}
+
+// 移除头像
+func deleteAvatarHandler(users UserRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "移除头像失败"})
+			return
+		}
+		if user.AvatarKey == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "尚未设置头像"})
+			return
+		}
+		if err := users.SetAvatar(user.ID, ""); err != nil {
+			logger.Printf("移除头像失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "移除头像失败"})
+			return
+		}
+		deleteAvatarFile(storage, user.AvatarKey)
+		c.JSON(http.StatusOK, gin.H{"message": "头像已移除"})
+	}
+}
+
+// 修改密码（需当前密码）；其他设备上的会话全部失效，返回当前客户端使用的新令牌
+func changePasswordHandler(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, _ := c.Get("userId")
+		var input struct {
+			CurrentPassword string `json:"current_password" binding:"required"`
+			NewPassword     string `json:"new_password" binding:"required,min=6,max=32"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := changePassword(c, users, tokens, userId.(uint), input.CurrentPassword, input.NewPassword); err != nil {
+			if errors.Is(err, errWrongPassword) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("修改密码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改密码失败"})
+			return
+		}
+
+		pair, err := tokens.issue(userId.(uint), "")
+		if err != nil {
+			logger.Printf("JWT生成失败: %v", err)
+			c.JSON(http.StatusOK, gin.H{"message": "密码已修改，请重新登录"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "密码已修改，其他设备已退出登录",
+			"access_token":  pair.AccessToken,
+			"refresh_token": pair.RefreshToken,
+			"token_type":    pair.TokenType,
+			"expires_in":    pair.ExpiresIn,
+		})
+	}
+}
+
+// === 页面Handler ===
+// settingsNotices 设置页操作成功后跳转回设置页时显示的提示（?done=）
+var settingsNotices = map[string]string{
+	"profile":  "个人简介已保存",
+	"avatar":   "头像已更新",
+	"password": "密码已修改，其他设备已退出登录",
+	"deletion": "已撤销注销申请",
+}
+
+// 账号设置页
+func webSettingsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderSettings(c, http.StatusOK, gin.H{"Notice": settingsNotices[c.Query("done")]})
+	}
+}
+
+func renderSettingsError(c *gin.Context, status int, message string) {
+	renderSettings(c, status, gin.H{"Error": message})
+}
+
+// renderSettings 渲染设置页，附加注销说明所需的配置
+func renderSettings(c *gin.Context, status int, data gin.H) {
+	if accountDeletionGracePeriod > 0 {
+		data["GracePeriod"] = humanizeWait(accountDeletionGracePeriod)
+	}
+	data["AnonymizeContent"] = accountDeletionPolicy == deletionPolicyAnonymize
+	renderPage(c, status, "settings.html", "账号设置", data)
+}
+
+// 保存个人简介
+func webUpdateProfileHandler(users UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bio, err := normalizeBio(c.PostForm("bio"))
+		if err != nil {
+			renderSettingsError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := users.UpdateProfile(currentUserID(c), bio); err != nil {
+			logger.Printf("更新个人资料失败: %v", err)
+			renderSettingsError(c, http.StatusInternalServerError, "保存失败，请重试")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/settings?done=profile")
+	}
+}
+
+// 上传头像
+func webUploadAvatarHandler(users UserRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, _, uerr := readUploadedFile(c, avatarMaxSize)
+		if uerr != nil {
+			renderSettingsError(c, uerr.Status, uerr.Message)
+			return
+		}
+		user, _ := c.Get("currentUser")
+		if _, err := saveAvatar(users, storage, user.(*User), data); err != nil {
+			var imageErr *avatarImageError
+			switch {
+			case errors.Is(err, errUnsupportedAvatar):
+				renderSettingsError(c, http.StatusUnsupportedMediaType, err.Error())
+			case errors.As(err, &imageErr):
+				renderSettingsError(c, http.StatusBadRequest, err.Error())
+			default:
+				logger.Printf("保存头像失败: %v", err)
+				renderSettingsError(c, http.StatusInternalServerError, "上传失败，请重试")
+			}
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/settings?done=avatar")
+	}
+}
+
+// 移除头像
+func webDeleteAvatarHandler(users UserRepository, storage Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := c.Get("currentUser")
+		key := user.(*User).AvatarKey
+		if key != "" {
+			if err := users.SetAvatar(currentUserID(c), ""); err != nil {
+				logger.Printf("移除头像失败: %v", err)
+				renderSettingsError(c, http.StatusInternalServerError, "移除头像失败，请重试")
+				return
+			}
+			deleteAvatarFile(storage, key)
+		}
+		c.Redirect(http.StatusSeeOther, "/settings?done=avatar")
+	}
+}
+
+// 修改密码；当前浏览器重新登录，其他会话全部失效
+func webChangePasswordHandler(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		password := c.PostForm("password")
+		switch {
+		case len(password) < 6 || len(password) > 32:
+			renderSettingsError(c, http.StatusBadRequest, "新密码需为6-32个字符")
+			return
+		case password != c.PostForm("password_confirm"):
+			renderSettingsError(c, http.StatusBadRequest, "两次输入的密码不一致")
+			return
+		}
+
+		userID := currentUserID(c)
+		if err := changePassword(c, users, tokens, userID, c.PostForm("current_password"), password); err != nil {
+			if errors.Is(err, errWrongPassword) {
+				renderSettingsError(c, http.StatusForbidden, err.Error())
+				return
+			}
+			logger.Printf("修改密码失败: %v", err)
+			renderSettingsError(c, http.StatusInternalServerError, "修改密码失败，请重试")
+			return
+		}
+		if err := startSession(c, tokens, userID); err != nil {
+			logger.Printf("创建会话失败: %v", err)
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/settings?done=password")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// profileResponse 公开资料接口的响应
+type profileResponse struct {
+	Data UserProfile `json:"data"`
+}
+
+// profile 查询用户的公开资料
+func (a *testApp) profile(userID uint) UserProfile {
+	a.t.Helper()
+	var resp profileResponse
+	expect(a.t, a.request(http.MethodGet, fmt.Sprintf("/api/public/users/%d", userID), nil, ""), http.StatusOK, &resp)
+	return resp.Data
+}
+
+func TestNormalizeBio(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"去除首尾空白", "  你好 \n", "你好", true},
+		{"允许清空", "", "", true},
+		{"按字符计数", strings.Repeat("字", maxBioLength), strings.Repeat("字", maxBioLength), true},
+		{"超出长度", strings.Repeat("a", maxBioLength+1), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeBio(tt.input)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Fatalf("normalizeBio = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	app := newTestApp(t)
+	aliceID, alice := app.newUser("alice")
+	app.createPost(alice, nil)
+	app.createPost(alice, nil)
+	app.createPost(alice, gin.H{"status": postStatusDraft})
+
+	tests := []struct {
+		name   string
+		body   gin.H
+		status int
+		bio    string
+	}{
+		{"更新简介", gin.H{"bio": "  喜欢Go  "}, http.StatusOK, "喜欢Go"},
+		{"缺少bio", gin.H{}, http.StatusBadRequest, "喜欢Go"},
+		{"超出长度", gin.H{"bio": strings.Repeat("a", maxBioLength+1)}, http.StatusBadRequest, "喜欢Go"},
+		{"清空简介", gin.H{"bio": ""}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPut, "/api/protected/profile", tt.body, alice), tt.status, nil)
+			if got := app.profile(aliceID).Bio; got != tt.bio {
+				t.Fatalf("简介为%q，期望%q", got, tt.bio)
+			}
+		})
+	}
+
+	// 公开资料只统计已发布文章，不含邮箱
+	w := app.request(http.MethodGet, fmt.Sprintf("/api/public/users/%d", aliceID), nil, "")
+	expect(t, w, http.StatusOK, nil)
+	if strings.Contains(w.Body.String(), "alice@example.com") {
+		t.Fatalf("公开资料暴露了邮箱: %s", w.Body.String())
+	}
+	if p := app.profile(aliceID); p.Username != "alice" || p.PostCount != 2 || p.AvatarURL != "" {
+		t.Fatalf("公开资料为%+v", p)
+	}
+	expect(t, app.request(http.MethodGet, "/api/public/users/9999", nil, ""), http.StatusNotFound, nil)
+
+	// 网页修改简介
+	web := app.newWebClient()
+	web.login("alice")
+	expectPage(t, web.post("/settings/profile", url.Values{"bio": {strings.Repeat("a", maxBioLength+1)}}), http.StatusBadRequest)
+	if rec := web.post("/settings/profile", url.Values{"bio": {"网页简介"}}); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/settings?done=profile" {
+		t.Fatalf("保存简介返回%d，跳转到%s", rec.Code, rec.Header().Get("Location"))
+	}
+	if got := app.profile(aliceID).Bio; got != "网页简介" {
+		t.Fatalf("网页保存后简介为%q", got)
+	}
+}
+
+func TestListUserPosts(t *testing.T) {
+	app := newTestApp(t)
+	aliceID, alice := app.newUser("alice")
+	_, bob := app.newUser("bob")
+	_, moderator := app.newUser("moderator", roleModerator)
+	app.createPost(alice, gin.H{"title": "已发布"})
+	app.createPost(alice, gin.H{"title": "草稿", "status": postStatusDraft})
+	app.createPost(bob, gin.H{"title": "其他作者"})
+
+	path := fmt.Sprintf("/api/public/users/%d/posts", aliceID)
+	tests := []struct {
+		name   string
+		query  string
+		token  string
+		status int
+		titles []string
+	}{
+		{"默认只看已发布", "", "", http.StatusOK, []string{"已发布"}},
+		{"本人查看草稿", "?status=draft", alice, http.StatusOK, []string{"草稿"}},
+		{"他人不能查看草稿", "?status=draft", bob, http.StatusForbidden, nil},
+		{"版主可以查看草稿", "?status=draft", moderator, http.StatusOK, []string{"草稿"}},
+		{"无效的状态", "?status=unknown", alice, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp listResponse[Post]
+			expect(t, app.request(http.MethodGet, path+tt.query, nil, tt.token), tt.status, &resp)
+			var titles []string
+			for _, post := range resp.Data {
+				titles = append(titles, post.Title)
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+				t.Fatalf("文章为%v，期望%v", titles, tt.titles)
+			}
+		})
+	}
+	expect(t, app.request(http.MethodGet, "/api/public/users/9999/posts", nil, ""), http.StatusNotFound, nil)
+}
+
+func TestAvatar(t *testing.T) {
+	app := newTestApp(t)
+	aliceID, alice := app.newUser("alice")
+	const path = "/api/protected/profile/avatar"
+	exists := func(avatarURL string) bool {
+		return app.request(http.MethodGet, avatarURL, nil, "").Code == http.StatusOK
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		status   int
+		size     int
+	}{
+		{"横图裁剪缩放", "a.png", encodeTestImage(t, "image/png", 600, 400), http.StatusOK, avatarSize},
+		{"小图不放大", "b.jpg", encodeTestImage(t, "image/jpeg", 100, 120), http.StatusOK, 100},
+		{"GIF保存为PNG", "c.gif", encodeTestImage(t, "image/gif", 50, 50), http.StatusOK, 50},
+		{"不是图片", "a.txt", []byte("文本"), http.StatusUnsupportedMediaType, 0},
+		{"损坏的图片", "d.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), http.StatusBadRequest, 0},
+		{"空文件", "e.png", nil, http.StatusBadRequest, 0},
+	}
+	var previous string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Data struct {
+					AvatarURL string `json:"avatar_url"`
+				} `json:"data"`
+			}
+			expect(t, app.upload(path, alice, "", tt.filename, tt.content), tt.status, &resp)
+			current := app.profile(aliceID).AvatarURL
+			if tt.status != http.StatusOK {
+				if current != previous {
+					t.Fatalf("上传失败后头像变为%s", current)
+				}
+				return
+			}
+			if current != resp.Data.AvatarURL || !strings.HasPrefix(current, fmt.Sprintf("%savatars/%d/", uploadURLPrefix, aliceID)) {
+				t.Fatalf("头像地址为%s，公开资料中为%s", resp.Data.AvatarURL, current)
+			}
+			w := app.request(http.MethodGet, current, nil, "")
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+			if err != nil || cfg.Width != tt.size || cfg.Height != tt.size {
+				t.Fatalf("头像为%dx%d（%v）", cfg.Width, cfg.Height, err)
+			}
+			// 更换后删除旧文件
+			if previous != "" && exists(previous) {
+				t.Fatal("旧头像文件未删除")
+			}
+			previous = current
+		})
+	}
+
+	expect(t, app.request(http.MethodDelete, path, nil, alice), http.StatusOK, nil)
+	expect(t, app.request(http.MethodDelete, path, nil, alice), http.StatusNotFound, nil)
+	if exists(previous) || app.profile(aliceID).AvatarURL != "" {
+		t.Fatal("移除后头像仍存在")
+	}
+
+	// 网页上传和移除
+	web := app.newWebClient()
+	web.login("alice")
+	expectPage(t, web.postMultipart("/settings/avatar", uploadFormField, "a.txt", []byte("文本")), http.StatusUnsupportedMediaType)
+	if rec := web.postMultipart("/settings/avatar", uploadFormField, "a.png", encodeTestImage(t, "image/png", 64, 64)); rec.Code != http.StatusSeeOther {
+		t.Fatalf("网页上传头像返回%d", rec.Code)
+	}
+	avatar := app.profile(aliceID).AvatarURL
+	if avatar == "" || !exists(avatar) {
+		t.Fatal("网页上传头像失败")
+	}
+	if rec := web.post("/settings/avatar/delete", nil); rec.Code != http.StatusSeeOther {
+		t.Fatalf("网页移除头像返回%d", rec.Code)
+	}
+	if exists(avatar) || app.profile(aliceID).AvatarURL != "" {
+		t.Fatal("网页移除后头像仍存在")
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	session := app.login("alice")
+	other := app.login("alice") // 另一台设备
+
+	tests := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"当前密码错误", gin.H{"current_password": "wrong", "new_password": "newpassword"}, http.StatusForbidden},
+		{"新密码过短", gin.H{"current_password": testPassword, "new_password": "123"}, http.StatusBadRequest},
+		{"缺少当前密码", gin.H{"new_password": "newpassword"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, "/api/protected/auth/change-password", tt.body, session.AccessToken), tt.status, nil)
+		})
+	}
+	// 失败的尝试不影响已有会话
+	if w, _ := app.refresh(other.RefreshToken); w.Code != http.StatusOK {
+		t.Fatalf("修改失败后刷新令牌返回%d", w.Code)
+	}
+	other = app.login("alice")
+
+	var resp loginResponse
+	expect(t, app.request(http.MethodPost, "/api/protected/auth/change-password", gin.H{"current_password": testPassword, "new_password": "newpassword"}, session.AccessToken), http.StatusOK, &resp)
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("修改密码后未返回新令牌: %+v", resp)
+	}
+	// 其他会话失效，当前客户端使用新令牌
+	for _, token := range []string{session.RefreshToken, other.RefreshToken} {
+		if w, _ := app.refresh(token); w.Code != http.StatusUnauthorized {
+			t.Fatalf("修改密码后旧的刷新令牌返回%d", w.Code)
+		}
+	}
+	if w, _ := app.refresh(resp.RefreshToken); w.Code != http.StatusOK {
+		t.Fatalf("新的刷新令牌返回%d", w.Code)
+	}
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": testPassword}, ""), http.StatusUnauthorized, nil)
+	expect(t, app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": "newpassword"}, ""), http.StatusOK, nil)
+}
+
+func TestWebChangePassword(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	api := app.login("alice")
+	web := app.newWebClient()
+	web.login("alice")
+	other := app.newWebClient()
+	other.login("alice")
+
+	tests := []struct {
+		name    string
+		current string
+		confirm string
+		status  int
+	}{
+		{"两次密码不一致", testPassword, "different", http.StatusBadRequest},
+		{"当前密码错误", "wrong", "newpassword", http.StatusForbidden},
+		{"成功", testPassword, "newpassword", http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"current_password": {tt.current}, "password": {"newpassword"}, "password_confirm": {tt.confirm}}
+			if rec := web.post("/settings/password", form); rec.Code != tt.status {
+				t.Fatalf("状态码为%d，期望%d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	// 当前浏览器保持登录，其他浏览器和接口会话失效
+	body := expectPage(t, web.get("/settings?done=password"), http.StatusOK)
+	if !strings.Contains(body, settingsNotices["password"]) {
+		t.Fatal("设置页缺少修改成功的提示")
+	}
+	if rec := other.get("/settings"); rec.Code != http.StatusSeeOther {
+		t.Fatalf("其他浏览器的会话返回%d", rec.Code)
+	}
+	if w, _ := app.refresh(api.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("接口的刷新令牌返回%d", w.Code)
+	}
+}
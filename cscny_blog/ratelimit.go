@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 限流 ===
+// 令牌桶算法：每个键的桶最多容纳Limit个令牌，每Period补满，每个请求消耗一个令牌。
+// 已登录的请求按用户ID计数（需放在authMiddleware之后），其余按客户端IP计数；
+// 不同策略的计数互不影响。计数保存在RateLimitStore中，多实例部署时可换成共享存储。
+
+// rateLimitEnabled 是否启用限流（由配置加载）
+var rateLimitEnabled = true
+
+// 各类接口的限流策略（由配置加载）
+var (
+	authRateLimit     = rateLimitPolicy{Name: "auth", Limit: 10, Period: time.Minute}    // 登录、刷新令牌
+	registerRateLimit = rateLimitPolicy{Name: "register", Limit: 5, Period: time.Hour}   // 注册
+	commentRateLimit  = rateLimitPolicy{Name: "comment", Limit: 10, Period: time.Minute} // 发表评论
+	writeRateLimit    = rateLimitPolicy{Name: "write", Limit: 60, Period: time.Minute}   // 其他写操作
+)
+
+// rateLimitPolicy 限流策略
+type rateLimitPolicy struct {
+	Name   string        // 计数键的前缀
+	Limit  int           // 桶容量，即允许的突发请求数
+	Period time.Duration // 桶从空到满的时间
+}
+
+// rate 每秒补充的令牌数
+func (p rateLimitPolicy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// rateLimitResult 一次取令牌的结果
+type rateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时，距下一个令牌可用的时间
+	Reset      time.Duration // 距桶补满的时间
+}
+
+// RateLimitStore 限流计数存储
+type RateLimitStore interface {
+	Take(key string, policy rateLimitPolicy, now time.Time) (rateLimitResult, error)
+}
+
+// tokenBucket 单个键的令牌桶
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // 桶补满的时间，之后可以丢弃
+}
+
+// memoryRateLimitStore 进程内的限流存储（并发安全）
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(key string, policy rateLimitPolicy, now time.Time) (rateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := float64(policy.Limit)
+	rate := policy.rate()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit, updated: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(limit, b.tokens+elapsed*rate)
+		b.updated = now
+	}
+
+	result := rateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsDuration((limit - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// prune 丢弃已补满的桶（与新建的桶等价）
+func (s *memoryRateLimitStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// pruneLoop 定期清理，随服务进程一直运行
+func (s *memoryRateLimitStore) pruneLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.prune(now)
+	}
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds 向上取整的秒数，用于响应头
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// rateLimitKey 已登录用户按用户ID，否则按客户端IP
+func rateLimitKey(c *gin.Context, policy rateLimitPolicy) string {
+	if userID := currentUserID(c); userID != 0 {
+		return policy.Name + ":user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return policy.Name + ":ip:" + c.ClientIP()
+}
+
+// takeRateLimit 取令牌并写入X-RateLimit-*响应头；存储出错时放行，避免限流故障导致整站不可用
+func takeRateLimit(c *gin.Context, store RateLimitStore, policy rateLimitPolicy) bool {
+	result, err := store.Take(rateLimitKey(c, policy), policy, time.Now())
+	if err != nil {
+		logger.Printf("限流计数失败: policy=%s err=%v", policy.Name, err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", ceilSeconds(result.Reset))
+	if !result.Allowed {
+		c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+	}
+	return result.Allowed
+}
+
+// === 限流中间件 ===
+// 接口使用，超出限制时返回429 JSON
+func rateLimit(store RateLimitStore, policy rateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rateLimitEnabled || takeRateLimit(c, store, policy) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+		c.Abort()
+	}
+}
+
+// 页面使用，超出限制时渲染错误页
+func webRateLimit(store RateLimitStore, policy rateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rateLimitEnabled || takeRateLimit(c, store, policy) {
+			c.Next()
+			return
+		}
+		renderError(c, http.StatusTooManyRequests, "操作过于频繁，请稍后再试")
+		c.Abort()
+	}
+}
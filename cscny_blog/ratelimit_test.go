@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingRateLimitStore 总是出错的限流存储
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(string, rateLimitPolicy, time.Time) (rateLimitResult, error) {
+	return rateLimitResult{}, errors.New("存储不可用")
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	policy := rateLimitPolicy{Name: "test", Limit: 3, Period: 3 * time.Second} // 每秒补充1个
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemoryRateLimitStore()
+
+	steps := []struct {
+		name       string
+		key        string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{"第一次", "a", 0, true, 2, 0, time.Second},
+		{"突发", "a", 0, true, 1, 0, 2 * time.Second},
+		{"用完", "a", 0, true, 0, 0, 3 * time.Second},
+		{"超出限制", "a", 0, false, 0, time.Second, 3 * time.Second},
+		{"补充了半个", "a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond, 2500 * time.Millisecond},
+		{"补充了一个", "a", time.Second, true, 0, 0, 3 * time.Second},
+		{"其他键互不影响", "b", time.Second, true, 2, 0, time.Second},
+		{"最多补满", "a", time.Hour, true, 2, 0, time.Second},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			got, err := store.Take(step.key, policy, start.Add(step.at))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := rateLimitResult{Allowed: step.allowed, Remaining: step.remaining, RetryAfter: step.retryAfter, Reset: step.reset}
+			if got != want {
+				t.Fatalf("结果为%+v，期望%+v", got, want)
+			}
+		})
+	}
+
+	// 补满的桶被清理，未补满的保留
+	store.prune(start.Add(time.Hour + 500*time.Millisecond))
+	if _, ok := store.buckets["a"]; !ok {
+		t.Fatal("未补满的桶被清理")
+	}
+	if _, ok := store.buckets["b"]; ok {
+		t.Fatal("已补满的桶未被清理")
+	}
+}
+
+func TestCeilSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+	for _, tt := range tests {
+		if got := ceilSeconds(tt.d); got != tt.want {
+			t.Fatalf("ceilSeconds(%v) = %s，期望%s", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestTakeRateLimitStoreError(t *testing.T) {
+	c := testQueryContext("")
+	if !takeRateLimit(c, failingRateLimitStore{}, authRateLimit) {
+		t.Fatal("存储出错时未放行")
+	}
+	if h := c.Writer.Header().Get("X-RateLimit-Limit"); h != "" {
+		t.Fatalf("存储出错时写入了限流响应头: %s", h)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Auth = RateLimitRule{Limit: 2, Period: Duration(time.Minute)}
+		cfg.RateLimit.Register = RateLimitRule{Limit: 3, Period: Duration(time.Hour)}
+		cfg.RateLimit.Comment = RateLimitRule{Limit: 2, Period: Duration(time.Minute)}
+	})
+	for _, name := range []string{"alice", "bob", "carol"} {
+		app.register(name)
+	}
+	alice := app.login("alice").AccessToken
+	bob := app.login("bob").AccessToken
+	post := app.createPost(alice, nil)
+	commentsPath := fmt.Sprintf("/api/protected/posts/%d/comments", post.ID)
+	comment := gin.H{"content": "评论"}
+	credentials := gin.H{"username": "carol", "password": testPassword}
+
+	// 登录和注册按IP计数，评论按用户计数
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		token      string
+		status     int
+		limit      string
+		remaining  string
+		retryAfter string
+	}{
+		{"注册超出限制", http.MethodPost, "/api/public/auth/register", gin.H{"username": "dave", "email": "dave@example.com", "password": testPassword}, "", http.StatusTooManyRequests, "3", "0", "1200"},
+		{"登录超出限制", http.MethodPost, "/api/public/auth/login", credentials, "", http.StatusTooManyRequests, "2", "0", "30"},
+		{"第一条评论", http.MethodPost, commentsPath, comment, alice, http.StatusCreated, "2", "1", ""},
+		{"第二条评论", http.MethodPost, commentsPath, comment, alice, http.StatusCreated, "2", "0", ""},
+		{"评论超出限制", http.MethodPost, commentsPath, comment, alice, http.StatusTooManyRequests, "2", "0", "30"},
+		{"其他用户不受影响", http.MethodPost, commentsPath, comment, bob, http.StatusCreated, "2", "1", ""},
+		{"不限流的接口", http.MethodGet, "/api/public/posts", nil, alice, http.StatusOK, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.request(tt.method, tt.path, tt.body, tt.token)
+			expect(t, w, tt.status, nil)
+			h := w.Header()
+			if h.Get("X-RateLimit-Limit") != tt.limit || h.Get("X-RateLimit-Remaining") != tt.remaining || h.Get("Retry-After") != tt.retryAfter {
+				t.Fatalf("响应头为%v", h)
+			}
+		})
+	}
+
+	// 页面与接口共用计数，超出时渲染错误页
+	web := app.newWebClient()
+	body := expectPage(t, web.post("/login", url.Values{"username": {"carol"}, "password": {testPassword}}), http.StatusTooManyRequests)
+	if web.cookies[sessionCookieName] != "" || body == "" {
+		t.Fatal("超出限制后仍然登录成功")
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	app := newTestApp(t, func(cfg *Config) {
+		cfg.RateLimit.Auth = RateLimitRule{Limit: 1, Period: Duration(time.Minute)}
+	})
+	app.register("alice")
+	for i := 0; i < 3; i++ {
+		w := app.request(http.MethodPost, "/api/public/auth/login", gin.H{"username": "alice", "password": testPassword}, "")
+		expect(t, w, http.StatusOK, nil)
+		if h := w.Header().Get("X-RateLimit-Limit"); h != "" {
+			t.Fatalf("关闭限流时写入了响应头: %s", h)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === 角色与权限 ===
+const (
+	roleAdmin     = "admin"     // 管理员：拥有全部权限
+	roleModerator = "moderator" // 版主：可管理任意文章和评论
+)
+
+const (
+	permPostEditAny      = "post:edit_any"      // 编辑任意文章
+	permPostDeleteAny    = "post:delete_any"    // 删除任意文章
+	permCommentEditAny   = "comment:edit_any"   // 编辑任意评论
+	permCommentDeleteAny = "comment:delete_any" // 删除任意评论
+	permRoleManage       = "role:manage"        // 授予/撤销角色
+)
+
+// rolePermissions 角色到权限的映射（普通用户没有额外权限）
+var rolePermissions = map[string][]string{
+	roleAdmin: {
+		permPostEditAny, permPostDeleteAny,
+		permCommentEditAny, permCommentDeleteAny,
+		permRoleManage,
+	},
+	roleModerator: {
+		permPostEditAny, permPostDeleteAny,
+		permCommentEditAny, permCommentDeleteAny,
+	},
+}
+
+// UserRole 用户角色关联
+type UserRole struct {
+	ID     uint   `gorm:"primarykey" json:"-"`
+	UserID uint   `gorm:"uniqueIndex:idx_user_role;not null" json:"-"`                     // 用户ID
+	Role   string `gorm:"type:varchar(20);uniqueIndex:idx_user_role;not null" json:"role"` // 角色名
+}
+
+// permissionsFor 计算一组角色拥有的权限（去重、排序）
+func permissionsFor(roles []string) []string {
+	set := make(map[string]struct{})
+	for _, role := range roles {
+		for _, perm := range rolePermissions[role] {
+			set[perm] = struct{}{}
+		}
+	}
+	perms := make([]string, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// loadUserRoles 查询用户的角色名列表
+func loadUserRoles(db *gorm.DB, userID uint) ([]string, error) {
+	var roles []string
+	if err := db.Model(&UserRole{}).Where("user_id = ?", userID).Order("role").Pluck("role", &roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// hasPermission 判断当前请求的用户是否拥有指定权限
+func hasPermission(c *gin.Context, perm string) bool {
+	perms, _ := c.Get("permissions")
+	list, _ := perms.([]string)
+	for _, p := range list {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// === 权限中间件（需放在authMiddleware之后） ===
+func requirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !hasPermission(c, perm) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// ensureAdmin 启动时为指定用户名授予管理员角色（用于初始化第一个管理员）
+func ensureAdmin(users UserRepository, username string) error {
+	user, err := users.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	return users.GrantRole(user.ID, roleAdmin)
+}
+
+// === 管理员Handler ===
+// 查询用户角色（需role:manage权限）
+func listUserRolesHandler(users UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, err := users.FindByID(userID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+			return
+		}
+
+		roles, err := users.Roles(user.ID)
+		if err != nil {
+			logger.Printf("查询用户角色失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{
+			"user_id":     user.ID,
+			"username":    user.Username,
+			"roles":       roles,
+			"permissions": permissionsFor(roles),
+		}})
+	}
+}
+
+// 授予角色（需role:manage权限）
+func grantRoleHandler(users UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var input struct {
+			Role string `json:"role" binding:"required,oneof=admin moderator"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := users.FindByID(userID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+			logger.Printf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "授予角色失败"})
+			return
+		}
+
+		if err := users.GrantRole(userID, input.Role); err != nil {
+			logger.Printf("授予角色失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "授予角色失败"})
+			return
+		}
+
+		operatorId, _ := c.Get("userId")
+		logger.Printf("用户%d 为用户%d 授予角色 %s", operatorId, userID, input.Role)
+		c.JSON(http.StatusOK, gin.H{"message": "角色授予成功"})
+	}
+}
+
+// 撤销角色（需role:manage权限）；撤销后吊销该用户现有令牌，使新权限立即生效
+func revokeRoleHandler(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		role := c.Param("role")
+
+		revoked, err := users.RevokeRole(userID, role)
+		if err != nil {
+			if errors.Is(err, ErrLastAdmin) {
+				c.JSON(http.StatusConflict, gin.H{"error": "不能撤销最后一个管理员"})
+				return
+			}
+			logger.Printf("撤销角色失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销角色失败"})
+			return
+		}
+		if !revoked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "该用户没有此角色"})
+			return
+		}
+
+		// 令牌未能吊销时旧令牌仍带有原角色，不能报告成功
+		if err := tokens.revokeUser(userID); err != nil {
+			logger.Printf("吊销用户令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "角色已撤销，但吊销用户现有令牌失败"})
+			return
+		}
+
+		operatorId, _ := c.Get("userId")
+		logger.Printf("用户%d 撤销了用户%d 的角色 %s", operatorId, userID, role)
+		c.JSON(http.StatusOK, gin.H{"message": "角色撤销成功"})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestPermissionsFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		want  []string
+	}{
+		{"普通用户", nil, []string{}},
+		{"未知角色", []string{"guest"}, []string{}},
+		{"版主", []string{roleModerator}, []string{permCommentDeleteAny, permCommentEditAny, permPostDeleteAny, permPostEditAny}},
+		{"管理员兼版主去重", []string{roleAdmin, roleModerator}, []string{permCommentDeleteAny, permCommentEditAny, permPostDeleteAny, permPostEditAny, permRoleManage}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permissionsFor(tt.roles); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("权限为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesRequireRoleManage(t *testing.T) {
+	app := newTestApp(t)
+	targetID := app.register("target")
+
+	tests := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"普通用户", nil, http.StatusForbidden},
+		{"版主", []string{roleModerator}, http.StatusForbidden},
+		{"管理员", []string{roleAdmin}, http.StatusOK},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token := app.newUser(fmt.Sprintf("user%d", i), tt.roles...)
+			w := app.request(http.MethodGet, fmt.Sprintf("/api/admin/users/%d/roles", targetID), nil, token)
+			expect(t, w, tt.status, nil)
+		})
+	}
+}
+
+func TestEditAnyPostPermission(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+
+	tests := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"普通用户", nil, http.StatusForbidden},
+		{"版主", []string{roleModerator}, http.StatusOK},
+		{"管理员", []string{roleAdmin}, http.StatusOK},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := app.createPost(author, nil)
+			_, token := app.newUser(fmt.Sprintf("editor%d", i), tt.roles...)
+			path := fmt.Sprintf("/api/protected/posts/%d", post.ID)
+			expect(t, app.request(http.MethodPut, path, gin.H{"title": "版主修改", "content": "版主修改后的文章内容。"}, token), tt.status, nil)
+			expect(t, app.request(http.MethodDelete, path, nil, token), tt.status, nil)
+		})
+	}
+}
+
+func TestRevokeRole(t *testing.T) {
+	app := newTestApp(t)
+	adminID, admin := app.newUser("admin", roleAdmin)
+	modID, moderator := app.newUser("moderator", roleModerator)
+
+	// 不能撤销最后一个管理员
+	w := app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", adminID, roleAdmin), nil, admin)
+	expect(t, w, http.StatusConflict, nil)
+
+	w = app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", modID, roleModerator), nil, admin)
+	expect(t, w, http.StatusOK, nil)
+	// 撤销后原令牌立即失效
+	if app.authenticated(moderator) {
+		t.Fatal("撤销角色后原令牌仍然有效")
+	}
+
+	w = app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", modID, roleModerator), nil, admin)
+	expect(t, w, http.StatusNotFound, nil)
+
+	// 还有其他管理员时可以撤销，撤销后剩下的管理员成为最后一个
+	secondID, _ := app.newUser("second", roleAdmin)
+	w = app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", secondID, roleAdmin), nil, admin)
+	expect(t, w, http.StatusOK, nil)
+	w = app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", adminID, roleAdmin), nil, admin)
+	expect(t, w, http.StatusConflict, nil)
+	if roles, err := app.repos.Users.Roles(adminID); err != nil || len(roles) != 1 {
+		t.Fatalf("最后一个管理员的角色为%v（%v）", roles, err)
+	}
+}
+
+func TestRevokeRoleTokenFailure(t *testing.T) {
+	app := newTestApp(t)
+	_, admin := app.newUser("admin", roleAdmin)
+	modID, _ := app.newUser("moderator", roleModerator)
+
+	// 吊销令牌失败时不能报告撤销成功
+	if err := app.db.Migrator().DropTable(&WebSession{}); err != nil {
+		t.Fatal(err)
+	}
+	w := app.request(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/roles/%s", modID, roleModerator), nil, admin)
+	expect(t, w, http.StatusInternalServerError, nil)
+}
+
+func TestGrantRoleValidation(t *testing.T) {
+	app := newTestApp(t)
+	_, admin := app.newUser("admin", roleAdmin)
+	targetID := app.register("target")
+
+	tests := []struct {
+		name   string
+		userID uint
+		role   string
+		status int
+	}{
+		{"不支持的角色", targetID, "owner", http.StatusBadRequest},
+		{"用户不存在", 9999, roleModerator, http.StatusNotFound},
+		{"成功", targetID, roleModerator, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := app.request(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/roles", tt.userID), gin.H{"role": tt.role}, admin)
+			expect(t, w, tt.status, nil)
+		})
+	}
+}
+
+// grantRoles 创建用户并授予角色，返回用户ID
+func grantRoles(t *testing.T, db *gorm.DB, username string, roles ...string) uint {
+	t.Helper()
+	user := User{Username: username, Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range roles {
+		if err := db.Create(&UserRole{UserID: user.ID, Role: role}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user.ID
+}
+
+func TestRequirePermission(t *testing.T) {
+	db := newTestDB(t)
+	store := newTokenStore(db)
+	r := gin.New()
+	r.GET("/", authMiddleware(store), requirePermission(permPostEditAny, permRoleManage), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"普通用户", nil, http.StatusForbidden},
+		{"版主缺少部分权限", []string{roleModerator}, http.StatusForbidden},
+		{"管理员", []string{roleAdmin}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := store.issue(grantRoles(t, db, tt.name, tt.roles...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("状态码为%d，期望%d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	db := newTestDB(t)
+	store := newTokenStore(db)
+	userID := grantRoles(t, db, "moderator", roleModerator)
+	otherID := grantRoles(t, db, "other")
+
+	var pairs []*tokenPair
+	for _, id := range []uint{userID, userID, otherID} {
+		pair, err := store.issue(id, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := store.revokeUser(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 该用户所有会话的访问令牌都被吊销，其他用户不受影响
+	for i, want := range []bool{true, true, false} {
+		if revoked, err := store.isRevoked(accessJTI(t, pairs[i].AccessToken)); err != nil || revoked != want {
+			t.Fatalf("第%d个令牌revoked=%v err=%v，期望%v", i, revoked, err, want)
+		}
+	}
+	if _, err := store.rotate(pairs[0].RefreshToken); err == nil {
+		t.Fatal("吊销后仍可刷新")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 表情回应 ===
+// 用户可对文章和评论做出回应（点赞及少量表情），同一用户对同一对象的每种回应最多一个。
+// 计数不单独存储，查询时按对象分组聚合，因此并发切换也不会出现计数偏差。
+
+// 回应对象类型
+const (
+	reactionTargetPost    = "post"
+	reactionTargetComment = "comment"
+)
+
+// reactionKinds 支持的回应种类及对应表情
+var reactionKinds = map[string]string{
+	"like":     "👍",
+	"heart":    "❤️",
+	"laugh":    "😄",
+	"hooray":   "🎉",
+	"confused": "😕",
+	"eyes":     "👀",
+}
+
+// Reaction 回应记录
+type Reaction struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TargetType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reaction_unique,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_reaction_unique,priority:2" json:"target_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_reaction_unique,priority:3;index" json:"user_id"`
+	Kind       string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reaction_unique,priority:4" json:"kind"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReactionCounts 各种回应的数量（没有回应的种类不出现）
+type ReactionCounts map[string]int64
+
+// MarshalJSON 没有任何回应时输出{}而不是null，保持响应结构稳定
+func (r ReactionCounts) MarshalJSON() ([]byte, error) {
+	if r == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]int64(r))
+}
+
+// ReactionRepository 回应仓储
+type ReactionRepository interface {
+	Toggle(r *Reaction) (bool, error)                                                // 已存在则取消，否则添加；返回操作后是否处于已回应状态
+	Counts(targetType string, ids []uint) (map[uint]ReactionCounts, error)           // 一次查询多个对象的回应数
+	UserKinds(userID uint, targetType string, ids []uint) (map[uint][]string, error) // 用户对多个对象做出的回应
+}
+
+// attachPostReactions 为文章填充回应数和当前用户的回应（整批两次查询）
+func attachPostReactions(c *gin.Context, reactions ReactionRepository, posts []Post) error {
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	counts, mine, err := loadReactions(c, reactions, reactionTargetPost, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = counts[posts[i].ID]
+		posts[i].MyReactions = mine[posts[i].ID]
+	}
+	return nil
+}
+
+// attachPostDetailReactions 为文章详情及其全部评论填充回应（评论需尚未组装为树）
+func attachPostDetailReactions(c *gin.Context, reactions ReactionRepository, post *Post) error {
+	counts, mine, err := loadReactions(c, reactions, reactionTargetPost, []uint{post.ID})
+	if err != nil {
+		return err
+	}
+	post.Reactions, post.MyReactions = counts[post.ID], mine[post.ID]
+	return attachCommentReactions(c, reactions, post.Comments)
+}
+
+// attachCommentReactions 为扁平评论列表填充回应数和当前用户的回应（需在组装评论树之前调用）
+func attachCommentReactions(c *gin.Context, reactions ReactionRepository, comments []Comment) error {
+	ids := make([]uint, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	counts, mine, err := loadReactions(c, reactions, reactionTargetComment, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].ID]
+		comments[i].MyReactions = mine[comments[i].ID]
+	}
+	return nil
+}
+
+// loadReactions 查询回应数，登录用户另外查询自己的回应
+func loadReactions(c *gin.Context, reactions ReactionRepository, targetType string, ids []uint) (map[uint]ReactionCounts, map[uint][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	counts, err := reactions.Counts(targetType, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mine map[uint][]string
+	if userId, ok := c.Get("userId"); ok {
+		if mine, err = reactions.UserKinds(userId.(uint), targetType, ids); err != nil {
+			return nil, nil, err
+		}
+	}
+	return counts, mine, nil
+}
+
+// === 回应Handler ===
+// 切换对文章的回应（只能回应已发布的文章）
+func togglePostReactionHandler(posts PostRepository, reactions ReactionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		post, err := posts.FindByID(postID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+				return
+			}
+			logger.Printf("查询文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "回应失败"})
+			return
+		}
+		if !canViewPost(c, post) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			return
+		}
+		if post.Status != postStatusPublished {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "文章未发布，不能回应"})
+			return
+		}
+
+		toggleReaction(c, reactions, reactionTargetPost, post.ID)
+	}
+}
+
+// 切换对评论的回应（已删除的评论及未发布文章下的评论不能回应）
+func toggleCommentReactionHandler(posts PostRepository, comments CommentRepository, reactions ReactionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		commentID, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		comment, err := comments.FindByID(commentID)
+		var post *Post
+		if err == nil {
+			if post, err = posts.FindByID(comment.PostID); err == nil && !canViewPost(c, post) {
+				err = ErrNotFound
+			}
+		}
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
+				return
+			}
+			logger.Printf("查询评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "回应失败"})
+			return
+		}
+		if post.Status != postStatusPublished {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "文章未发布，不能回应"})
+			return
+		}
+
+		toggleReaction(c, reactions, reactionTargetComment, comment.ID)
+	}
+}
+
+// toggleReaction 校验回应种类并切换，返回切换后的状态和最新计数
+func toggleReaction(c *gin.Context, reactions ReactionRepository, targetType string, targetID uint) {
+	userId, _ := c.Get("userId")
+	kind := c.Param("kind")
+	if _, ok := reactionKinds[kind]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的回应: " + kind})
+		return
+	}
+
+	reacted, err := reactions.Toggle(&Reaction{TargetType: targetType, TargetID: targetID, UserID: userId.(uint), Kind: kind})
+	if err != nil {
+		logger.Printf("切换回应失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "回应失败"})
+		return
+	}
+	counts, err := reactions.Counts(targetType, []uint{targetID})
+	if err != nil {
+		logger.Printf("查询回应数失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询回应数失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"kind": kind, "reacted": reacted, "reactions": counts[targetID]}})
+}
+
+// 支持的回应种类（无需认证）
+func listReactionKindsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": reactionKinds})
+	}
+}
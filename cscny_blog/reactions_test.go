@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toggleResponse 切换回应接口的响应
+type toggleResponse struct {
+	Data struct {
+		Kind      string         `json:"kind"`
+		Reacted   bool           `json:"reacted"`
+		Reactions ReactionCounts `json:"reactions"`
+	} `json:"data"`
+}
+
+func TestReactionCountsJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts ReactionCounts
+		want   string
+	}{
+		{"nil输出空对象", nil, "{}"},
+		{"空map", ReactionCounts{}, "{}"},
+		{"有回应", ReactionCounts{"like": 2}, `{"like":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(struct {
+				Reactions ReactionCounts `json:"reactions"`
+			}{tt.counts})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `{"reactions":` + tt.want + `}`; string(body) != want {
+				t.Fatalf("输出为%s，期望%s", body, want)
+			}
+		})
+	}
+}
+
+func TestTogglePostReaction(t *testing.T) {
+	app := newTestApp(t)
+	_, alice := app.newUser("alice")
+	_, bob := app.newUser("bob")
+	post := app.createPost(alice, nil)
+	path := fmt.Sprintf("/api/protected/posts/%d/reactions/", post.ID)
+
+	steps := []struct {
+		name    string
+		token   string
+		kind    string
+		reacted bool
+		counts  ReactionCounts
+	}{
+		{"点赞", alice, "like", true, ReactionCounts{"like": 1}},
+		{"其他用户点赞", bob, "like", true, ReactionCounts{"like": 2}},
+		{"另一种回应", bob, "heart", true, ReactionCounts{"like": 2, "heart": 1}},
+		{"再次点击取消", alice, "like", false, ReactionCounts{"like": 1, "heart": 1}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			var resp toggleResponse
+			expect(t, app.request(http.MethodPost, path+step.kind, nil, step.token), http.StatusOK, &resp)
+			if resp.Data.Reacted != step.reacted || fmt.Sprint(resp.Data.Reactions) != fmt.Sprint(step.counts) {
+				t.Fatalf("回应结果为%+v", resp.Data)
+			}
+		})
+	}
+
+	// 详情和列表中带回应数及当前用户的回应
+	var detail postResponse
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d", post.ID), nil, bob), http.StatusOK, &detail)
+	sort.Strings(detail.Data.MyReactions)
+	if detail.Data.Reactions["like"] != 1 || strings.Join(detail.Data.MyReactions, ",") != "heart,like" {
+		t.Fatalf("回应为%v，我的回应为%v", detail.Data.Reactions, detail.Data.MyReactions)
+	}
+	var list listResponse[Post]
+	expect(t, app.request(http.MethodGet, "/api/public/posts", nil, ""), http.StatusOK, &list)
+	if list.Data[0].Reactions["heart"] != 1 || len(list.Data[0].MyReactions) != 0 {
+		t.Fatalf("列表中的回应为%v，我的回应为%v", list.Data[0].Reactions, list.Data[0].MyReactions)
+	}
+
+	// 取消全部回应后仍输出空对象
+	expect(t, app.request(http.MethodPost, path+"like", nil, bob), http.StatusOK, nil)
+	w := app.request(http.MethodPost, path+"heart", nil, bob)
+	expect(t, w, http.StatusOK, nil)
+	if !strings.Contains(w.Body.String(), `"reactions":{}`) {
+		t.Fatalf("响应为%s", w.Body.String())
+	}
+}
+
+func TestToggleReactionValidation(t *testing.T) {
+	app := newTestApp(t)
+	_, author := app.newUser("author")
+	_, stranger := app.newUser("stranger")
+	post := app.createPost(author, nil)
+	comment := app.createComment(author, post.ID, 0, "评论")
+	draft := app.createPost(author, gin.H{"status": postStatusDraft})
+	archived := app.createPost(author, nil)
+	archivedComment := app.createComment(author, archived.ID, 0, "归档文章下的评论")
+	expect(t, app.request(http.MethodPut, fmt.Sprintf("/api/protected/posts/%d", archived.ID), gin.H{"status": postStatusArchived}, author), http.StatusOK, nil)
+	deleted := app.createComment(author, post.ID, 0, "已删除的评论")
+	expect(t, app.request(http.MethodDelete, fmt.Sprintf("/api/protected/comments/%d", deleted.ID), nil, author), http.StatusOK, nil)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"不支持的回应", fmt.Sprintf("/api/protected/posts/%d/reactions/angry", post.ID), author, http.StatusBadRequest},
+		{"文章不存在", "/api/protected/posts/9999/reactions/like", author, http.StatusNotFound},
+		{"他人的草稿", fmt.Sprintf("/api/protected/posts/%d/reactions/like", draft.ID), stranger, http.StatusNotFound},
+		{"自己的草稿", fmt.Sprintf("/api/protected/posts/%d/reactions/like", draft.ID), author, http.StatusBadRequest},
+		{"评论", fmt.Sprintf("/api/protected/comments/%d/reactions/like", comment.ID), stranger, http.StatusOK},
+		{"已删除的评论", fmt.Sprintf("/api/protected/comments/%d/reactions/like", deleted.ID), stranger, http.StatusNotFound},
+		{"他人归档文章下的评论", fmt.Sprintf("/api/protected/comments/%d/reactions/like", archivedComment.ID), stranger, http.StatusNotFound},
+		{"自己归档文章下的评论", fmt.Sprintf("/api/protected/comments/%d/reactions/like", archivedComment.ID), author, http.StatusBadRequest},
+		{"未登录", fmt.Sprintf("/api/protected/posts/%d/reactions/like", post.ID), "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, app.request(http.MethodPost, tt.path, nil, tt.token), tt.status, nil)
+		})
+	}
+
+	// 评论列表中的回应数
+	var comments listResponse[Comment]
+	expect(t, app.request(http.MethodGet, fmt.Sprintf("/api/public/posts/%d/comments", post.ID), nil, ""), http.StatusOK, &comments)
+	if len(comments.Data) != 1 || comments.Data[0].Reactions["like"] != 1 {
+		t.Fatalf("评论列表为%+v", comments.Data)
+	}
+}
+
+func TestReactionToggleConcurrent(t *testing.T) {
+	app := newTestApp(t)
+	userID, token := app.newUser("alice")
+	post := app.createPost(token, nil)
+
+	// 同一用户并发切换时，同种回应最多只有一条
+	const toggles = 10
+	var wg sync.WaitGroup
+	for i := 0; i < toggles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := app.repos.Reactions.Toggle(&Reaction{TargetType: reactionTargetPost, TargetID: post.ID, UserID: userID, Kind: "like"}); err != nil {
+				t.Errorf("切换回应失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts, err := app.repos.Reactions.Counts(reactionTargetPost, []uint{post.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := counts[post.ID]["like"]; n > 1 {
+		t.Fatalf("回应数为%d", n)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 仓储接口 ===
+// Handler只依赖以下接口，不直接接触数据库连接
+
+// ErrNotFound 记录不存在（各仓储实现统一返回此错误）
+var ErrNotFound = errors.New("记录不存在")
+
+// ErrLastAdmin 撤销的是最后一个管理员角色
+var ErrLastAdmin = errors.New("不能撤销最后一个管理员")
+
+// PostRepository 文章仓储
+type PostRepository interface {
+	Create(post *Post) error                                 // 由标题生成唯一slug
+	FindByID(id uint) (*Post, error)                         // 仅文章本身
+	FindBySlug(slug string) (*Post, error)                   // 仅文章本身；slug为改名前的旧slug时同样返回文章
+	FindWithAuthor(id uint) (*Post, error)                   // 含作者信息
+	FindWithComments(id uint) (*Post, error)                 // 含作者、评论及评论者信息
+	List(q PostQuery) ([]Post, *Pagination, error)           // 按条件分页查询（含作者信息和评论数）
+	Update(post *Post) error                                 // 标题变化时重新生成slug，旧slug保留为跳转
+	SetTags(post *Post, tags []Tag) error                    // 替换文章标签（不存在的标签自动创建，未使用的标签自动清理）
+	Delete(post *Post) error                                 // 连同文章下的评论、附件记录一起删除
+	ForEach(fn func(batch []Post) error) error               // 分批遍历全部文章（重建索引等场景）
+	PublishDue(now time.Time) ([]Post, error)                // 将到期的定时文章改为已发布，返回被发布的文章
+	CountByAuthor(userID uint, status string) (int64, error) // 作者的文章数（status为空时统计全部状态）
+}
+
+// CommentRepository 评论仓储
+type CommentRepository interface {
+	Create(comment *Comment) error
+	FindByID(id uint) (*Comment, error)
+	FindWithAuthor(id uint) (*Comment, error)
+	ListByPost(q CommentQuery) ([]Comment, *Pagination, error) // 分页的根评论及其全部回复（含占位用的已删除评论），按楼层和path排序
+	Update(comment *Comment) error
+	Delete(comment *Comment, deletedBy uint) error           // 软删除并记录操作人
+	ListDeleted(q PageQuery) ([]Comment, *Pagination, error) // 已删除评论（按删除时间倒序，供版主审核；不含已删除文章下的评论）
+	Restore(id uint) (*Comment, error)                       // 恢复已删除的评论（所属文章已删除时返回ErrNotFound）
+	ForEach(fn func(batch []Comment) error) error            // 分批遍历全部评论
+}
+
+// UserRepository 用户仓储（含角色管理）
+type UserRepository interface {
+	Create(user *User) error
+	FindByID(id uint) (*User, error)
+	FindByUsername(username string) (*User, error)
+	FindByUsernames(usernames []string) ([]User, error) // 不存在的用户名忽略
+	FindByEmail(email string) (*User, error)
+	SetEmail(userID uint, email string) error                  // 更换邮箱并重置为未验证
+	MarkEmailVerified(userID uint, email string) (bool, error) // 邮箱仍为email时标记为已验证
+	UpdatePassword(userID uint, hash string) error
+	UpdateProfile(userID uint, bio string) error
+	SetAvatar(userID uint, key string) error // key为空表示移除头像
+	Roles(userID uint) ([]string, error)
+	GrantRole(userID uint, role string) error
+	RevokeRole(userID uint, role string) (bool, error) // 返回是否确实撤销了角色；撤销后不再有管理员时返回ErrLastAdmin且不做改动
+}
+
+// Repositories 汇总所有仓储，便于在路由间传递
+type Repositories struct {
+	Posts         PostRepository
+	Comments      CommentRepository
+	Users         UserRepository
+	Tags          TagRepository
+	Revisions     RevisionRepository
+	Reactions     ReactionRepository
+	Follows       FollowRepository
+	Notifications NotificationRepository
+	Attachments   AttachmentRepository
+}
+
+// paramID 解析URL中的数字ID参数
+func paramID(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("无效的ID")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,930 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// === GORM仓储实现（MySQL与SQLite共用） ===
+
+func newGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Posts:         &gormPostRepository{db: db},
+		Comments:      &gormCommentRepository{db: db},
+		Users:         &gormUserRepository{db: db},
+		Tags:          &gormTagRepository{db: db},
+		Revisions:     &gormRevisionRepository{db: db},
+		Reactions:     &gormReactionRepository{db: db},
+		Follows:       &gormFollowRepository{db: db},
+		Notifications: &gormNotificationRepository{db: db},
+		Attachments:   &gormAttachmentRepository{db: db},
+	}
+}
+
+// translateError 将GORM的错误转换为仓储层错误
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// selectAuthor 预加载作者时只查询ID和用户名，避免敏感信息
+func selectAuthor(db *gorm.DB) *gorm.DB {
+	return db.Select("ID", "Username", "AvatarKey")
+}
+
+// commentCountExpr 文章评论数子查询（列表排序与返回comment_count共用）
+const commentCountExpr = "(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL)"
+
+// withCommentCount 查询文章时附带评论数
+func withCommentCount(db *gorm.DB) *gorm.DB {
+	return db.Select("posts.*, " + commentCountExpr + " AS comment_count")
+}
+
+// keyset 按排序表达式和ID做游标分页；多取一条用于判断是否还有下一页
+func keyset(db *gorm.DB, expr, idColumn string, q PageQuery) (*gorm.DB, error) {
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.Cursor != nil {
+		var value interface{}
+		if q.Sort == sortCommentCount {
+			n, err := strconv.ParseInt(q.Cursor.Value, 10, 64)
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			value = n
+		} else {
+			t, err := time.Parse(time.RFC3339Nano, q.Cursor.Value)
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			value = t.Local() // 与写入时的时区一致，保证SQLite按字符串比较时正确
+		}
+		db = db.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", expr, cmp, expr, idColumn, cmp),
+			value, value, q.Cursor.ID)
+	}
+	return db.Order(expr + " " + dir).Order(idColumn + " " + dir).Limit(q.Limit + 1), nil
+}
+
+// escapeLike 转义LIKE中的通配符（配合ESCAPE '!'使用）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// --- 文章 ---
+type gormPostRepository struct {
+	db *gorm.DB
+}
+
+// isDuplicateKey 判断是否违反唯一约束
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// maxSlugAttempts 并发创建同名文章时分配slug的重试次数（修订编号冲突同样适用）
+const maxSlugAttempts = 3
+
+// uniquePostSlug 在base基础上分配未被占用的slug（base、base-2、base-3……）
+// 已删除的文章及其他文章的旧slug均视为占用；文章自己的旧slug可以重新使用
+func uniquePostSlug(tx *gorm.DB, base string, postID uint) (string, error) {
+	pattern := escapeLike(base+slugSeparator) + "%"
+	var taken, redirected []string
+	if err := tx.Unscoped().Model(&Post{}).
+		Where("id <> ? AND (slug = ? OR slug LIKE ? ESCAPE '!')", postID, base, pattern).
+		Pluck("slug", &taken).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Model(&PostSlugRedirect{}).
+		Where("post_id <> ? AND (slug = ? OR slug LIKE ? ESCAPE '!')", postID, base, pattern).
+		Pluck("slug", &redirected).Error; err != nil {
+		return "", err
+	}
+
+	used := make(map[string]struct{}, len(taken)+len(redirected))
+	for _, slug := range append(taken, redirected...) {
+		used[slug] = struct{}{}
+	}
+	slug := base
+	for n := 2; ; n++ {
+		if _, ok := used[slug]; !ok {
+			return slug, nil
+		}
+		slug = base + slugSeparator + strconv.Itoa(n)
+	}
+}
+
+// Create 创建文章并分配唯一slug
+func (r *gormPostRepository) Create(post *Post) error {
+	base := slugify(post.Title)
+	for attempt := 1; ; attempt++ {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			slug, err := uniquePostSlug(tx, base, 0)
+			if err != nil {
+				return err
+			}
+			post.Slug = slug
+			return tx.Create(post).Error
+		})
+		if err == nil || attempt == maxSlugAttempts || !isDuplicateKey(r.db, err) {
+			return err
+		}
+		post.ID = 0 // 同名文章并发创建，重新分配slug
+	}
+}
+
+func (r *gormPostRepository) FindByID(id uint) (*Post, error) {
+	var post Post
+	if err := r.db.First(&post, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindWithAuthor(id uint) (*Post, error) {
+	var post Post
+	if err := r.db.Scopes(withCommentCount).Preload("User", selectAuthor).Preload("Tags").First(&post, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindWithComments(id uint) (*Post, error) {
+	var post Post
+	if err := r.db.Scopes(withCommentCount).Preload("User", selectAuthor).Preload("Tags").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Order("comments.path") // 已删除的评论用于占位，由buildCommentTree处理
+		}).
+		Preload("Comments.User", selectAuthor).
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB { return db.Order("attachments.id") }).
+		First(&post, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) List(q PostQuery) ([]Post, *Pagination, error) {
+	db := r.db.Model(&Post{}).Scopes(withCommentCount).Preload("User", selectAuthor).Preload("Tags")
+	if q.TagID != 0 {
+		db = db.Where("posts.id IN (?)", r.db.Table("post_tags").Select("post_id").Where("tag_id = ?", q.TagID))
+	}
+	if q.AuthorID != 0 {
+		db = db.Where("posts.user_id = ?", q.AuthorID)
+	}
+	if q.Status != "" {
+		db = db.Where("posts.status = ?", q.Status)
+	}
+	if q.FollowerID != 0 {
+		db = db.Where("posts.user_id IN (?)", r.db.Model(&Follow{}).Select("followee_id").Where("follower_id = ?", q.FollowerID))
+	}
+	if q.AuthorName != "" {
+		db = db.Where("posts.user_id IN (?)", r.db.Model(&User{}).Select("id").Where("username = ?", q.AuthorName))
+	}
+	if q.CreatedFrom != nil {
+		db = db.Where("posts.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		db = db.Where("posts.created_at < ?", *q.CreatedTo)
+	}
+	if q.TitleContains != "" {
+		db = db.Where("posts.title LIKE ? ESCAPE '!'", "%"+escapeLike(q.TitleContains)+"%")
+	}
+
+	expr := "posts." + q.Sort
+	if q.Sort == sortCommentCount {
+		expr = commentCountExpr
+	}
+	db, err := keyset(db, expr, "posts.id", q.PageQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var posts []Post
+	if err := db.Find(&posts).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Pagination{Limit: q.Limit}
+	if len(posts) > q.Limit {
+		posts = posts[:q.Limit]
+		last := posts[len(posts)-1]
+		cursor := pageCursor{Sort: q.Sort, Desc: q.Desc, ID: last.ID}
+		switch q.Sort {
+		case sortUpdatedAt:
+			cursor.Value = timeCursorValue(last.UpdatedAt)
+		case sortCommentCount:
+			cursor.Value = countCursorValue(last.CommentCount)
+		case sortPublishedAt:
+			cursor.Value = timeCursorValue(publishedTime(&last))
+		default:
+			cursor.Value = timeCursorValue(last.CreatedAt)
+		}
+		page.HasMore = true
+		page.NextCursor = cursor.encode()
+	}
+	return posts, page, nil
+}
+
+func (r *gormPostRepository) FindBySlug(slug string) (*Post, error) {
+	var post Post
+	err := r.db.Where("slug = ?", slug).First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var redirect PostSlugRedirect
+		if err := r.db.Where("slug = ?", slug).First(&redirect).Error; err != nil {
+			return nil, translateError(err)
+		}
+		err = r.db.First(&post, redirect.PostID).Error
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+// Update 保存文章；标题变化导致slug变化时分配新slug，并把旧slug保存为跳转记录
+func (r *gormPostRepository) Update(post *Post) error {
+	base := slugify(post.Title)
+	if slugHasBase(post.Slug, base) {
+		return r.db.Save(post).Error
+	}
+	oldSlug := post.Slug
+	for attempt := 1; ; attempt++ {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			slug, err := uniquePostSlug(tx, base, post.ID)
+			if err != nil {
+				return err
+			}
+			// 改回曾经用过的标题时，对应的跳转记录不再需要
+			if err := tx.Where("slug = ?", slug).Delete(&PostSlugRedirect{}).Error; err != nil {
+				return err
+			}
+			if oldSlug != "" {
+				if err := tx.Create(&PostSlugRedirect{Slug: oldSlug, PostID: post.ID}).Error; err != nil {
+					return err
+				}
+			}
+			post.Slug = slug
+			return tx.Save(post).Error
+		})
+		if err == nil {
+			return nil
+		}
+		post.Slug = oldSlug
+		if attempt == maxSlugAttempts || !isDuplicateKey(r.db, err) {
+			return err
+		}
+	}
+}
+
+func (r *gormPostRepository) SetTags(post *Post, tags []Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		resolved := make([]Tag, 0, len(tags))
+		for _, tag := range tags {
+			if err := tx.Where(Tag{Slug: tag.Slug}).Attrs(Tag{Name: tag.Name}).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			resolved = append(resolved, tag)
+		}
+		if err := tx.Model(post).Association("Tags").Replace(resolved); err != nil {
+			return err
+		}
+		post.Tags = resolved
+		return deleteUnusedTags(tx)
+	})
+}
+
+func (r *gormPostRepository) Delete(post *Post) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return deletePost(tx, post)
+	})
+}
+
+// deletePost 在事务中删除文章及其关联数据（账号注销时与其他步骤共用同一事务）
+func deletePost(tx *gorm.DB, post *Post) error {
+	// 文章及其评论上的回应
+	if err := tx.Where("target_type = ? AND target_id = ?", reactionTargetPost, post.ID).
+		Or("target_type = ? AND target_id IN (?)", reactionTargetComment,
+			tx.Unscoped().Model(&Comment{}).Select("id").Where("post_id = ?", post.ID)).
+		Delete(&Reaction{}).Error; err != nil {
+		return err
+	}
+	// 级联删除评论（或在数据库设置外键级联删除）
+	if err := tx.Where("post_id = ?", post.ID).Delete(&Comment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", post.ID).Delete(&PostRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", post.ID).Delete(&PostSlugRedirect{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", post.ID).Delete(&Notification{}).Error; err != nil {
+		return err
+	}
+	// 附件文件由调用方在删除成功后清理
+	if err := tx.Where("post_id = ?", post.ID).Delete(&Attachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(post).Association("Tags").Clear(); err != nil {
+		return err
+	}
+	if err := tx.Delete(post).Error; err != nil {
+		return err
+	}
+	return deleteUnusedTags(tx)
+}
+
+// deleteUnusedTags 清理没有任何文章使用的标签
+func deleteUnusedTags(tx *gorm.DB) error {
+	return tx.Where("id NOT IN (?)", tx.Table("post_tags").Select("tag_id")).Delete(&Tag{}).Error
+}
+
+func (r *gormPostRepository) PublishDue(now time.Time) ([]Post, error) {
+	var due []Post
+	if err := r.db.Where("status = ? AND publish_at <= ?", postStatusScheduled, now).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	published := make([]Post, 0, len(due))
+	for _, post := range due {
+		publishedAt := *post.PublishAt
+		// 带状态条件更新，避免覆盖作者在此期间所做的状态修改
+		result := r.db.Model(&Post{}).Where("id = ? AND status = ?", post.ID, postStatusScheduled).
+			Updates(map[string]interface{}{"status": postStatusPublished, "published_at": publishedAt, "publish_at": nil})
+		if result.Error != nil {
+			return published, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		post.Status = postStatusPublished
+		post.PublishedAt = &publishedAt
+		post.PublishAt = nil
+		published = append(published, post)
+	}
+	return published, nil
+}
+
+func (r *gormPostRepository) CountByAuthor(userID uint, status string) (int64, error) {
+	db := r.db.Model(&Post{}).Where("user_id = ?", userID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var count int64
+	err := db.Count(&count).Error
+	return count, err
+}
+
+func (r *gormPostRepository) ForEach(fn func(batch []Post) error) error {
+	var batch []Post
+	return r.db.FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// --- 评论 ---
+type gormCommentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCommentRepository) Create(comment *Comment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		parentPath := ""
+		if comment.ParentID != nil {
+			var parent Comment
+			if err := tx.First(&parent, *comment.ParentID).Error; err != nil {
+				return translateError(err)
+			}
+			comment.Depth = parent.Depth + 1
+			comment.RootID = parent.RootID
+			parentPath = parent.Path
+		}
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+
+		// 路径和根评论ID依赖自身ID，插入后再补全
+		comment.Path = commentPath(parentPath, comment.ID)
+		if comment.ParentID == nil {
+			comment.RootID = comment.ID
+		}
+		return tx.Model(comment).Updates(map[string]interface{}{"path": comment.Path, "root_id": comment.RootID}).Error
+	})
+}
+
+func (r *gormCommentRepository) FindByID(id uint) (*Comment, error) {
+	var comment Comment
+	if err := r.db.First(&comment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) FindWithAuthor(id uint) (*Comment, error) {
+	var comment Comment
+	if err := r.db.Preload("User", selectAuthor).First(&comment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) ListByPost(q CommentQuery) ([]Comment, *Pagination, error) {
+	// 根评论：未删除的，或已删除但仍有存活回复的（作为占位）
+	db := r.db.Unscoped().Model(&Comment{}).
+		Where("comments.post_id = ? AND comments.parent_id IS NULL", q.PostID).
+		Where("comments.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments replies WHERE replies.root_id = comments.id AND replies.id <> comments.id AND replies.deleted_at IS NULL)")
+	db, err := keyset(db, "comments."+q.Sort, "comments.id", q.PageQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roots []Comment
+	if err := db.Preload("User", selectAuthor).Find(&roots).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Pagination{Limit: q.Limit}
+	if len(roots) > q.Limit {
+		roots = roots[:q.Limit]
+		last := roots[len(roots)-1]
+		page.HasMore = true
+		page.NextCursor = pageCursor{Sort: q.Sort, Desc: q.Desc, Value: timeCursorValue(last.CreatedAt), ID: last.ID}.encode()
+	}
+	if len(roots) == 0 {
+		return roots, page, nil
+	}
+
+	// 一次查询取出本页所有楼层的回复
+	rootIDs := make([]uint, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+	var replies []Comment
+	if err := r.db.Unscoped().Where("root_id IN ? AND parent_id IS NOT NULL", rootIDs).
+		Order("path").Preload("User", selectAuthor).Find(&replies).Error; err != nil {
+		return nil, nil, err
+	}
+
+	byRoot := make(map[uint][]Comment)
+	for _, reply := range replies {
+		byRoot[reply.RootID] = append(byRoot[reply.RootID], reply)
+	}
+	comments := make([]Comment, 0, len(roots)+len(replies))
+	for _, root := range roots {
+		comments = append(comments, root)
+		comments = append(comments, byRoot[root.ID]...)
+	}
+	return comments, page, nil
+}
+
+func (r *gormCommentRepository) Update(comment *Comment) error {
+	return r.db.Save(comment).Error
+}
+
+func (r *gormCommentRepository) Delete(comment *Comment, deletedBy uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(comment).Update("deleted_by_id", deletedBy).Error; err != nil {
+			return err
+		}
+		return tx.Delete(comment).Error
+	})
+}
+
+// livePostIDs 未删除文章的ID子查询。随文章级联删除的评论不出现在审核列表中，也不能单独恢复，
+// 否则会在已删除的文章下重新出现并进入搜索索引
+func (r *gormCommentRepository) livePostIDs() *gorm.DB {
+	return r.db.Model(&Post{}).Select("id")
+}
+
+func (r *gormCommentRepository) ListDeleted(q PageQuery) ([]Comment, *Pagination, error) {
+	db := r.db.Unscoped().Where("comments.deleted_at IS NOT NULL AND comments.post_id IN (?)", r.livePostIDs()).
+		Preload("User", selectAuthor)
+	db, err := keyset(db, "comments.deleted_at", "comments.id", q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var comments []Comment
+	if err := db.Find(&comments).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Pagination{Limit: q.Limit}
+	if len(comments) > q.Limit {
+		comments = comments[:q.Limit]
+		last := comments[len(comments)-1]
+		page.HasMore = true
+		page.NextCursor = pageCursor{Sort: q.Sort, Desc: q.Desc, Value: timeCursorValue(last.DeletedAt.Time), ID: last.ID}.encode()
+	}
+	return comments, page, nil
+}
+
+func (r *gormCommentRepository) Restore(id uint) (*Comment, error) {
+	var comment Comment
+	if err := r.db.Unscoped().Where("deleted_at IS NOT NULL AND post_id IN (?)", r.livePostIDs()).First(&comment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	if err := r.db.Unscoped().Model(&comment).Updates(map[string]interface{}{"deleted_at": nil, "deleted_by_id": nil}).Error; err != nil {
+		return nil, err
+	}
+	comment.DeletedAt = gorm.DeletedAt{}
+	comment.DeletedByID = nil
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) ForEach(fn func(batch []Comment) error) error {
+	var batch []Comment
+	return r.db.FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// --- 标签 ---
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTagRepository) ListWithCounts() ([]TagCount, error) {
+	var tags []TagCount
+	err := r.db.Model(&Tag{}).
+		Select("tags.*, COUNT(posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ?", postStatusPublished).
+		Group("tags.id").
+		Order("post_count DESC").Order("tags.slug").
+		Scan(&tags).Error
+	return tags, err
+}
+
+func (r *gormTagRepository) FindBySlug(slug string) (*Tag, error) {
+	var tag Tag
+	if err := r.db.Where("slug = ?", slug).First(&tag).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &tag, nil
+}
+
+// --- 修订 ---
+type gormRevisionRepository struct {
+	db *gorm.DB
+}
+
+// Create 保存修订并分配递增的版本号；锁住文章行使同一文章的修订串行编号，
+// 不支持行锁的数据库上并发编号冲突时重试
+func (r *gormRevisionRepository) Create(rev *PostRevision) error {
+	for attempt := 1; ; attempt++ {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id").First(&Post{}, rev.PostID).Error; err != nil {
+				return translateError(err)
+			}
+			var last int
+			if err := tx.Model(&PostRevision{}).Where("post_id = ?", rev.PostID).
+				Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+				return err
+			}
+			rev.Number = last + 1
+			return tx.Create(rev).Error
+		})
+		if err == nil || attempt == maxSlugAttempts || !isDuplicateKey(r.db, err) {
+			return err
+		}
+		rev.ID = 0
+	}
+}
+
+func (r *gormRevisionRepository) ListByPost(postID uint) ([]PostRevision, error) {
+	var revs []PostRevision
+	err := r.db.Omit("content").Where("post_id = ?", postID).
+		Preload("Editor", selectAuthor).Order("number DESC").Find(&revs).Error
+	return revs, err
+}
+
+func (r *gormRevisionRepository) Find(postID uint, number int) (*PostRevision, error) {
+	var rev PostRevision
+	if err := r.db.Preload("Editor", selectAuthor).
+		Where("post_id = ? AND number = ?", postID, number).First(&rev).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &rev, nil
+}
+
+func (r *gormRevisionRepository) Prune(postID uint, keep int, before time.Time) (int64, error) {
+	var revs []PostRevision
+	if err := r.db.Select("id", "created_at").Where("post_id = ?", postID).
+		Order("number DESC").Find(&revs).Error; err != nil {
+		return 0, err
+	}
+
+	// 最新的修订始终保留
+	var ids []uint
+	for i, rev := range revs {
+		if i == 0 {
+			continue
+		}
+		if (keep > 0 && i >= keep) || (!before.IsZero() && rev.CreatedAt.Before(before)) {
+			ids = append(ids, rev.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Delete(&PostRevision{}, ids)
+	return result.RowsAffected, result.Error
+}
+
+// --- 用户 ---
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(user *User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) FindByID(id uint) (*User, error) {
+	var user User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (*User, error) {
+	var user User
+	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsernames(usernames []string) ([]User, error) {
+	var users []User
+	if err := r.db.Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*User, error) {
+	var user User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) SetEmail(userID uint, email string) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"email": email, "email_verified_at": nil}).Error
+}
+
+func (r *gormUserRepository) MarkEmailVerified(userID uint, email string) (bool, error) {
+	result := r.db.Model(&User{}).Where("id = ? AND email = ?", userID, email).Update("email_verified_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormUserRepository) UpdatePassword(userID uint, hash string) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).Update("password", hash).Error
+}
+
+func (r *gormUserRepository) UpdateProfile(userID uint, bio string) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).Update("bio", bio).Error
+}
+
+func (r *gormUserRepository) SetAvatar(userID uint, key string) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).Update("avatar_key", key).Error
+}
+
+func (r *gormUserRepository) Roles(userID uint) ([]string, error) {
+	return loadUserRoles(r.db, userID)
+}
+
+func (r *gormUserRepository) GrantRole(userID uint, role string) error {
+	return r.db.Where(UserRole{UserID: userID, Role: role}).FirstOrCreate(&UserRole{}).Error
+}
+
+func (r *gormUserRepository) RevokeRole(userID uint, role string) (bool, error) {
+	revoked := false
+	// 先删除再统计剩余管理员：删除取得写锁，并发撤销两个管理员时后一个事务能看到前一个的结果
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND role = ?", userID, role).Delete(&UserRole{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		revoked = true
+		if role != roleAdmin {
+			return nil
+		}
+		var admins int64
+		if err := tx.Model(&UserRole{}).Where("role = ?", roleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins == 0 {
+			return ErrLastAdmin
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// --- 回应 ---
+type gormReactionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReactionRepository) Toggle(reaction *Reaction) (bool, error) {
+	reacted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("target_type = ? AND target_id = ? AND user_id = ? AND kind = ?",
+			reaction.TargetType, reaction.TargetID, reaction.UserID, reaction.Kind).Delete(&Reaction{})
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		// 同一用户并发添加时唯一索引保证只有一条生效
+		reacted = true
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error
+	})
+	return reacted, err
+}
+
+func (r *gormReactionRepository) Counts(targetType string, ids []uint) (map[uint]ReactionCounts, error) {
+	var rows []struct {
+		TargetID uint
+		Kind     string
+		Total    int64
+	}
+	if err := r.db.Model(&Reaction{}).Select("target_id, kind, COUNT(*) AS total").
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id, kind").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]ReactionCounts)
+	for _, row := range rows {
+		if counts[row.TargetID] == nil {
+			counts[row.TargetID] = make(ReactionCounts)
+		}
+		counts[row.TargetID][row.Kind] = row.Total
+	}
+	return counts, nil
+}
+
+func (r *gormReactionRepository) UserKinds(userID uint, targetType string, ids []uint) (map[uint][]string, error) {
+	var list []Reaction
+	if err := r.db.Select("target_id", "kind").
+		Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, ids).
+		Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	kinds := make(map[uint][]string)
+	for _, reaction := range list {
+		kinds[reaction.TargetID] = append(kinds[reaction.TargetID], reaction.Kind)
+	}
+	return kinds, nil
+}
+
+// --- 关注 ---
+type gormFollowRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormFollowRepository) Follow(followerID, followeeID uint) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Follow{FollowerID: followerID, FolloweeID: followeeID})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormFollowRepository) Unfollow(followerID, followeeID uint) (bool, error) {
+	result := r.db.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&Follow{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormFollowRepository) ListFollowers(userID uint, q PageQuery) ([]Follow, *Pagination, error) {
+	return r.list(r.db.Where("followee_id = ?", userID).Preload("Follower", selectAuthor), q)
+}
+
+func (r *gormFollowRepository) ListFollowing(userID uint, q PageQuery) ([]Follow, *Pagination, error) {
+	return r.list(r.db.Where("follower_id = ?", userID).Preload("Followee", selectAuthor), q)
+}
+
+// list 按关注时间分页
+func (r *gormFollowRepository) list(db *gorm.DB, q PageQuery) ([]Follow, *Pagination, error) {
+	db, err := keyset(db, "follows.created_at", "follows.id", q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var follows []Follow
+	if err := db.Find(&follows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Pagination{Limit: q.Limit}
+	if len(follows) > q.Limit {
+		follows = follows[:q.Limit]
+		last := follows[len(follows)-1]
+		page.HasMore = true
+		page.NextCursor = pageCursor{Sort: q.Sort, Desc: q.Desc, Value: timeCursorValue(last.CreatedAt), ID: last.ID}.encode()
+	}
+	return follows, page, nil
+}
+
+// --- 通知 ---
+type gormNotificationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormNotificationRepository) CreateBatch(list []Notification) error {
+	return r.db.Create(&list).Error
+}
+
+func (r *gormNotificationRepository) List(userID uint, unreadOnly bool, q PageQuery) ([]Notification, *Pagination, error) {
+	db := r.db.Where("user_id = ?", userID).Preload("Actor", selectAuthor)
+	if unreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	db, err := keyset(db, "notifications.created_at", "notifications.id", q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var list []Notification
+	if err := db.Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Pagination{Limit: q.Limit}
+	if len(list) > q.Limit {
+		list = list[:q.Limit]
+		last := list[len(list)-1]
+		page.HasMore = true
+		page.NextCursor = pageCursor{Sort: q.Sort, Desc: q.Desc, Value: timeCursorValue(last.CreatedAt), ID: last.ID}.encode()
+	}
+	return list, page, nil
+}
+
+func (r *gormNotificationRepository) CountUnread(userID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *gormNotificationRepository) MarkRead(userID, id uint) (bool, error) {
+	var n Notification
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if n.ReadAt != nil {
+		return true, nil
+	}
+	return true, r.db.Model(&n).Update("read_at", time.Now()).Error
+}
+
+func (r *gormNotificationRepository) MarkAllRead(userID uint) (int64, error) {
+	result := r.db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// --- 附件 ---
+type gormAttachmentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAttachmentRepository) Create(a *Attachment) error {
+	return r.db.Create(a).Error
+}
+
+func (r *gormAttachmentRepository) FindByID(id uint) (*Attachment, error) {
+	var a Attachment
+	if err := r.db.First(&a, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &a, nil
+}
+
+func (r *gormAttachmentRepository) ListByPost(postID uint) ([]Attachment, error) {
+	list := []Attachment{}
+	err := r.db.Where("post_id = ?", postID).Order("id").Find(&list).Error
+	return list, err
+}
+
+func (r *gormAttachmentRepository) Delete(id uint) error {
+	return r.db.Delete(&Attachment{}, id).Error
+}
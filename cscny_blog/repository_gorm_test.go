@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// newTestUser 直接通过仓储创建用户
+func newTestUser(t *testing.T, users UserRepository, username string) *User {
+	t.Helper()
+	user := &User{Username: username, Password: "x"}
+	if err := users.Create(user); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+func TestRepositoriesReturnErrNotFound(t *testing.T) {
+	repos := newTestApp(t).repos
+
+	tests := []struct {
+		name string
+		find func() error
+	}{
+		{"文章", func() error { _, err := repos.Posts.FindByID(42); return err }},
+		{"文章slug", func() error { _, err := repos.Posts.FindBySlug("missing"); return err }},
+		{"评论", func() error { _, err := repos.Comments.FindByID(42); return err }},
+		{"用户", func() error { _, err := repos.Users.FindByID(42); return err }},
+		{"用户名", func() error { _, err := repos.Users.FindByUsername("nobody"); return err }},
+		{"邮箱", func() error { _, err := repos.Users.FindByEmail("nobody@example.com"); return err }},
+		{"标签", func() error { _, err := repos.Tags.FindBySlug("missing"); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.find(); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("错误为%v，期望ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestUserRepositoryRoles(t *testing.T) {
+	users := newTestApp(t).repos.Users
+	alice := newTestUser(t, users, "alice")
+
+	if err := users.GrantRole(alice.ID, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.GrantRole(alice.ID, roleModerator); err != nil {
+		t.Fatalf("重复授予角色应当成功: %v", err)
+	}
+	roles, err := users.Roles(alice.ID)
+	if err != nil || len(roles) != 1 || roles[0] != roleModerator {
+		t.Fatalf("角色为%v（err=%v）", roles, err)
+	}
+
+	revoked, err := users.RevokeRole(alice.ID, roleModerator)
+	if err != nil || !revoked {
+		t.Fatalf("撤销角色: revoked=%v err=%v", revoked, err)
+	}
+	if revoked, _ := users.RevokeRole(alice.ID, roleModerator); revoked {
+		t.Fatal("再次撤销不应返回true")
+	}
+}
+
+func TestPostRepositoryCRUD(t *testing.T) {
+	repos := newTestApp(t).repos
+	alice := newTestUser(t, repos.Users, "alice")
+
+	post := &Post{Title: "Hello", Content: "content", UserID: alice.ID, Status: postStatusPublished}
+	if err := repos.Posts.Create(post); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repos.Posts.FindWithAuthor(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.User.Username != "alice" {
+		t.Fatalf("作者为%q", found.User.Username)
+	}
+
+	found.Content = "changed"
+	if err := repos.Posts.Update(found); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := repos.Posts.FindByID(post.ID); again.Content != "changed" {
+		t.Fatalf("内容为%q", again.Content)
+	}
+
+	comment := &Comment{Content: "hi", UserID: alice.ID, PostID: post.ID}
+	if err := repos.Comments.Create(comment); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Posts.Delete(found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Posts.FindByID(post.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("删除后查询错误为%v", err)
+	}
+	if _, err := repos.Comments.FindByID(comment.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("文章删除后评论仍存在: %v", err)
+	}
+}
+
+func TestPostRepositoryCountByAuthor(t *testing.T) {
+	repos := newTestApp(t).repos
+	alice := newTestUser(t, repos.Users, "alice")
+	for _, status := range []string{postStatusPublished, postStatusPublished, postStatusDraft} {
+		if err := repos.Posts.Create(&Post{Title: "t", Content: "c", UserID: alice.ID, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		status string
+		want   int64
+	}{
+		{postStatusPublished, 2},
+		{postStatusDraft, 1},
+		{"", 3},
+	}
+	for _, tt := range tests {
+		got, err := repos.Posts.CountByAuthor(alice.ID, tt.status)
+		if err != nil || got != tt.want {
+			t.Errorf("status=%q: 数量为%d（err=%v），期望%d", tt.status, got, err, tt.want)
+		}
+	}
+}
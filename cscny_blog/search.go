@@ -0,0 +1,472 @@
+package main
+
+import (
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 全文搜索 ===
+// 进程内倒排索引：中文按单字+双字切分，英文按单词切分（转小写），BM25排序
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	titleWeight    = 2  // 标题中的词按2倍词频计算
+	snippetBefore  = 30 // 摘要中命中词之前保留的字符数
+	snippetLength  = 120
+	docTypePost    = "post"
+	docTypeComment = "comment"
+)
+
+// englishStopWords 英文停用词（不进入索引）
+var englishStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {},
+	"for": {}, "in": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "the": {},
+	"to": {}, "with": {},
+}
+
+// searchToken 分词结果（Start/End为原文中的字节偏移，用于高亮）
+type searchToken struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// isCJK 判断是否为需要按字切分的东亚文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// tokenize 将文本切分为索引词；withUnigrams为true时中文同时输出单字
+func tokenize(text string, withUnigrams bool) []searchToken {
+	var tokens []searchToken
+
+	type cjkRune struct {
+		r     rune
+		start int
+		end   int
+	}
+	var cjkRun []cjkRune
+	flushCJK := func() {
+		if len(cjkRun) == 1 || withUnigrams {
+			for _, c := range cjkRun {
+				tokens = append(tokens, searchToken{Term: string(c.r), Start: c.start, End: c.end})
+			}
+		}
+		for i := 0; i+1 < len(cjkRun); i++ {
+			tokens = append(tokens, searchToken{
+				Term:  string(cjkRun[i].r) + string(cjkRun[i+1].r),
+				Start: cjkRun[i].start,
+				End:   cjkRun[i+1].end,
+			})
+		}
+		cjkRun = cjkRun[:0]
+	}
+
+	wordStart := -1
+	flushWord := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		word := strings.ToLower(text[wordStart:end])
+		if _, stop := englishStopWords[word]; !stop {
+			tokens = append(tokens, searchToken{Term: word, Start: wordStart, End: end})
+		}
+		wordStart = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			cjkRun = append(cjkRun, cjkRune{r: r, start: i, end: i + utf8.RuneLen(r)})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			flushCJK()
+			flushWord(i)
+		}
+	}
+	flushCJK()
+	flushWord(len(text))
+	return tokens
+}
+
+// queryTerms 对查询词分词并去重；中文查询只有一个字时才使用单字
+func queryTerms(query string) []string {
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, t := range tokenize(query, false) {
+		if _, ok := seen[t.Term]; ok {
+			continue
+		}
+		seen[t.Term] = struct{}{}
+		terms = append(terms, t.Term)
+	}
+	return terms
+}
+
+// searchDocKey 索引文档键
+type searchDocKey struct {
+	Type string
+	ID   uint
+}
+
+// searchDoc 索引中保存的文档（用于生成摘要）
+type searchDoc struct {
+	Key     searchDocKey
+	PostID  uint
+	Title   string
+	Content string
+	Length  int // 加权后的词数
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Type    string  `json:"type"`
+	ID      uint    `json:"id"`
+	PostID  uint    `json:"post_id"`
+	Title   string  `json:"title,omitempty"`
+	Snippet string  `json:"snippet"` // 已转义的HTML，命中词用<mark>包裹
+	Score   float64 `json:"score"`
+}
+
+// searchIndex 倒排索引（并发安全）
+type searchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[searchDocKey]int // 词 -> 文档 -> 词频
+	docs     map[searchDocKey]*searchDoc
+	docTerms map[searchDocKey][]string // 文档包含的词，删除时使用
+	hidden   map[uint]struct{}         // 未发布的文章ID，其本身及评论不出现在搜索结果中
+	totalLen int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[searchDocKey]int),
+		docs:     make(map[searchDocKey]*searchDoc),
+		docTerms: make(map[searchDocKey][]string),
+		hidden:   make(map[uint]struct{}),
+	}
+}
+
+// IndexPost 新增或更新文章（未发布的文章同样建立索引，但搜索时隐藏）
+func (idx *searchIndex) IndexPost(post *Post) {
+	idx.mu.Lock()
+	if post.Status == postStatusPublished {
+		delete(idx.hidden, post.ID)
+	} else {
+		idx.hidden[post.ID] = struct{}{}
+	}
+	idx.mu.Unlock()
+
+	idx.upsert(&searchDoc{
+		Key:     searchDocKey{Type: docTypePost, ID: post.ID},
+		PostID:  post.ID,
+		Title:   post.Title,
+		Content: post.Content,
+	})
+}
+
+// IndexComment 新增或更新评论
+func (idx *searchIndex) IndexComment(comment *Comment) {
+	idx.upsert(&searchDoc{
+		Key:     searchDocKey{Type: docTypeComment, ID: comment.ID},
+		PostID:  comment.PostID,
+		Content: comment.Content,
+	})
+}
+
+// RemovePost 删除文章及其下所有评论
+func (idx *searchIndex) RemovePost(postID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.hidden, postID)
+	for key, doc := range idx.docs {
+		if doc.PostID == postID {
+			idx.removeLocked(key)
+		}
+	}
+}
+
+// RemoveComment 删除评论
+func (idx *searchIndex) RemoveComment(commentID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(searchDocKey{Type: docTypeComment, ID: commentID})
+}
+
+func (idx *searchIndex) upsert(doc *searchDoc) {
+	freqs := make(map[string]int)
+	for _, t := range tokenize(doc.Title, true) {
+		freqs[t.Term] += titleWeight
+	}
+	for _, t := range tokenize(doc.Content, true) {
+		freqs[t.Term]++
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(doc.Key)
+
+	terms := make([]string, 0, len(freqs))
+	for term, tf := range freqs {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[searchDocKey]int)
+		}
+		idx.postings[term][doc.Key] = tf
+		doc.Length += tf
+		terms = append(terms, term)
+	}
+	idx.docs[doc.Key] = doc
+	idx.docTerms[doc.Key] = terms
+	idx.totalLen += doc.Length
+}
+
+func (idx *searchIndex) removeLocked(key searchDocKey) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for _, term := range idx.docTerms[key] {
+		delete(idx.postings[term], key)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLen -= doc.Length
+	delete(idx.docs, key)
+	delete(idx.docTerms, key)
+}
+
+// Search 按BM25得分返回结果；docType为空时同时搜索文章和评论
+func (idx *searchIndex) Search(query, docType string, offset, limit int) ([]SearchResult, int) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, 0
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return []SearchResult{}, 0
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	scores := make(map[searchDocKey]float64)
+	for _, term := range terms {
+		postings := idx.postings[term]
+		df := float64(len(postings))
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for key, tf := range postings {
+			if docType != "" && key.Type != docType {
+				continue
+			}
+			if _, ok := idx.hidden[idx.docs[key].PostID]; ok {
+				continue
+			}
+			docLen := float64(idx.docs[key].Length)
+			f := float64(tf)
+			scores[key] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+		}
+	}
+
+	keys := make([]searchDocKey, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type > keys[j].Type // 同分时文章排在评论前
+		}
+		return keys[i].ID > keys[j].ID
+	})
+
+	total := len(keys)
+	if offset >= total {
+		return []SearchResult{}, total
+	}
+	keys = keys[offset:]
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	termSet := make(map[string]struct{}, len(terms))
+	for _, t := range terms {
+		termSet[t] = struct{}{}
+	}
+	results := make([]SearchResult, 0, len(keys))
+	for _, key := range keys {
+		doc := idx.docs[key]
+		snippetSource := doc.Content
+		if !containsTerm(doc.Content, termSet) && doc.Title != "" {
+			snippetSource = doc.Title
+		}
+		title := doc.Title
+		if key.Type == docTypeComment {
+			// 评论结果显示所属文章的标题
+			if post, ok := idx.docs[searchDocKey{Type: docTypePost, ID: doc.PostID}]; ok {
+				title = post.Title
+			}
+		}
+		results = append(results, SearchResult{
+			Type:    key.Type,
+			ID:      key.ID,
+			PostID:  doc.PostID,
+			Title:   title,
+			Snippet: highlightSnippet(snippetSource, termSet),
+			Score:   math.Round(scores[key]*1000) / 1000,
+		})
+	}
+	return results, total
+}
+
+func containsTerm(text string, terms map[string]struct{}) bool {
+	for _, t := range tokenize(text, true) {
+		if _, ok := terms[t.Term]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet 截取第一个命中词附近的文本，转义HTML并用<mark>标记命中词
+func highlightSnippet(text string, terms map[string]struct{}) string {
+	tokens := tokenize(text, true)
+
+	// 命中区间（按起点有序，合并重叠部分）
+	var ranges [][2]int
+	for _, t := range tokens {
+		if _, ok := terms[t.Term]; !ok {
+			continue
+		}
+		if n := len(ranges); n > 0 && t.Start <= ranges[n-1][1] {
+			if t.End > ranges[n-1][1] {
+				ranges[n-1][1] = t.End
+			}
+			continue
+		}
+		ranges = append(ranges, [2]int{t.Start, t.End})
+	}
+
+	// 确定摘要窗口（字节偏移，保证落在字符边界上）
+	start := 0
+	if len(ranges) > 0 {
+		start = ranges[0][0]
+		for i := 0; i < snippetBefore && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+	}
+	end := start
+	for i := 0; i < snippetLength && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, r := range ranges {
+		if r[1] <= start || r[0] >= end {
+			continue
+		}
+		s, e := max(r[0], start), min(r[1], end)
+		b.WriteString(html.EscapeString(text[pos:s]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s:e]))
+		b.WriteString("</mark>")
+		pos = e
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// rebuildSearchIndex 启动时从数据库重建索引
+func rebuildSearchIndex(idx *searchIndex, posts PostRepository, comments CommentRepository) error {
+	if err := posts.ForEach(func(batch []Post) error {
+		for i := range batch {
+			idx.IndexPost(&batch[i])
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return comments.ForEach(func(batch []Comment) error {
+		for i := range batch {
+			idx.IndexComment(&batch[i])
+		}
+		return nil
+	})
+}
+
+// === 搜索Handler ===
+// 全文搜索（无需认证）
+// 查询参数：q（关键词）、type（post/comment，默认全部）、limit、cursor
+func searchHandler(idx *searchIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "缺少搜索关键词q"})
+			return
+		}
+
+		docType := c.Query("type")
+		if docType != "" && docType != docTypePost && docType != docTypeComment {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type只能为post或comment"})
+			return
+		}
+
+		page, err := parsePageQuery(c, true, "relevance")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 相关度排序的游标中保存的是已返回的条数
+		offset := 0
+		if page.Cursor != nil {
+			if offset, err = strconv.Atoi(page.Cursor.Value); err != nil || offset < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidCursor.Error()})
+				return
+			}
+		}
+
+		results, total := idx.Search(query, docType, offset, page.Limit)
+
+		pagination := &Pagination{Limit: page.Limit}
+		if next := offset + len(results); next < total {
+			last := results[len(results)-1]
+			pagination.HasMore = true
+			pagination.NextCursor = pageCursor{Sort: page.Sort, Desc: page.Desc, Value: strconv.Itoa(next), ID: last.ID}.encode()
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": results, "pagination": pagination, "total": total})
+	}
+}
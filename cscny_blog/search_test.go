@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		withUnigrams bool
+		want         []string
+	}{
+		{"英文转小写并去掉停用词", "The Go and GORM", false, []string{"go", "gorm"}},
+		{"中文双字切分", "搜索引擎", false, []string{"搜索", "索引", "引擎"}},
+		{"中文同时输出单字", "博客", true, []string{"博", "客", "博客"}},
+		{"单个汉字", "字", false, []string{"字"}},
+		{"中英文混排", "Go语言v2", false, []string{"go", "语言", "v2"}},
+		{"标点分隔", "你好，世界!", false, []string{"你好", "世界"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, token := range tokenize(tt.text, tt.withUnigrams) {
+				got = append(got, token.Term)
+				if tt.text[token.Start:token.End] == "" {
+					t.Fatalf("%q的偏移为空", token.Term)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("分词结果为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	if got, want := queryTerms("Go go 语言 语言"), []string{"go", "语言"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("查询词为%v，期望%v", got, want)
+	}
+	if got := queryTerms("the, of"); len(got) != 0 {
+		t.Fatalf("只有停用词时应为空: %v", got)
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	terms := map[string]struct{}{"go": {}}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"标记命中词", "learn Go today", "learn <mark>Go</mark> today"},
+		{"转义HTML", "<b>Go</b>", "&lt;b&gt;<mark>Go</mark>&lt;/b&gt;"},
+		{"未命中时取开头", "nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.text, terms); got != tt.want {
+				t.Fatalf("摘要为%q，期望%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := newSearchIndex()
+	idx.IndexPost(&Post{Model: gorm.Model{ID: 1}, Title: "Gin框架入门", Content: "介绍路由和中间件。", Status: postStatusPublished})
+	idx.IndexPost(&Post{Model: gorm.Model{ID: 2}, Title: "数据库", Content: "GORM也能配合Gin框架使用。", Status: postStatusPublished})
+	idx.IndexPost(&Post{Model: gorm.Model{ID: 3}, Title: "Gin框架草稿", Content: "还没写完。", Status: postStatusDraft})
+	idx.IndexComment(&Comment{Model: gorm.Model{ID: 10}, PostID: 2, Content: "中间件怎么写？"})
+	idx.IndexComment(&Comment{Model: gorm.Model{ID: 11}, PostID: 3, Content: "草稿里的中间件"})
+
+	keys := func(results []SearchResult) []searchDocKey {
+		var out []searchDocKey
+		for _, r := range results {
+			out = append(out, searchDocKey{Type: r.Type, ID: r.ID})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		docType string
+		want    []searchDocKey
+	}{
+		{"标题命中排在正文前", "gin框架", "", []searchDocKey{{docTypePost, 1}, {docTypePost, 2}}},
+		{"同时搜索文章和评论，短文档得分更高", "中间件", "", []searchDocKey{{docTypeComment, 10}, {docTypePost, 1}}},
+		{"只搜索评论", "中间件", docTypeComment, []searchDocKey{{docTypeComment, 10}}},
+		{"未命中", "不存在的词", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total := idx.Search(tt.query, tt.docType, 0, 10)
+			if got := keys(results); !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+				t.Fatalf("结果为%v（共%d条），期望%v", got, total, tt.want)
+			}
+		})
+	}
+
+	// 评论结果显示所属文章的标题
+	if results, _ := idx.Search("怎么", "", 0, 10); len(results) != 1 || results[0].Title != "数据库" {
+		t.Fatalf("评论结果为%+v", results)
+	}
+
+	// 发布后文章及其评论可被搜索到；删除文章时一并删除评论
+	idx.IndexPost(&Post{Model: gorm.Model{ID: 3}, Title: "Gin框架草稿", Content: "还没写完。", Status: postStatusPublished})
+	if _, total := idx.Search("草稿", "", 0, 10); total != 2 {
+		t.Fatalf("发布后应命中文章和评论，实际%d条", total)
+	}
+	idx.RemovePost(3)
+	if _, total := idx.Search("草稿", "", 0, 10); total != 0 {
+		t.Fatalf("删除后仍命中%d条", total)
+	}
+	if _, ok := idx.docs[searchDocKey{Type: docTypeComment, ID: 11}]; ok {
+		t.Fatal("删除文章后评论仍在索引中")
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+	for _, title := range []string{"Go语言并发", "Go语言接口", "Go语言泛型"} {
+		app.createPost(token, gin.H{"title": title})
+	}
+	app.createPost(token, gin.H{"title": "Go语言草稿", "status": postStatusDraft})
+
+	type searchResponse struct {
+		Data       []SearchResult `json:"data"`
+		Pagination Pagination     `json:"pagination"`
+		Total      int            `json:"total"`
+	}
+
+	// 按相关度翻页，草稿不出现在结果中
+	seen := make(map[uint]bool)
+	query := url.Values{"q": {"语言"}, "limit": {"2"}}
+	for {
+		var resp searchResponse
+		expect(t, app.request(http.MethodGet, "/api/public/search?"+query.Encode(), nil, ""), http.StatusOK, &resp)
+		if resp.Total != 3 {
+			t.Fatalf("共%d条结果，期望3条", resp.Total)
+		}
+		for _, r := range resp.Data {
+			if seen[r.ID] {
+				t.Fatalf("文章%d重复出现", r.ID)
+			}
+			seen[r.ID] = true
+		}
+		if !resp.Pagination.HasMore {
+			break
+		}
+		query.Set("cursor", resp.Pagination.NextCursor)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("翻页结果为%v", seen)
+	}
+
+	for _, query := range []string{"", "q=go&type=user", "q=go&sort=created_at", "q=go&cursor=abc"} {
+		t.Run(query, func(t *testing.T) {
+			expect(t, app.request(http.MethodGet, "/api/public/search?"+query, nil, ""), http.StatusBadRequest, nil)
+		})
+	}
+}
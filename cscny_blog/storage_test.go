@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoragePath(t *testing.T) {
+	root := t.TempDir()
+	s, err := newLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want string // 为空表示拒绝
+	}{
+		{"posts/1/a.jpg", filepath.Join(root, "posts", "1", "a.jpg")},
+		{"a.txt", filepath.Join(root, "a.txt")},
+		{"", ""},
+		{"../a.txt", ""},
+		{"posts/../../a.txt", ""},
+		{"/etc/passwd", ""},
+		{"posts//a.txt", ""},
+		{"posts/./a.txt", ""},
+		{"posts\\..\\a.txt", ""},
+		{"posts/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := s.path(tt.key)
+			if tt.want == "" {
+				if !errors.Is(err, errInvalidStorageKey) {
+					t.Fatalf("路径%q未被拒绝: %s", tt.key, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("路径为%q(%v)，期望%q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s, err := newLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(key string) (string, error) {
+		f, err := s.Open(key)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		return string(data), err
+	}
+
+	// 保存时自动创建目录，覆盖已有文件
+	for _, content := range []string{"第一版", "第二版"} {
+		if err := s.Save("posts/1/a.txt", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := read("posts/1/a.txt"); err != nil || got != content {
+			t.Fatalf("读取到%q(%v)", got, err)
+		}
+	}
+	if err := s.Save("../a.txt", strings.NewReader("x")); !errors.Is(err, errInvalidStorageKey) {
+		t.Fatalf("保存越界路径: %v", err)
+	}
+
+	// 不留下临时文件
+	entries, _ := os.ReadDir(filepath.Join(root, "posts", "1"))
+	if len(entries) != 1 {
+		t.Fatalf("目录中有%d个文件", len(entries))
+	}
+
+	if err := s.Delete("posts/1/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("posts/1/a.txt"); err != nil {
+		t.Fatalf("删除不存在的文件: %v", err)
+	}
+	for _, key := range []string{"posts/1/a.txt", "../a.txt"} {
+		if _, err := read(key); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("读取%s: %v", key, err)
+		}
+	}
+}
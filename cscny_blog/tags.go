@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// === 标签 ===
+const (
+	maxTagsPerPost = 10
+	maxTagLength   = 30 // 按字符计
+)
+
+// Tag 标签模型（与文章多对多关联，关联表post_tags）
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"type:varchar(50);not null" json:"name"`              // 规范化后的名称
+	Slug      string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"slug"` // URL标识
+	CreatedAt time.Time `json:"-"`
+}
+
+// TagCount 标签及其下文章数
+type TagCount struct {
+	Tag
+	PostCount int64 `json:"post_count"`
+}
+
+// TagRepository 标签仓储
+type TagRepository interface {
+	ListWithCounts() ([]TagCount, error) // 按文章数倒序
+	FindBySlug(slug string) (*Tag, error)
+}
+
+// normalizeTagName 规范化标签名：全角转半角(NFKC)、转小写、合并空白
+func normalizeTagName(name string) string {
+	name = norm.NFKC.String(name)
+	name = strings.ToLower(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// tagSlug 由规范化后的名称生成slug：保留字母（含中文）和数字，其余字符转为"-"
+func tagSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// normalizeTags 规范化并去重客户端提交的标签
+func normalizeTags(names []string) ([]Tag, error) {
+	if len(names) > maxTagsPerPost {
+		return nil, fmt.Errorf("每篇文章最多%d个标签", maxTagsPerPost)
+	}
+	seen := make(map[string]struct{})
+	tags := make([]Tag, 0, len(names))
+	for _, raw := range names {
+		name := normalizeTagName(raw)
+		if name == "" {
+			continue
+		}
+		if len([]rune(name)) > maxTagLength {
+			return nil, fmt.Errorf("标签过长（最多%d个字符）: %s", maxTagLength, name)
+		}
+		slug := tagSlug(name)
+		if slug == "" {
+			return nil, fmt.Errorf("无效的标签: %s", raw)
+		}
+		if _, ok := seen[slug]; ok {
+			continue
+		}
+		seen[slug] = struct{}{}
+		tags = append(tags, Tag{Name: name, Slug: slug})
+	}
+	return tags, nil
+}
+
+// === 标签Handler ===
+// 标签列表及文章数（无需认证）
+func listTagsHandler(tags TagRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := tags.ListWithCounts()
+		if err != nil {
+			logger.Printf("查询标签失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list})
+	}
+}
+
+// 某标签下的文章列表（无需认证，分页与排序参数同文章列表）
+func listTagPostsHandler(tags TagRepository, posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := tagSlug(normalizeTagName(c.Param("slug")))
+		tag, err := tags.FindBySlug(slug)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+				return
+			}
+			logger.Printf("查询标签失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+			return
+		}
+
+		query, err := parsePostQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query.TagID = tag.ID
+		query.Status = postStatusPublished
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Printf("查询标签文章失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文章失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": list, "tag": tag, "pagination": page})
+	}
+}
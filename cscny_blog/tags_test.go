@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []string
+		want    []Tag
+		wantErr bool
+	}{
+		{"全角转半角并转小写", []string{"ＧＯ"}, []Tag{{Name: "go", Slug: "go"}}, false},
+		{"合并空白，符号转为连字符", []string{"  Web   开发 ", "c++"}, []Tag{{Name: "web 开发", Slug: "web-开发"}, {Name: "c++", Slug: "c"}}, false},
+		{"按slug去重并忽略空标签", []string{"Go", "go", " ", "GO!"}, []Tag{{Name: "go", Slug: "go"}}, false},
+		{"标签过多", strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), nil, true},
+		{"标签过长", []string{strings.Repeat("长", maxTagLength+1)}, nil, true},
+		{"只有符号", []string{"+++"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望返回错误，实际为%v", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("结果为%v（err=%v），期望%v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagRoutes(t *testing.T) {
+	app := newTestApp(t)
+	_, token := app.newUser("alice")
+	first := app.createPost(token, gin.H{"tags": []string{"Go", "Gin"}})
+	second := app.createPost(token, gin.H{"tags": []string{"go"}})
+	app.createPost(token, gin.H{"tags": []string{"go", "草稿"}, "status": postStatusDraft})
+
+	// 标签列表只统计已发布的文章
+	var tags struct {
+		Data []TagCount `json:"data"`
+	}
+	expect(t, app.request(http.MethodGet, "/api/public/tags", nil, ""), http.StatusOK, &tags)
+	var counts []string
+	for _, tag := range tags.Data {
+		counts = append(counts, fmt.Sprintf("%s:%d", tag.Slug, tag.PostCount))
+	}
+	if want := []string{"go:2", "gin:1"}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("标签统计为%v，期望%v", counts, want)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   []uint
+	}{
+		{"标签下的已发布文章", "/api/public/tags/go/posts", http.StatusOK, []uint{second.ID, first.ID}},
+		{"slug按标签名规范化", "/api/public/tags/GIN/posts", http.StatusOK, []uint{first.ID}},
+		{"只有草稿的标签", "/api/public/tags/草稿/posts", http.StatusOK, nil},
+		{"标签不存在", "/api/public/tags/rust/posts", http.StatusNotFound, nil},
+		{"分页参数无效", "/api/public/tags/go/posts?limit=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp listResponse[Post]
+			expect(t, app.request(http.MethodGet, tt.path, nil, ""), tt.status, &resp)
+			var got []uint
+			for _, post := range resp.Data {
+				got = append(got, post.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("文章为%v，期望%v", got, tt.want)
+			}
+		})
+	}
+
+	// 更新时传空数组清空标签，不再使用的标签被删除
+	w := app.request(http.MethodPut, fmt.Sprintf("/api/protected/posts/%d", first.ID), gin.H{"tags": []string{}}, token)
+	expect(t, w, http.StatusOK, nil)
+	expect(t, app.request(http.MethodGet, "/api/public/tags/gin/posts", nil, ""), http.StatusNotFound, nil)
+	var resp listResponse[Post]
+	expect(t, app.request(http.MethodGet, "/api/public/tags/go/posts", nil, ""), http.StatusOK, &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != second.ID {
+		t.Fatalf("清空标签后go标签下的文章为%v", resp.Data)
+	}
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// === 服务端渲染页面 ===
+// 模板与静态文件通过embed打包进二进制；每个页面模板与layout.html组合，
+// 由layout引用页面中定义的"content"块。
+
+//go:embed web/templates/*.html web/static/*
+var webFS embed.FS
+
+const editorTimeLayout = "2006-01-02T15:04" // datetime-local输入框格式
+
+// pageRenderer 为每个页面单独解析"布局+页面"模板，避免各页面的content块互相覆盖
+type pageRenderer map[string]*template.Template
+
+func (p pageRenderer) Instance(name string, data any) render.Render {
+	return render.HTML{Template: p[name], Name: "layout", Data: data}
+}
+
+var templateFuncs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"datePtr": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Local().Format(editorTimeLayout)
+	},
+	"join": func(tags []Tag) string {
+		names := make([]string, len(tags))
+		for i, tag := range tags {
+			names[i] = tag.Name
+		}
+		return strings.Join(names, ", ")
+	},
+}
+
+// loadPages 解析所有页面模板（模板已嵌入二进制，解析失败属于编程错误）
+func loadPages() pageRenderer {
+	layout := template.Must(template.New("layout.html").Funcs(templateFuncs).ParseFS(webFS, "web/templates/layout.html"))
+	files, err := fs.Glob(webFS, "web/templates/*.html")
+	if err != nil {
+		panic(err)
+	}
+	pages := make(pageRenderer)
+	for _, file := range files {
+		name := path.Base(file)
+		if name == "layout.html" {
+			continue
+		}
+		pages[name] = template.Must(template.Must(layout.Clone()).ParseFS(webFS, file))
+	}
+	return pages
+}
+
+// renderPage 渲染页面，附加当前用户和CSRF令牌等公共数据
+func renderPage(c *gin.Context, status int, name, title string, data gin.H) {
+	if data == nil {
+		data = gin.H{}
+	}
+	data["Title"] = title
+	data["CSRFToken"] = c.GetString("csrfToken")
+	if user, ok := c.Get("currentUser"); ok {
+		data["CurrentUser"] = user
+	}
+	c.HTML(status, name, data)
+}
+
+// renderError 渲染错误页
+func renderError(c *gin.Context, status int, message string) {
+	renderPage(c, status, "error.html", http.StatusText(status), gin.H{"Message": message})
+}
+
+// renderMessage 渲染提示页（操作成功等）
+func renderMessage(c *gin.Context, title, message string) {
+	renderPage(c, http.StatusOK, "message.html", title, gin.H{"Message": message})
+}
+
+// safeRedirect 只允许跳转到站内路径，防止开放重定向
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// splitTags 拆分编辑器中以逗号分隔的标签
+func splitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '，' })
+}
+
+// currentUserID 当前登录用户ID（未登录为0）
+func currentUserID(c *gin.Context) uint {
+	userId, _ := c.Get("userId")
+	id, _ := userId.(uint)
+	return id
+}
+
+// === 页面Handler ===
+// 首页：已发布文章列表（分页参数同文章列表接口）
+func webHomeHandler(posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := parsePostQuery(c)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.Status = postStatusPublished
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				renderError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.Printf("查询文章列表失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询文章失败")
+			return
+		}
+
+		renderPage(c, http.StatusOK, "home.html", "首页", gin.H{"Posts": list, "Page": page})
+	}
+}
+
+// 文章详情页（含评论树和评论表单）
+func webPostHandler(posts PostRepository, renderer *contentRenderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("id") // 文章ID或slug
+		post, err := lookupPost(posts, key)
+		if err == nil {
+			if !canViewPost(c, post) {
+				renderError(c, http.StatusNotFound, "文章不存在")
+				return
+			}
+			// 通过ID或旧slug访问时跳转到规范链接
+			if post.Slug != "" && key != post.Slug {
+				redirectToSlug(c, key, post.Slug)
+				return
+			}
+			post, err = posts.FindWithComments(post.ID)
+		}
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				renderError(c, http.StatusNotFound, "文章不存在")
+				return
+			}
+			logger.Printf("查询文章详情失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询文章失败")
+			return
+		}
+		html, err := renderer.Render(post)
+		if err != nil {
+			logger.Printf("渲染文章内容失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "渲染文章失败")
+			return
+		}
+		post.Comments = buildCommentTree(post.Comments)
+
+		// ?reply_to=ID 时评论表单作为对该评论的回复
+		var replyTo uint
+		if v, err := strconv.ParseUint(c.Query("reply_to"), 10, 64); err == nil {
+			replyTo = uint(v)
+		}
+
+		userID := currentUserID(c)
+		renderPage(c, http.StatusOK, "post.html", post.Title, gin.H{
+			"Post":     post,
+			"HTML":     template.HTML(html), // 已经过白名单过滤
+			"ReplyTo":  replyTo,
+			"CanEdit":  userID != 0 && (post.UserID == userID || hasPermission(c, permPostEditAny)),
+			"MaxDepth": maxCommentDepth,
+			"Error":    c.Query("error"),
+		})
+	}
+}
+
+// 提交评论（需登录）
+func webCreateCommentHandler(posts PostRepository, comments CommentRepository, index *searchIndex, events *eventBus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, err := paramID(c, "id")
+		if err != nil {
+			renderError(c, http.StatusNotFound, "文章不存在")
+			return
+		}
+		post, err := posts.FindByID(postID)
+		if err != nil || !canViewPost(c, post) {
+			renderError(c, http.StatusNotFound, "文章不存在")
+			return
+		}
+		back := postPath(post)
+
+		content := strings.TrimSpace(c.PostForm("content"))
+		var message string
+		switch {
+		case post.Status != postStatusPublished:
+			message = "文章未发布，不能评论"
+		case content == "" || len([]rune(content)) > 500:
+			message = "评论内容需为1-500字"
+		}
+
+		comment := Comment{Content: content, UserID: currentUserID(c), PostID: post.ID}
+		if v := c.PostForm("parent_id"); v != "" && message == "" {
+			parentID, _ := strconv.ParseUint(v, 10, 64)
+			parent, err := comments.FindByID(uint(parentID))
+			switch {
+			case err != nil || parent.PostID != post.ID:
+				message = "回复的评论不存在"
+			case parent.Depth+1 > maxCommentDepth:
+				message = "回复层级超过上限"
+			default:
+				comment.ParentID = &parent.ID
+			}
+		}
+		if message != "" {
+			c.Redirect(http.StatusSeeOther, back+"?error="+url.QueryEscape(message)+"#comment-form")
+			return
+		}
+
+		if err := comments.Create(&comment); err != nil {
+			logger.Printf("创建评论失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "创建评论失败")
+			return
+		}
+		index.IndexComment(&comment)
+		events.Publish(eventCommentCreated, CommentEvent{Comment: &comment, Post: post, ActorID: comment.UserID})
+
+		c.Redirect(http.StatusSeeOther, back+"#comment-"+strconv.FormatUint(uint64(comment.ID), 10))
+	}
+}
+
+// 作者主页：该作者已发布的文章（作者本人还能看到自己的全部文章）
+func webAuthorHandler(users UserRepository, posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		author, err := users.FindByUsername(c.Param("username"))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				renderError(c, http.StatusNotFound, "用户不存在")
+				return
+			}
+			logger.Printf("查询用户失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询用户失败")
+			return
+		}
+
+		query, err := parsePostQuery(c)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.AuthorID = author.ID
+		query.Status = postStatusPublished
+		own := currentUserID(c) == author.ID
+		if own {
+			query.Status = "" // 作者本人查看全部状态
+		}
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				renderError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.Printf("查询作者文章失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询文章失败")
+			return
+		}
+
+		postCount, err := posts.CountByAuthor(author.ID, postStatusPublished)
+		if err != nil {
+			logger.Printf("查询作者文章数失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询文章失败")
+			return
+		}
+
+		renderPage(c, http.StatusOK, "author.html", author.Username, gin.H{
+			"Author":    author,
+			"PostCount": postCount,
+			"Posts":     list,
+			"Page":      page,
+			"Own":       own,
+		})
+	}
+}
+
+// 标签页：列出带该标签的已发布文章
+func webTagHandler(tags TagRepository, posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, err := tags.FindBySlug(tagSlug(normalizeTagName(c.Param("slug"))))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				renderError(c, http.StatusNotFound, "标签不存在")
+				return
+			}
+			logger.Printf("查询标签失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询标签失败")
+			return
+		}
+
+		query, err := parsePostQuery(c)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.TagID = tag.ID
+		query.Status = postStatusPublished
+
+		list, page, err := posts.List(query)
+		if err != nil {
+			if errors.Is(err, errInvalidCursor) {
+				renderError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.Printf("查询标签文章失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "查询文章失败")
+			return
+		}
+
+		renderPage(c, http.StatusOK, "tag.html", "#"+tag.Name, gin.H{
+			"Tag":   tag,
+			"Posts": list,
+			"Page":  page,
+		})
+	}
+}
+
+// 登录页
+func webLoginFormHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderPage(c, http.StatusOK, "login.html", "登录", gin.H{"Next": safeRedirect(c.Query("next"))})
+	}
+}
+
+// 提交登录表单
+func webLoginHandler(guard *loginGuard, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := strings.TrimSpace(c.PostForm("username"))
+		next := safeRedirect(c.PostForm("next"))
+
+		user, err := guard.authenticate(username, c.PostForm("password"), c.ClientIP())
+		if err != nil {
+			status := http.StatusUnauthorized
+			var blocked *loginBlockedError
+			switch {
+			case errors.As(err, &blocked):
+				status = http.StatusTooManyRequests
+				c.Header("Retry-After", ceilSeconds(blocked.RetryAfter))
+			case !errors.Is(err, errInvalidCredentials):
+				logger.Printf("登录校验失败: %v", err)
+				renderError(c, http.StatusInternalServerError, "登录失败，请重试")
+				return
+			}
+			renderPage(c, status, "login.html", "登录", gin.H{
+				"Error": err.Error(), "Username": username, "Next": next,
+			})
+			return
+		}
+
+		if err := startSession(c, tokens, user.ID); err != nil {
+			logger.Printf("创建会话失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "登录失败，请重试")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, next)
+	}
+}
+
+// 注册页
+func webRegisterFormHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderPage(c, http.StatusOK, "register.html", "注册", nil)
+	}
+}
+
+// 提交注册表单（注册成功后自动登录）
+func webRegisterHandler(users UserRepository, tokens *tokenStore, mails *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := strings.TrimSpace(c.PostForm("username"))
+		password := c.PostForm("password")
+
+		fail := func(status int, message string) {
+			renderPage(c, status, "register.html", "注册", gin.H{"Error": message, "Username": username, "Email": c.PostForm("email")})
+		}
+		email, err := normalizeEmail(c.PostForm("email"))
+		switch {
+		case err != nil:
+			fail(http.StatusBadRequest, err.Error())
+			return
+		case len(username) < 3 || len(username) > 20:
+			fail(http.StatusBadRequest, "用户名需为3-20个字符")
+			return
+		case len(password) < 6 || len(password) > 32:
+			fail(http.StatusBadRequest, "密码需为6-32个字符")
+			return
+		case password != c.PostForm("password_confirm"):
+			fail(http.StatusBadRequest, "两次输入的密码不一致")
+			return
+		}
+		if _, err := users.FindByUsername(username); err == nil {
+			fail(http.StatusConflict, "用户名已存在")
+			return
+		}
+		if _, err := users.FindByEmail(email); err == nil {
+			fail(http.StatusConflict, "邮箱已被使用")
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		if err != nil {
+			logger.Printf("密码加密失败: %v", err)
+			fail(http.StatusInternalServerError, "注册失败，请重试")
+			return
+		}
+		user := User{Username: username, Password: string(hash), Email: &email}
+		if err := users.Create(&user); err != nil {
+			logger.Printf("用户创建失败: %v", err)
+			fail(http.StatusInternalServerError, "注册失败，请重试")
+			return
+		}
+		if err := mails.sendVerification(&user, email); err != nil {
+			logger.Printf("发送验证邮件失败: %v", err)
+		}
+
+		if err := startSession(c, tokens, user.ID); err != nil {
+			logger.Printf("创建会话失败: %v", err)
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/")
+	}
+}
+
+// 退出登录
+func webLogoutHandler(tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, err := c.Cookie(sessionCookieName); err == nil && token != "" {
+			if err := tokens.deleteSession(token); err != nil {
+				logger.Printf("注销会话失败: %v", err)
+			}
+		}
+		setCookie(c, sessionCookieName, "", -1)
+		c.Redirect(http.StatusSeeOther, "/")
+	}
+}
+
+// loadEditablePost 编辑器中加载文章并校验权限，失败时已渲染错误页
+func loadEditablePost(c *gin.Context, posts PostRepository) (*Post, bool) {
+	postID, err := paramID(c, "id")
+	if err != nil {
+		renderError(c, http.StatusNotFound, "文章不存在")
+		return nil, false
+	}
+	post, err := posts.FindWithAuthor(postID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			renderError(c, http.StatusNotFound, "文章不存在")
+			return nil, false
+		}
+		logger.Printf("查询文章失败: %v", err)
+		renderError(c, http.StatusInternalServerError, "查询文章失败")
+		return nil, false
+	}
+	if post.UserID != currentUserID(c) && !hasPermission(c, permPostEditAny) {
+		renderError(c, http.StatusForbidden, "没有权限修改此文章")
+		return nil, false
+	}
+	return post, true
+}
+
+// 编辑器页面（新建或编辑）
+func webEditorHandler(posts PostRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("id") == "" {
+			renderPage(c, http.StatusOK, "editor.html", "写文章", gin.H{
+				"Post": &Post{Status: postStatusDraft},
+			})
+			return
+		}
+		post, ok := loadEditablePost(c, posts)
+		if !ok {
+			return
+		}
+		renderPage(c, http.StatusOK, "editor.html", "编辑文章", gin.H{"Post": post})
+	}
+}
+
+// 保存编辑器表单（新建或编辑）
+func webSavePostHandler(posts PostRepository, revisions RevisionRepository, index *searchIndex, renderer *contentRenderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		post := &Post{UserID: userID}
+		creating := c.Param("id") == ""
+		if !creating {
+			var ok bool
+			if post, ok = loadEditablePost(c, posts); !ok {
+				return
+			}
+		}
+		oldTitle, oldContent := post.Title, post.Content
+
+		post.Title = strings.TrimSpace(c.PostForm("title"))
+		post.Content = c.PostForm("content")
+		tagInput := c.PostForm("tags")
+
+		fail := func(message string) {
+			post.Tags = nil
+			title := "编辑文章"
+			if creating {
+				title = "写文章"
+			}
+			renderPage(c, http.StatusBadRequest, "editor.html", title, gin.H{"Post": post, "TagInput": tagInput, "Error": message})
+		}
+		if post.Title == "" || len([]rune(post.Title)) > 100 {
+			fail("标题需为1-100字")
+			return
+		}
+		if len([]rune(post.Content)) < 10 {
+			fail("内容至少10字")
+			return
+		}
+		tags, err := normalizeTags(splitTags(tagInput))
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		var publishAt *time.Time
+		if v := c.PostForm("publish_at"); v != "" {
+			t, err := time.ParseInLocation(editorTimeLayout, v, time.Local)
+			if err != nil {
+				fail("定时发布时间格式错误")
+				return
+			}
+			publishAt = &t
+		}
+		status := c.PostForm("status")
+		if !isValidPostStatus(status) || (creating && status == postStatusArchived) {
+			fail("无效的文章状态")
+			return
+		}
+		if err := applyPostStatus(post, status, publishAt, time.Now()); err != nil {
+			fail(err.Error())
+			return
+		}
+
+		if creating {
+			err = posts.Create(post)
+		} else {
+			err = posts.Update(post)
+		}
+		if err != nil {
+			logger.Printf("保存文章失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "保存文章失败")
+			return
+		}
+		if err := posts.SetTags(post, tags); err != nil {
+			logger.Printf("保存文章标签失败: %v", err)
+			renderError(c, http.StatusInternalServerError, "保存文章标签失败")
+			return
+		}
+		if changes := changedFields(oldTitle, oldContent, post); len(changes) > 0 {
+			if err := recordRevision(revisions, post, userID, changes, nil); err != nil {
+				logger.Printf("保存文章修订失败: %v", err)
+			}
+		}
+		index.IndexPost(post)
+		renderer.Invalidate(post.ID)
+
+		c.Redirect(http.StatusSeeOther, postPath(post))
+	}
+}
+
+// === 页面路由 ===
+// 与JSON接口共用同一个gin引擎，页面路由挂在根路径下
+func setupWebRoutes(r *gin.Engine, repos *Repositories, tokens *tokenStore, index *searchIndex, renderer *contentRenderer, events *eventBus, storage Storage, limiter RateLimitStore, guard *loginGuard, mails *accountMailer, accounts *accountService) {
+	r.HTMLRender = loadPages()
+	static, _ := fs.Sub(webFS, "web/static")
+	r.StaticFS("/static", http.FS(static))
+
+	web := r.Group("/")
+	web.Use(webSessionMiddleware(repos.Users, tokens), csrfMiddleware())
+	{
+		web.GET("/", webHomeHandler(repos.Posts))
+		web.GET("/posts/:id", webPostHandler(repos.Posts, renderer))
+		web.GET("/authors/:username", webAuthorHandler(repos.Users, repos.Posts))
+		web.GET("/tags/:slug", webTagHandler(repos.Tags, repos.Posts))
+		web.GET("/login", webLoginFormHandler())
+		web.POST("/login", webRateLimit(limiter, authRateLimit), webLoginHandler(guard, tokens))
+		web.GET("/register", webRegisterFormHandler())
+		web.POST("/register", webRateLimit(limiter, registerRateLimit), webRegisterHandler(repos.Users, tokens, mails))
+		web.POST("/logout", webLogoutHandler(tokens))
+		web.GET("/forgot-password", webForgotPasswordFormHandler())
+		web.POST("/forgot-password", webRateLimit(limiter, authRateLimit), webForgotPasswordHandler(repos.Users, mails))
+		web.GET("/reset-password", webResetPasswordFormHandler())
+		web.POST("/reset-password", webRateLimit(limiter, authRateLimit), webResetPasswordHandler(repos.Users, tokens, guard))
+		web.GET("/verify-email", webVerifyEmailFormHandler())
+		web.POST("/verify-email", webRateLimit(limiter, authRateLimit), webVerifyEmailHandler(repos.Users, tokens))
+	}
+
+	// 需登录的页面
+	member := web.Group("/", requireWebLogin())
+	{
+		member.POST("/posts/:id/comments", webRateLimit(limiter, commentRateLimit), webCreateCommentHandler(repos.Posts, repos.Comments, index, events))
+		member.GET("/editor", webEditorHandler(repos.Posts))
+		member.GET("/editor/:id", webEditorHandler(repos.Posts))
+		member.POST("/editor", webRateLimit(limiter, writeRateLimit), webSavePostHandler(repos.Posts, repos.Revisions, index, renderer))
+		member.POST("/editor/:id", webRateLimit(limiter, writeRateLimit), webSavePostHandler(repos.Posts, repos.Revisions, index, renderer))
+		member.GET("/settings", webSettingsHandler())
+		member.POST("/settings/profile", webRateLimit(limiter, writeRateLimit), webUpdateProfileHandler(repos.Users))
+		member.POST("/settings/avatar", webRateLimit(limiter, writeRateLimit), webUploadAvatarHandler(repos.Users, storage))
+		member.POST("/settings/avatar/delete", webRateLimit(limiter, writeRateLimit), webDeleteAvatarHandler(repos.Users, storage))
+		member.POST("/settings/password", webRateLimit(limiter, authRateLimit), webChangePasswordHandler(repos.Users, tokens))
+		member.GET("/settings/export", webRateLimit(limiter, writeRateLimit), webExportAccountHandler(accounts))
+		member.POST("/settings/delete-account", webRateLimit(limiter, authRateLimit), webScheduleDeletionHandler(accounts))
+		member.POST("/settings/delete-account/cancel", webRateLimit(limiter, writeRateLimit), webCancelDeletionHandler(accounts))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === 网页会话与CSRF防护 ===
+// 网页端使用Cookie会话（服务端只保存令牌哈希，随用户令牌一起吊销）；
+// 表单提交使用双重提交Cookie校验CSRF令牌。
+
+const (
+	sessionCookieName = "blog_session"
+	csrfCookieName    = "blog_csrf"
+	csrfFormField     = "csrf_token"
+
+	webFormMaxSize   = 1 << 20                        // 普通表单请求体上限
+	webUploadMaxSize = avatarMaxSize + webFormMaxSize // multipart表单（上传头像）请求体上限
+)
+
+var errSessionInvalid = errors.New("会话无效或已过期")
+
+// WebSession 网页登录会话
+type WebSession struct {
+	ID        uint      `gorm:"primarykey"`
+	TokenHash string    `gorm:"type:char(64);uniqueIndex;not null"` // 会话令牌SHA-256哈希
+	UserID    uint      `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// createSession 创建会话，返回写入Cookie的明文令牌（有效期与刷新令牌一致）
+func (s *tokenStore) createSession(userID uint) (string, time.Time, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	session := WebSession{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+// sessionUser 校验会话令牌，返回所属用户ID
+func (s *tokenStore) sessionUser(token string) (uint, error) {
+	var session WebSession
+	err := s.db.Where("token_hash = ? AND expires_at > ?", hashToken(token), time.Now()).First(&session).Error
+	if err != nil {
+		if errors.Is(translateError(err), ErrNotFound) {
+			return 0, errSessionInvalid
+		}
+		return 0, err
+	}
+	return session.UserID, nil
+}
+
+// deleteSession 注销会话
+func (s *tokenStore) deleteSession(token string) error {
+	return s.db.Where("token_hash = ?", hashToken(token)).Delete(&WebSession{}).Error
+}
+
+// setCookie 写入HttpOnly、SameSite=Lax的Cookie（HTTPS请求时附加Secure）
+func setCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+// webSessionMiddleware 识别会话Cookie对应的用户，并确保访客拥有CSRF令牌
+// 与authMiddleware一样设置userId、roles、permissions，另外设置currentUser供模板使用
+func webSessionMiddleware(users UserRepository, tokens *tokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		csrf, err := c.Cookie(csrfCookieName)
+		if err != nil || len(csrf) != 64 {
+			if csrf, err = randomToken(32); err != nil {
+				logger.Printf("生成CSRF令牌失败: %v", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			setCookie(c, csrfCookieName, csrf, 0)
+		}
+		c.Set("csrfToken", csrf)
+
+		token, err := c.Cookie(sessionCookieName)
+		if err != nil || token == "" {
+			c.Next()
+			return
+		}
+		userID, err := tokens.sessionUser(token)
+		if err != nil {
+			if !errors.Is(err, errSessionInvalid) {
+				logger.Printf("查询会话失败: %v", err)
+			}
+			setCookie(c, sessionCookieName, "", -1) // 清除失效的会话Cookie
+			c.Next()
+			return
+		}
+
+		user, err := users.FindByID(userID)
+		if err != nil {
+			setCookie(c, sessionCookieName, "", -1)
+			c.Next()
+			return
+		}
+		roles, err := users.Roles(userID)
+		if err != nil {
+			logger.Printf("查询用户角色失败: %v", err)
+		}
+		c.Set("userId", user.ID)
+		c.Set("roles", roles)
+		c.Set("permissions", permissionsFor(roles))
+		c.Set("currentUser", user)
+		c.Next()
+	}
+}
+
+// csrfMiddleware 校验非GET请求表单中的CSRF令牌与Cookie一致
+func csrfMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		// 在解析表单前限制请求体大小，避免未经校验的请求占用内存和临时文件
+		limit := int64(webFormMaxSize)
+		multipartForm := strings.HasPrefix(c.ContentType(), "multipart/")
+		if multipartForm {
+			limit = webUploadMaxSize
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		var err error
+		if multipartForm {
+			err = c.Request.ParseMultipartForm(limit)
+		} else {
+			err = c.Request.ParseForm()
+		}
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			renderError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("提交的内容不能超过%dMB", limit>>20))
+			c.Abort()
+			return
+		}
+
+		expected := c.GetString("csrfToken")
+		submitted := c.Request.PostFormValue(csrfFormField)
+		if submitted == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) != 1 {
+			renderError(c, http.StatusForbidden, "表单已过期，请刷新页面后重试")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireWebLogin 未登录时跳转到登录页，登录后返回原页面
+func requireWebLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("userId"); !ok {
+			c.Redirect(http.StatusSeeOther, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// startSession 登录成功后创建会话并写入Cookie
+func startSession(c *gin.Context, tokens *tokenStore, userID uint) error {
+	token, expiresAt, err := tokens.createSession(userID)
+	if err != nil {
+		return err
+	}
+	setCookie(c, sessionCookieName, token, int(time.Until(expiresAt).Seconds()))
+	return nil
+}
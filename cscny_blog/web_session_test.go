@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webClient 模拟浏览器访问页面（保存Cookie，提交表单时自动附带CSRF令牌）
+type webClient struct {
+	app     *testApp
+	cookies map[string]string
+}
+
+func (a *testApp) newWebClient() *webClient {
+	return &webClient{app: a, cookies: make(map[string]string)}
+}
+
+// do 发送请求并保存响应中的Cookie
+func (w *webClient) do(method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	w.app.t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range w.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	rec := httptest.NewRecorder()
+	w.app.router.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 || cookie.Value == "" {
+			delete(w.cookies, cookie.Name)
+		} else {
+			w.cookies[cookie.Name] = cookie.Value
+		}
+	}
+	return rec
+}
+
+func (w *webClient) get(path string) *httptest.ResponseRecorder {
+	return w.do(http.MethodGet, path, "", nil)
+}
+
+// csrfToken 返回当前CSRF令牌（没有时先访问首页获取）
+func (w *webClient) csrfToken() string {
+	if _, ok := w.cookies[csrfCookieName]; !ok {
+		w.get("/")
+	}
+	return w.cookies[csrfCookieName]
+}
+
+// post 提交表单（自动附带CSRF令牌）
+func (w *webClient) post(path string, form url.Values) *httptest.ResponseRecorder {
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set(csrfFormField, w.csrfToken())
+	return w.do(http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+// postMultipart 提交带文件的表单（自动附带CSRF令牌）
+func (w *webClient) postMultipart(path, field, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField(csrfFormField, w.csrfToken())
+	part, _ := mw.CreateFormFile(field, filename)
+	part.Write(content)
+	mw.Close()
+	return w.do(http.MethodPost, path, mw.FormDataContentType(), &body)
+}
+
+// login 通过登录表单登录
+func (w *webClient) login(username string) {
+	w.app.t.Helper()
+	rec := w.post("/login", url.Values{"username": {username}, "password": {testPassword}})
+	if rec.Code != http.StatusSeeOther || w.cookies[sessionCookieName] == "" {
+		w.app.t.Fatalf("网页登录失败: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// expectPage 校验页面状态码，并返回页面内容
+func expectPage(t *testing.T, rec *httptest.ResponseRecorder, status int) string {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("状态码为%d，期望%d，响应: %s", rec.Code, status, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/posts/1", "/posts/1"},
+		{"", "/"},
+		{"https://evil.example", "/"},
+		{"//evil.example", "/"},
+		{"/\\evil.example", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeRedirect(tt.next); got != tt.want {
+			t.Fatalf("safeRedirect(%q) = %q，期望%q", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	client := app.newWebClient()
+	token := client.csrfToken()
+	if len(token) != 64 {
+		t.Fatalf("CSRF令牌为%q", token)
+	}
+
+	login := url.Values{"username": {"alice"}, "password": {testPassword}}
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"缺少令牌", "", http.StatusForbidden},
+		{"令牌不一致", strings.Repeat("0", 64), http.StatusForbidden},
+		{"令牌正确", token, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			for k, v := range login {
+				form[k] = v
+			}
+			if tt.token != "" {
+				form.Set(csrfFormField, tt.token)
+			}
+			rec := client.do(http.MethodPost, "/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+			expectPage(t, rec, tt.status)
+		})
+	}
+
+	// 查询参数中的令牌不被接受
+	rec := client.do(http.MethodPost, "/logout?"+csrfFormField+"="+token, "application/x-www-form-urlencoded", nil)
+	expectPage(t, rec, http.StatusForbidden)
+}
+
+func TestCSRFMiddlewareBodyLimit(t *testing.T) {
+	app := newTestApp(t)
+	client := app.newWebClient()
+
+	big := url.Values{"username": {strings.Repeat("a", webFormMaxSize)}}
+	expectPage(t, client.post("/login", big), http.StatusRequestEntityTooLarge)
+
+	app.register("alice")
+	client.login("alice")
+	upload := bytes.Repeat([]byte{0}, webUploadMaxSize)
+	expectPage(t, client.postMultipart("/settings/avatar", "avatar", "avatar.png", upload), http.StatusRequestEntityTooLarge)
+}
+
+func TestWebSession(t *testing.T) {
+	app := newTestApp(t)
+	app.register("alice")
+	client := app.newWebClient()
+
+	// 未登录访问需登录的页面时跳转到登录页，登录后返回原页面
+	rec := client.get("/settings")
+	expectPage(t, rec, http.StatusSeeOther)
+	if loc := rec.Header().Get("Location"); loc != "/login?next=%2Fsettings" {
+		t.Fatalf("跳转到%s", loc)
+	}
+	rec = client.post("/login", url.Values{"username": {"alice"}, "password": {testPassword}, "next": {"/settings"}})
+	if expectPage(t, rec, http.StatusSeeOther); rec.Header().Get("Location") != "/settings" {
+		t.Fatalf("登录后跳转到%s", rec.Header().Get("Location"))
+	}
+	expectPage(t, client.get("/settings"), http.StatusOK)
+
+	// 登录失败时重新显示登录页
+	other := app.newWebClient()
+	if body := expectPage(t, other.post("/login", url.Values{"username": {"alice"}, "password": {"wrong-password"}}), http.StatusUnauthorized); !strings.Contains(body, "alice") {
+		t.Fatal("登录失败后应保留用户名")
+	}
+
+	// 注销后会话失效
+	session := client.cookies[sessionCookieName]
+	expectPage(t, client.post("/logout", nil), http.StatusSeeOther)
+	if _, err := app.tokens.sessionUser(session); err != errSessionInvalid {
+		t.Fatalf("注销后会话仍有效: %v", err)
+	}
+	expectPage(t, client.get("/settings"), http.StatusSeeOther)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	app := newTestApp(t)
+	userID := app.register("alice")
+	token, _, err := app.tokens.createSession(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := app.tokens.sessionUser(token); err != nil || got != userID {
+		t.Fatalf("会话用户为%d（err=%v）", got, err)
+	}
+
+	app.db.Model(&WebSession{}).Where("token_hash = ?", hashToken(token)).Update("expires_at", time.Now().Add(-time.Second))
+	if _, err := app.tokens.sessionUser(token); err != errSessionInvalid {
+		t.Fatalf("过期会话应无效: %v", err)
+	}
+
+	// 失效的会话Cookie被清除
+	client := app.newWebClient()
+	client.cookies[sessionCookieName] = token
+	expectPage(t, client.get("/"), http.StatusOK)
+	if _, ok := client.cookies[sessionCookieName]; ok {
+		t.Fatal("失效的会话Cookie未被清除")
+	}
+}